@@ -0,0 +1,212 @@
+package bot
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/rekurt/ymsdk/client/ym"
+)
+
+// Matcher reports whether a route applies to an update. Matchers may fill the
+// extracted data of the update, like Regex fills Matches.
+type Matcher func(u *Update) bool
+
+// Command matches the slash command name, case-insensitively. The leading slash is optional.
+func Command(name string) Matcher {
+	name = strings.ToLower(strings.TrimPrefix(name, "/"))
+
+	return func(u *Update) bool {
+		return u.Command != "" && u.Command == name
+	}
+}
+
+// AnyCommand matches any slash command.
+func AnyCommand() Matcher {
+	return func(u *Update) bool {
+		return u.Command != ""
+	}
+}
+
+// Regex matches texts matching re and stores the submatches in Update.Matches.
+func Regex(re *regexp.Regexp) Matcher {
+	return func(u *Update) bool {
+		m := re.FindStringSubmatch(u.Text)
+		if m == nil {
+			return false
+		}
+		u.Matches = m
+
+		return true
+	}
+}
+
+// Kind matches updates of any of the kinds, see ym.Update.Kind.
+func Kind(kinds ...ym.UpdateKind) Matcher {
+	return func(u *Update) bool {
+		kind := u.Kind()
+		for _, k := range kinds {
+			if k == kind {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// ChatType matches updates from chats of any of the types.
+func ChatType(types ...ym.ChatType) Matcher {
+	return func(u *Update) bool {
+		if u.Chat == nil {
+			return false
+		}
+		for _, t := range types {
+			if u.Chat.Type == t {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// InChat matches updates from any of the chats.
+func InChat(ids ...ym.ChatID) Matcher {
+	return func(u *Update) bool {
+		if u.Chat == nil {
+			return false
+		}
+		for _, id := range ids {
+			if u.Chat.ID == id {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// From matches updates sent by any of the logins.
+func From(logins ...ym.UserLogin) Matcher {
+	return func(u *Update) bool {
+		if u.From == nil {
+			return false
+		}
+		for _, login := range logins {
+			if u.From.Login == login {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// And matches updates matching all of the matchers.
+func And(matchers ...Matcher) Matcher {
+	return func(u *Update) bool {
+		for _, m := range matchers {
+			if !m(u) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// Or matches updates matching any of the matchers.
+func Or(matchers ...Matcher) Matcher {
+	return func(u *Update) bool {
+		for _, m := range matchers {
+			if m(u) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// Not inverts m.
+func Not(m Matcher) Matcher {
+	return func(u *Update) bool {
+		return !m(u)
+	}
+}
+
+// Any matches every update.
+func Any() Matcher {
+	return func(*Update) bool {
+		return true
+	}
+}
+
+func newUpdate(u ym.Update) *Update {
+	upd := &Update{Update: u}
+	if u.Kind() != ym.UpdateKindText {
+		return upd
+	}
+	text := strings.TrimSpace(u.Text)
+	if !strings.HasPrefix(text, "/") {
+		return upd
+	}
+
+	word, rest := text[1:], ""
+	if end := strings.IndexFunc(word, unicode.IsSpace); end >= 0 {
+		word, rest = word[:end], word[end:]
+	}
+	if at := strings.IndexByte(word, '@'); at >= 0 {
+		word = word[:at]
+	}
+	// "/" alone and paths like "/usr/bin" are not commands.
+	if word == "" || strings.ContainsRune(word, '/') {
+		return upd
+	}
+	upd.Command = strings.ToLower(word)
+	upd.RawArgs = strings.TrimSpace(rest)
+	upd.Args = SplitArgs(upd.RawArgs)
+
+	return upd
+}
+
+// SplitArgs splits command arguments at whitespace. Double or single quotes
+// keep an argument together and a backslash escapes the next character.
+func SplitArgs(s string) []string {
+	var args []string
+	var cur strings.Builder
+	var quote rune
+	inArg, escaped := false, false
+	for _, r := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+
+	return args
+}
@@ -0,0 +1,273 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym"
+	"github.com/rekurt/ymsdk/middleware"
+)
+
+// maxFloodBuckets bounds the number of per-sender buckets FloodControl keeps.
+const maxFloodBuckets = 10000
+
+// Middleware wraps a handler with cross-cutting behavior.
+type Middleware func(next Handler) Handler
+
+// PanicError is returned by Recover for a handler that panicked.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("yandex-messenger/bot: handler panicked: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+
+	return err
+}
+
+// Use adds middleware around every dispatch, including the fallback and the
+// not-handled hook. The first middleware is the outermost.
+func (r *Router) Use(mws ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.middleware = append(r.middleware, mws...)
+}
+
+// With adds middleware around the handler of a single route, inside the router middleware.
+func With(mws ...Middleware) RouteOption {
+	return func(rt *route) {
+		rt.handler = Chain(mws...)(rt.handler)
+	}
+}
+
+// Chain composes middleware into one. The first middleware is the outermost.
+func Chain(mws ...Middleware) Middleware {
+	return func(next Handler) Handler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			next = mws[i](next)
+		}
+
+		return next
+	}
+}
+
+// Wrap returns an updates.PollLoop handler calling h through mws.
+func Wrap(h Handler, mws ...Middleware) func(context.Context, ym.Update) error {
+	h = Chain(mws...)(h)
+
+	return func(ctx context.Context, u ym.Update) error {
+		return h(ctx, newUpdate(u))
+	}
+}
+
+// Recover turns a handler panic into a *PanicError, so that one bad update
+// does not crash the process.
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, u *Update) (err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					err = &PanicError{Value: v, Stack: debug.Stack()}
+				}
+			}()
+
+			return next(ctx, u)
+		}
+	}
+}
+
+// Logging logs every update with its kind, chat, sender, duration and error.
+// It sets a request id "update-<update_id>" with middleware.WithRequestID unless
+// the context already has one, so client logs of the handler share it.
+func Logging(logger ym.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, u *Update) error {
+			if middleware.RequestID(ctx) == "" {
+				ctx = middleware.WithRequestID(ctx, "update-"+strconv.FormatInt(u.UpdateID, 10))
+			}
+			started := time.Now()
+			err := next(ctx, u)
+
+			keyvals := []any{"update_id", u.UpdateID, "kind", string(u.Kind()), "duration", time.Since(started)}
+			if u.Chat != nil {
+				keyvals = append(keyvals, "chat_id", string(u.Chat.ID))
+			}
+			if u.From != nil {
+				keyvals = append(keyvals, "sender", string(u.From.Login))
+			}
+			if u.Command != "" {
+				keyvals = append(keyvals, "command", u.Command)
+			}
+			if err != nil {
+				logger.Log(ctx, ym.LevelError, "update handler failed", append(keyvals, "error", err)...)
+			} else {
+				logger.Log(ctx, ym.LevelInfo, "update handled", keyvals...)
+			}
+
+			return err
+		}
+	}
+}
+
+// Timing reports the duration and the error of every handler call.
+func Timing(observe func(u *Update, d time.Duration, err error)) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, u *Update) error {
+			started := time.Now()
+			err := next(ctx, u)
+			observe(u, time.Since(started), err)
+
+			return err
+		}
+	}
+}
+
+// Timeout cancels the handler context after d.
+func Timeout(d time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, u *Update) error {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+
+			return next(ctx, u)
+		}
+	}
+}
+
+// AllowUsers drops updates from senders other than logins.
+func AllowUsers(logins ...ym.UserLogin) Middleware {
+	return Filter(From(logins...))
+}
+
+// DenyUsers drops updates from logins.
+func DenyUsers(logins ...ym.UserLogin) Middleware {
+	return Filter(Not(From(logins...)))
+}
+
+// AllowChats drops updates from chats other than ids.
+func AllowChats(ids ...ym.ChatID) Middleware {
+	return Filter(InChat(ids...))
+}
+
+// DenyChats drops updates from ids.
+func DenyChats(ids ...ym.ChatID) Middleware {
+	return Filter(Not(InChat(ids...)))
+}
+
+// Filter drops updates not matching m: the handler is not called and nil is returned.
+func Filter(m Matcher) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, u *Update) error {
+			if !m(u) {
+				return nil
+			}
+
+			return next(ctx, u)
+		}
+	}
+}
+
+// FloodControl limits every sender, or chat for updates without a sender, to
+// rate updates per second with bursts of burst. Updates over the limit are
+// passed to onLimited, e.g. to answer "slow down", or dropped if it is nil.
+// Updates with neither a sender nor a chat are not limited. At most 10000
+// buckets are kept: idle ones are dropped first, then the least recently used.
+func FloodControl(rate float64, burst int, onLimited Handler) Middleware {
+	fc := &floodControl{rate: rate, burst: float64(max(burst, 1)), buckets: make(map[string]*floodBucket)}
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, u *Update) error {
+			if key, ok := floodKey(u); !ok || fc.allow(key, time.Now()) {
+				return next(ctx, u)
+			}
+			if onLimited != nil {
+				return onLimited(ctx, u)
+			}
+
+			return nil
+		}
+	}
+}
+
+type floodControl struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*floodBucket
+}
+
+type floodBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (f *floodControl) allow(key string, now time.Time) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, ok := f.buckets[key]
+	if !ok {
+		if len(f.buckets) >= maxFloodBuckets {
+			f.evict(now)
+		}
+		if len(f.buckets) >= maxFloodBuckets {
+			f.evictOldest()
+		}
+		b = &floodBucket{tokens: f.burst, last: now}
+		f.buckets[key] = b
+	}
+	b.tokens = min(f.burst, b.tokens+now.Sub(b.last).Seconds()*f.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+
+	return true
+}
+
+// evict drops buckets that have refilled completely. Callers must hold f.mu.
+func (f *floodControl) evict(now time.Time) {
+	for key, b := range f.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*f.rate >= f.burst {
+			delete(f.buckets, key)
+		}
+	}
+}
+
+// evictOldest drops the least recently used bucket. Callers must hold f.mu.
+func (f *floodControl) evictOldest() {
+	var (
+		oldest string
+		last   time.Time
+	)
+	for key, b := range f.buckets {
+		if last.IsZero() || b.last.Before(last) {
+			oldest, last = key, b.last
+		}
+	}
+	delete(f.buckets, oldest)
+}
+
+// floodKey returns the bucket key of u, or false if u has neither a sender nor a chat.
+func floodKey(u *Update) (string, bool) {
+	switch {
+	case u.From != nil && u.From.Login != "":
+		return "login:" + string(u.From.Login), true
+	case u.Chat != nil && u.Chat.ID != "":
+		return "chat:" + string(u.Chat.ID), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,188 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym"
+	"github.com/rekurt/ymsdk/middleware"
+)
+
+type recordLogger struct {
+	entries []string
+	ids     []string
+}
+
+func (l *recordLogger) Log(ctx context.Context, level ym.LogLevel, msg string, _ ...any) {
+	l.entries = append(l.entries, msg)
+	l.ids = append(l.ids, middleware.RequestID(ctx))
+}
+
+func tag(name string, order *[]string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, u *Update) error {
+			*order = append(*order, name)
+
+			return next(ctx, u)
+		}
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var order []string
+	r := NewRouter()
+	r.Use(tag("outer", &order), tag("inner", &order))
+	r.Command("start", func(context.Context, *Update) error {
+		order = append(order, "handler")
+
+		return nil
+	}, With(tag("route", &order)))
+	r.Fallback(func(context.Context, *Update) error {
+		order = append(order, "fallback")
+
+		return nil
+	})
+
+	if err := r.Dispatch(context.Background(), textUpdate("/start")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.Dispatch(context.Background(), textUpdate("hello")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "outer,inner,route,handler,outer,inner,fallback"
+	if got := strings.Join(order, ","); got != want {
+		t.Fatalf("unexpected order %s, want %s", got, want)
+	}
+}
+
+func TestRecoverAndLogging(t *testing.T) {
+	logger := &recordLogger{}
+	h := Wrap(func(context.Context, *Update) error {
+		panic("boom")
+	}, Logging(logger), Recover())
+
+	err := h(context.Background(), textUpdate("hi"))
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || panicErr.Value != "boom" || len(panicErr.Stack) == 0 {
+		t.Fatalf("expected panic error, got %v", err)
+	}
+	if len(logger.entries) != 1 || logger.entries[0] != "update handler failed" || logger.ids[0] != "update-1" {
+		t.Fatalf("unexpected logs %v %v", logger.entries, logger.ids)
+	}
+
+	ctx := middleware.WithRequestID(context.Background(), "req-7")
+	if err := Wrap(func(context.Context, *Update) error { return nil }, Logging(logger))(ctx, textUpdate("hi")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if logger.entries[1] != "update handled" || logger.ids[1] != "req-7" {
+		t.Fatalf("unexpected logs %v %v", logger.entries, logger.ids)
+	}
+}
+
+func TestTimeoutAndTiming(t *testing.T) {
+	var observed error
+	h := Wrap(func(ctx context.Context, _ *Update) error {
+		<-ctx.Done()
+
+		return ctx.Err()
+	}, Timing(func(_ *Update, d time.Duration, err error) {
+		observed = err
+	}), Timeout(10*time.Millisecond))
+
+	if err := h(context.Background(), textUpdate("hi")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline, got %v", err)
+	}
+	if !errors.Is(observed, context.DeadlineExceeded) {
+		t.Fatalf("timing observed %v", observed)
+	}
+}
+
+func TestAccessLists(t *testing.T) {
+	calls := 0
+	handler := func(context.Context, *Update) error {
+		calls++
+
+		return nil
+	}
+	cases := []struct {
+		mw   Middleware
+		want int
+	}{
+		{AllowUsers("alice@org"), 1},
+		{AllowUsers("bob@org"), 0},
+		{DenyUsers("alice@org"), 0},
+		{AllowChats("c1"), 1},
+		{DenyChats("c2"), 1},
+		{DenyChats("c1"), 0},
+	}
+	for i, tc := range cases {
+		calls = 0
+		if err := Wrap(handler, tc.mw)(context.Background(), textUpdate("hi")); err != nil {
+			t.Fatalf("case %d: unexpected error: %v", i, err)
+		}
+		if calls != tc.want {
+			t.Fatalf("case %d: handler called %d times, want %d", i, calls, tc.want)
+		}
+	}
+}
+
+func TestFloodControl(t *testing.T) {
+	calls, limited := 0, 0
+	h := Wrap(func(context.Context, *Update) error {
+		calls++
+
+		return nil
+	}, FloodControl(0.001, 2, func(context.Context, *Update) error {
+		limited++
+
+		return nil
+	}))
+
+	for range 3 {
+		_ = h(context.Background(), textUpdate("hi"))
+	}
+	other := textUpdate("hi")
+	other.From = &ym.Sender{Login: "bob@org"}
+	_ = h(context.Background(), other)
+
+	if calls != 3 || limited != 1 {
+		t.Fatalf("calls=%d limited=%d", calls, limited)
+	}
+}
+
+func TestFloodControlSkipsAnonymousUpdates(t *testing.T) {
+	calls := 0
+	h := Wrap(func(context.Context, *Update) error {
+		calls++
+
+		return nil
+	}, FloodControl(0.001, 1, nil))
+
+	for range 3 {
+		_ = h(context.Background(), ym.Update{UpdateID: 1, Text: "hi"})
+	}
+	if calls != 3 {
+		t.Fatalf("expected updates without sender and chat to pass, got %d calls", calls)
+	}
+}
+
+func TestFloodControlBoundsBuckets(t *testing.T) {
+	fc := &floodControl{rate: 0.001, burst: 1, buckets: make(map[string]*floodBucket)}
+	start := time.Now()
+	for i := range maxFloodBuckets + 10 {
+		fc.allow(fmt.Sprintf("login:%d", i), start.Add(time.Duration(i)*time.Millisecond))
+	}
+	if len(fc.buckets) != maxFloodBuckets {
+		t.Fatalf("expected %d buckets, got %d", maxFloodBuckets, len(fc.buckets))
+	}
+	if _, ok := fc.buckets["login:0"]; ok {
+		t.Fatal("expected the least recently used bucket to be dropped")
+	}
+	if _, ok := fc.buckets[fmt.Sprintf("login:%d", maxFloodBuckets+9)]; !ok {
+		t.Fatal("expected the newest bucket to be kept")
+	}
+}
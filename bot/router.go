@@ -0,0 +1,225 @@
+// Package bot routes updates to handlers.
+//
+// A Router matches every ym.Update against its routes, highest priority first
+// and in registration order within a priority, and calls the handler of the
+// first matching route. Routes match slash commands, regular expressions on
+// the text, content kinds, chat types, chats and senders, or any combination
+// of Matchers. Router.Dispatch has the signature of the updates.PollLoop handler
+// and Router implements http.Handler for webhooks, so the same routes serve both
+// modes:
+//
+//	r := bot.NewRouter()
+//	r.Command("start", start)
+//	r.Regex(`^order #(\d+)$`, showOrder)
+//	r.Kind(ym.UpdateKindImage, saveImage)
+//	err := svc.Updates.PollLoop(ctx, updates.GetUpdatesParams{}, r.Dispatch)
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/rekurt/ymsdk/client/ym"
+)
+
+// MaxWebhookBody is the largest webhook request body Router.ServeHTTP reads.
+const MaxWebhookBody = 4 << 20
+
+// Update is a ym.Update with the data extracted by the matchers.
+type Update struct {
+	ym.Update
+	// Command is the lower-cased slash command without the slash and a trailing
+	// @mention, e.g. "start" for "/Start@bot now". Empty if the text is not a command.
+	Command string
+	// RawArgs is the text after the command, trimmed.
+	RawArgs string
+	// Args are the command arguments split at whitespace; quoted arguments are kept together.
+	Args []string
+	// Matches holds the submatches of the Regex matcher of the route, if any.
+	Matches []string
+}
+
+// Handler handles a routed update.
+type Handler func(ctx context.Context, u *Update) error
+
+// RouteOption configures a route.
+type RouteOption func(*route)
+
+// Router dispatches updates to handlers. Routes may be added concurrently with dispatching.
+type Router struct {
+	mu         sync.RWMutex
+	routes     []*route
+	fallback   Handler
+	notHandled func(ctx context.Context, u ym.Update)
+	middleware []Middleware
+	seq        int
+}
+
+type route struct {
+	match    Matcher
+	handler  Handler
+	priority int
+	seq      int
+}
+
+// Priority sets the route priority. Routes with a higher priority are matched
+// first; the default is 0.
+func Priority(p int) RouteOption {
+	return func(r *route) {
+		r.priority = p
+	}
+}
+
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Handle registers h for updates matching m.
+func (r *Router) Handle(m Matcher, h Handler, opts ...RouteOption) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rt := &route{match: m, handler: h, seq: r.seq}
+	r.seq++
+	for _, opt := range opts {
+		opt(rt)
+	}
+	// Dispatch iterates its snapshot of r.routes without the lock, so the
+	// routes are copied rather than sorted in place.
+	routes := make([]*route, len(r.routes), len(r.routes)+1)
+	copy(routes, r.routes)
+	routes = append(routes, rt)
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].priority != routes[j].priority {
+			return routes[i].priority > routes[j].priority
+		}
+
+		return routes[i].seq < routes[j].seq
+	})
+	r.routes = routes
+}
+
+// Command registers h for the slash command name, e.g. "start" or "/start".
+func (r *Router) Command(name string, h Handler, opts ...RouteOption) {
+	r.Handle(Command(name), h, opts...)
+}
+
+// Regex registers h for texts matching pattern. It panics if pattern does not compile.
+func (r *Router) Regex(pattern string, h Handler, opts ...RouteOption) {
+	r.Handle(Regex(regexp.MustCompile(pattern)), h, opts...)
+}
+
+// Kind registers h for updates of the given kind.
+func (r *Router) Kind(kind ym.UpdateKind, h Handler, opts ...RouteOption) {
+	r.Handle(Kind(kind), h, opts...)
+}
+
+// ChatType registers h for updates from chats of type t.
+func (r *Router) ChatType(t ym.ChatType, h Handler, opts ...RouteOption) {
+	r.Handle(ChatType(t), h, opts...)
+}
+
+// Fallback sets the handler for updates matching no route.
+func (r *Router) Fallback(h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.fallback = h
+}
+
+// NotHandled sets a hook called for updates matching no route when there is no fallback.
+func (r *Router) NotHandled(hook func(ctx context.Context, u ym.Update)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.notHandled = hook
+}
+
+// Dispatch routes u to the first matching handler and returns its error.
+// Unmatched updates go to the fallback or the not-handled hook and return nil.
+func (r *Router) Dispatch(ctx context.Context, u ym.Update) error {
+	r.mu.RLock()
+	routes, fallback, notHandled, mws := r.routes, r.fallback, r.notHandled, r.middleware
+	r.mu.RUnlock()
+
+	dispatch := func(ctx context.Context, upd *Update) error {
+		for _, rt := range routes {
+			upd.Matches = nil
+			if rt.match(upd) {
+				return rt.handler(ctx, upd)
+			}
+		}
+		if fallback != nil {
+			return fallback(ctx, upd)
+		}
+		if notHandled != nil {
+			notHandled(ctx, upd.Update)
+		}
+
+		return nil
+	}
+
+	return Chain(mws...)(dispatch)(ctx, newUpdate(u))
+}
+
+// ServeHTTP receives webhook updates. The body is either a single update or an
+// object with an "updates" array, at most MaxWebhookBody bytes. Updates are
+// dispatched in order; a handler error stops the batch and answers 500 so that
+// the server delivers the request again. Delivery is therefore at least once:
+// the updates of the batch dispatched before the error are dispatched again on
+// redelivery, so handlers should tolerate repeated update IDs.
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, MaxWebhookBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+
+			return
+		}
+		http.Error(w, "read body", http.StatusBadRequest)
+
+		return
+	}
+	upds, err := decodeWebhook(body)
+	if err != nil {
+		http.Error(w, "decode updates", http.StatusBadRequest)
+
+		return
+	}
+	for _, u := range upds {
+		if err := r.Dispatch(req.Context(), u); err != nil {
+			http.Error(w, "handler failed", http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{"ok":true}`))
+}
+
+func decodeWebhook(body []byte) ([]ym.Update, error) {
+	var batch struct {
+		Updates []ym.Update `json:"updates"`
+	}
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return nil, err
+	}
+	if batch.Updates != nil {
+		return batch.Updates, nil
+	}
+
+	var u ym.Update
+	if err := json.Unmarshal(body, &u); err != nil {
+		return nil, err
+	}
+
+	return []ym.Update{u}, nil
+}
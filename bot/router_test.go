@@ -0,0 +1,195 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rekurt/ymsdk/client/ym"
+)
+
+func textUpdate(text string) ym.Update {
+	return ym.Update{
+		UpdateID:  1,
+		MessageID: 10,
+		Text:      text,
+		Chat:      &ym.Chat{ID: "c1", Type: ym.ChatTypeGroup},
+		From:      &ym.Sender{Login: "alice@org"},
+	}
+}
+
+func TestRouterCommandsAndArgs(t *testing.T) {
+	r := NewRouter()
+	var got *Update
+	r.Command("/deploy", func(_ context.Context, u *Update) error {
+		got = u
+
+		return nil
+	})
+
+	if err := r.Dispatch(context.Background(), textUpdate(`/Deploy@bot api "release 7" it\'s`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got == nil || got.Command != "deploy" || got.RawArgs != `api "release 7" it\'s` {
+		t.Fatalf("unexpected update: %+v", got)
+	}
+	if want := []string{"api", "release 7", "it's"}; !reflect.DeepEqual(got.Args, want) {
+		t.Fatalf("unexpected args %q", got.Args)
+	}
+	if got.Chat.ID != "c1" {
+		t.Fatalf("expected the original update, got %+v", got.Update)
+	}
+}
+
+func TestRouterPriorityFallbackAndHook(t *testing.T) {
+	r := NewRouter()
+	var calls []string
+	record := func(name string) Handler {
+		return func(context.Context, *Update) error {
+			calls = append(calls, name)
+
+			return nil
+		}
+	}
+	r.Regex(`^order #(\d+)$`, func(_ context.Context, u *Update) error {
+		calls = append(calls, "order "+u.Matches[1])
+
+		return nil
+	})
+	r.Kind(ym.UpdateKindText, record("text"))
+	r.Handle(And(From("boss@org"), Kind(ym.UpdateKindText)), record("boss"), Priority(10))
+	r.Handle(InChat("c2"), record("c2"))
+	r.ChatType(ym.ChatTypePrivate, record("private"))
+	r.Kind(ym.UpdateKindSticker, record("sticker"))
+
+	var unhandled []int64
+	r.NotHandled(func(_ context.Context, u ym.Update) { unhandled = append(unhandled, u.UpdateID) })
+
+	ctx := context.Background()
+	boss := textUpdate("order #1")
+	boss.From = &ym.Sender{Login: "boss@org"}
+	private := ym.Update{UpdateID: 5, Image: &ym.Image{}, Chat: &ym.Chat{ID: "p", Type: ym.ChatTypePrivate}}
+	inC2 := ym.Update{UpdateID: 6, Document: &ym.File{}, Chat: &ym.Chat{ID: "c2", Type: ym.ChatTypeGroup}}
+	unknown := ym.Update{UpdateID: 7, Image: &ym.Image{}, Chat: &ym.Chat{ID: "c3", Type: ym.ChatTypeGroup}}
+	for _, u := range []ym.Update{textUpdate("order #42"), textUpdate("hello"), boss, {Sticker: &ym.Sticker{}}, private, inC2, unknown} {
+		if err := r.Dispatch(ctx, u); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if want := []string{"order 42", "text", "boss", "sticker", "private", "c2"}; !reflect.DeepEqual(calls, want) {
+		t.Fatalf("unexpected calls %q", calls)
+	}
+	if !reflect.DeepEqual(unhandled, []int64{7}) {
+		t.Fatalf("unexpected not handled updates %v", unhandled)
+	}
+
+	r.Fallback(record("fallback"))
+	if err := r.Dispatch(ctx, unknown); err != nil || calls[len(calls)-1] != "fallback" {
+		t.Fatalf("expected fallback, got %q, %v", calls, err)
+	}
+}
+
+func TestRouterReturnsHandlerErrors(t *testing.T) {
+	r := NewRouter()
+	boom := errors.New("boom")
+	r.Handle(Regex(regexp.MustCompile("fail")), func(context.Context, *Update) error { return boom })
+	if err := r.Dispatch(context.Background(), textUpdate("please fail")); !errors.Is(err, boom) {
+		t.Fatalf("expected handler error, got %v", err)
+	}
+	if err := r.Dispatch(context.Background(), textUpdate("/start")); err != nil {
+		t.Fatalf("unexpected error for unmatched update: %v", err)
+	}
+}
+
+func TestRouterServesWebhook(t *testing.T) {
+	r := NewRouter()
+	var texts []string
+	r.Handle(Any(), func(_ context.Context, u *Update) error {
+		if u.Text == "fail" {
+			return errors.New("failed")
+		}
+		texts = append(texts, u.Text)
+
+		return nil
+	})
+
+	cases := []struct {
+		body   string
+		status int
+	}{
+		{`{"updates":[{"update_id":1,"text":"a"},{"update_id":2,"text":"b"}]}`, http.StatusOK},
+		{`{"update_id":3,"text":"c"}`, http.StatusOK},
+		{`{"update_id":4,"text":"fail"}`, http.StatusInternalServerError},
+		{`not json`, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(tc.body)))
+		if rec.Code != tc.status {
+			t.Fatalf("%s: expected %d, got %d", tc.body, tc.status, rec.Code)
+		}
+	}
+	if !reflect.DeepEqual(texts, []string{"a", "b", "c"}) {
+		t.Fatalf("unexpected texts %q", texts)
+	}
+
+	rec := httptest.NewRecorder()
+	big := `{"update_id":5,"text":"` + strings.Repeat("x", 1<<22) + `"}`
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(big)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for an oversized body, got %d", rec.Code)
+	}
+}
+
+func TestRouterHandleConcurrentWithDispatch(t *testing.T) {
+	r := NewRouter()
+	r.Handle(Any(), func(context.Context, *Update) error { return nil })
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if err := r.Dispatch(context.Background(), textUpdate("hello")); err != nil {
+				t.Errorf("dispatch: %v", err)
+			}
+		}
+	}()
+	for i := range 500 {
+		r.Handle(Command("never"), func(context.Context, *Update) error { return nil }, Priority(i%3))
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestCommandParsing(t *testing.T) {
+	cases := []struct {
+		text, command string
+		args          []string
+	}{
+		{"/start", "start", nil},
+		{"  /help   me\tplease ", "help", []string{"me", "please"}},
+		{"/usr/bin/env", "", nil},
+		{"/", "", nil},
+		{"not a /command", "", nil},
+		{`/say 'single \ quoted' "a \"b\""`, "say", []string{`single \ quoted`, `a "b"`}},
+	}
+	for _, tc := range cases {
+		u := newUpdate(ym.Update{Text: tc.text})
+		if u.Command != tc.command || !reflect.DeepEqual(u.Args, tc.args) {
+			t.Fatalf("%q: got command %q args %q", tc.text, u.Command, u.Args)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+// Package broadcast sends the same message to many chats and logins.
+//
+// A Broadcast renders a Template for every Recipient and sends the result with
+// bounded concurrency through a Sender, normally *messages.Service, so that the
+// client rate limiter, retry policy and interceptors apply to every send.
+// Recipients failing with transient errors are retried with backoff; permanent
+// failures are recorded and skipped. Every recipient ends up in the Report.
+package broadcast
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym"
+	"github.com/rekurt/ymsdk/client/ym/format"
+	"github.com/rekurt/ymsdk/client/ym/messages"
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+)
+
+const (
+	// DefaultConcurrency is the number of concurrent sends when Config.Concurrency is zero.
+	DefaultConcurrency = 8
+	// DefaultMaxAttempts is the number of attempts per recipient when Config.MaxAttempts is zero.
+	DefaultMaxAttempts = 3
+	// DefaultBackoff is the first retry delay when Config.Backoff is zero.
+	DefaultBackoff = time.Second
+
+	maxBackoff = 30 * time.Second
+)
+
+var (
+	// ErrCanceled is the cause reported when a broadcast is stopped with Cancel.
+	ErrCanceled = errors.New("yandex-messenger/broadcast: canceled")
+	// ErrStarted is returned when Run is called more than once.
+	ErrStarted = errors.New("yandex-messenger/broadcast: already started")
+)
+
+// Sender sends text messages. *messages.Service implements it.
+type Sender interface {
+	SendToChat(ctx context.Context, chatID ym.ChatID, text string, opts *messages.SendMessageOptions) (*ym.Message, error)
+	SendToLogin(ctx context.Context, login ym.UserLogin, text string, opts *messages.SendMessageOptions) (*ym.Message, error)
+}
+
+// Recipient is a chat or a login, with optional variables for the template.
+type Recipient struct {
+	ChatID ym.ChatID         `json:"chat_id,omitempty"`
+	Login  ym.UserLogin      `json:"login,omitempty"`
+	Vars   map[string]string `json:"vars,omitempty"`
+}
+
+// Template renders the message text for a recipient.
+type Template func(r Recipient) (string, error)
+
+type Config struct {
+	// ID identifies the broadcast. If set, every send gets the payload_id
+	// "<ID>:<recipient>", so that a rerun of the same broadcast is deduplicated by the server.
+	ID string
+	// Concurrency bounds the number of concurrent sends, DefaultConcurrency if zero.
+	Concurrency int
+	// MaxAttempts bounds the attempts per recipient, DefaultMaxAttempts if zero.
+	// Every attempt also goes through the client retry policy.
+	MaxAttempts int
+	// Backoff is the delay before the second attempt, doubled for every next one.
+	// Rate limited attempts wait for Retry-After instead.
+	Backoff time.Duration
+	// Options are applied to every message.
+	Options *messages.SendMessageOptions
+	// OnResult, if set, is called when a recipient is done. It may be called concurrently.
+	OnResult func(Result)
+}
+
+// Broadcast is a single fan-out run. It is safe for concurrent use.
+type Broadcast struct {
+	sender     Sender
+	recipients []Recipient
+	tmpl       Template
+	cfg        Config
+	stop       chan struct{}
+	stopOnce   sync.Once
+
+	mu      sync.Mutex
+	started bool
+	paused  bool
+	resume  chan struct{}
+	results []Result
+}
+
+// Chat returns a recipient for chatID.
+func Chat(chatID ym.ChatID) Recipient {
+	return Recipient{ChatID: chatID}
+}
+
+// Login returns a recipient for login.
+func Login(login ym.UserLogin) Recipient {
+	return Recipient{Login: login}
+}
+
+// String returns the chat id or the login of the recipient.
+func (r Recipient) String() string {
+	if r.ChatID != "" {
+		return "chat:" + string(r.ChatID)
+	}
+
+	return "login:" + string(r.Login)
+}
+
+// Text returns a template rendering the same text for every recipient.
+func Text(text string) Template {
+	return func(Recipient) (string, error) {
+		return text, nil
+	}
+}
+
+// ParseTemplate parses a text/template executed with the Recipient as data, e.g.
+// "Hi {{escape .Vars.name}}". The escape function escapes markup with format.Escape.
+func ParseTemplate(text string) (Template, error) {
+	t, err := template.New("broadcast").Funcs(template.FuncMap{"escape": format.Escape}).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("yandex-messenger/broadcast: parse template: %w", err)
+	}
+
+	return func(r Recipient) (string, error) {
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, r); err != nil {
+			return "", fmt.Errorf("yandex-messenger/broadcast: render template: %w", err)
+		}
+
+		return buf.String(), nil
+	}, nil
+}
+
+func New(sender Sender, recipients []Recipient, tmpl Template, cfg Config) *Broadcast {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = DefaultConcurrency
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultBackoff
+	}
+	results := make([]Result, len(recipients))
+	for i, r := range recipients {
+		results[i] = Result{Recipient: r, Status: StatusPending}
+	}
+
+	return &Broadcast{
+		sender:     sender,
+		recipients: recipients,
+		tmpl:       tmpl,
+		cfg:        cfg,
+		stop:       make(chan struct{}),
+		results:    results,
+	}
+}
+
+// Run sends the message to every recipient and blocks until all are done, the
+// broadcast is canceled or ctx is done. The report is returned in any case;
+// recipients that were not attempted are StatusCanceled. The error is non-nil
+// if the run was stopped early: the context error, ErrCanceled or the
+// authorization error that aborted the run.
+func (b *Broadcast) Run(ctx context.Context) (*Report, error) {
+	b.mu.Lock()
+	if b.started {
+		b.mu.Unlock()
+
+		return nil, ErrStarted
+	}
+	b.started = true
+	b.mu.Unlock()
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go func() {
+		select {
+		case <-b.stop:
+			cancel(ErrCanceled)
+		case <-ctx.Done():
+		}
+	}()
+
+	report := &Report{StartedAt: time.Now()}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(b.cfg.Concurrency, max(len(b.recipients), 1)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				b.deliver(ctx, cancel, i)
+			}
+		}()
+	}
+feed:
+	for i := range b.recipients {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	report.FinishedAt = time.Now()
+	report.Results = b.Results()
+	for i := range report.Results {
+		if report.Results[i].Status == StatusPending {
+			report.Results[i].Status = StatusCanceled
+		}
+	}
+
+	return report, context.Cause(ctx)
+}
+
+// Pause stops starting new attempts until Resume. Attempts in flight complete.
+func (b *Broadcast) Pause() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.paused {
+		b.paused = true
+		b.resume = make(chan struct{})
+	}
+}
+
+// Resume continues a paused broadcast.
+func (b *Broadcast) Resume() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.paused {
+		b.paused = false
+		close(b.resume)
+	}
+}
+
+// Paused reports whether the broadcast is paused.
+func (b *Broadcast) Paused() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.paused
+}
+
+// Cancel stops the broadcast. Attempts in flight are interrupted and Run returns ErrCanceled.
+func (b *Broadcast) Cancel() {
+	b.stopOnce.Do(func() { close(b.stop) })
+}
+
+// Results returns a snapshot of the per-recipient results, in recipient order.
+func (b *Broadcast) Results() []Result {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]Result(nil), b.results...)
+}
+
+func (b *Broadcast) deliver(ctx context.Context, abort context.CancelCauseFunc, i int) {
+	r := b.recipients[i]
+	res := Result{Recipient: r, Status: StatusPending}
+	defer func() {
+		b.mu.Lock()
+		b.results[i] = res
+		b.mu.Unlock()
+		if res.Status != StatusPending && b.cfg.OnResult != nil {
+			b.cfg.OnResult(res)
+		}
+	}()
+
+	if r.ChatID == "" && r.Login == "" {
+		res.fail(errors.New("yandex-messenger/broadcast: recipient has no chat_id or login"))
+
+		return
+	}
+	text, err := b.tmpl(r)
+	if err != nil {
+		res.fail(err)
+
+		return
+	}
+	opts := b.options(r)
+
+	for attempt := 1; ; attempt++ {
+		if err := b.waitResumed(ctx); err != nil {
+			return
+		}
+		res.Attempts = attempt
+
+		var msg *ym.Message
+		if r.ChatID != "" {
+			msg, err = b.sender.SendToChat(ctx, r.ChatID, text, opts)
+		} else {
+			msg, err = b.sender.SendToLogin(ctx, r.Login, text, opts)
+		}
+		if err == nil {
+			res.Status = StatusDelivered
+			if msg != nil {
+				res.MessageID = msg.ID
+			}
+
+			return
+		}
+		if ctx.Err() != nil {
+			// The recipient was interrupted; the message may or may not have been delivered.
+			res.fail(err)
+			res.Status = StatusCanceled
+
+			return
+		}
+		if kind := ymerrors.KindOf(err); kind == ymerrors.KindUnauthorized || kind == ymerrors.KindInvalidToken {
+			res.fail(err)
+			abort(fmt.Errorf("yandex-messenger/broadcast: aborted: %w", err))
+
+			return
+		}
+		if !ymerrors.IsTemporary(err) || attempt >= b.cfg.MaxAttempts {
+			res.fail(err)
+
+			return
+		}
+		if ym.Sleep(ctx, ym.RetryDelay(attempt, b.cfg.Backoff, maxBackoff, err)) != nil {
+			res.fail(err)
+			res.Status = StatusCanceled
+
+			return
+		}
+	}
+}
+
+func (b *Broadcast) options(r Recipient) *messages.SendMessageOptions {
+	var opts messages.SendMessageOptions
+	if b.cfg.Options != nil {
+		opts = *b.cfg.Options
+	}
+	if b.cfg.ID != "" {
+		opts.PayloadID = b.cfg.ID + ":" + r.String()
+		opts.IdempotencyKey = ""
+	}
+
+	return &opts
+}
+
+func (b *Broadcast) waitResumed(ctx context.Context) error {
+	b.mu.Lock()
+	paused, resume := b.paused, b.resume
+	b.mu.Unlock()
+	if !paused {
+		return ctx.Err()
+	}
+
+	select {
+	case <-resume:
+		return ctx.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
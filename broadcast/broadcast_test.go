@@ -0,0 +1,220 @@
+package broadcast
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym"
+	"github.com/rekurt/ymsdk/client/ym/messages"
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+)
+
+type sent struct {
+	to   string
+	text string
+	opts messages.SendMessageOptions
+}
+
+// fakeSender fails a recipient with the queued errors before delivering to it.
+type fakeSender struct {
+	mu     sync.Mutex
+	errs   map[string][]error
+	sent   []sent
+	nextID ym.MessageID
+	block  chan struct{}
+}
+
+func (f *fakeSender) SendToChat(ctx context.Context, chatID ym.ChatID, text string, opts *messages.SendMessageOptions) (*ym.Message, error) {
+	return f.send(ctx, "chat:"+string(chatID), text, opts)
+}
+
+func (f *fakeSender) SendToLogin(ctx context.Context, login ym.UserLogin, text string, opts *messages.SendMessageOptions) (*ym.Message, error) {
+	return f.send(ctx, "login:"+string(login), text, opts)
+}
+
+func (f *fakeSender) send(ctx context.Context, to, text string, opts *messages.SendMessageOptions) (*ym.Message, error) {
+	if f.block != nil {
+		select {
+		case <-f.block:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sent = append(f.sent, sent{to: to, text: text, opts: *opts})
+	if errs := f.errs[to]; len(errs) > 0 {
+		f.errs[to] = errs[1:]
+
+		return nil, errs[0]
+	}
+	f.nextID++
+
+	return &ym.Message{ID: f.nextID}, nil
+}
+
+func TestBroadcastDeliversAndReports(t *testing.T) {
+	sender := &fakeSender{errs: map[string][]error{
+		"chat:flaky": {&ymerrors.APIError{Kind: ymerrors.KindRateLimited, RetryAfter: time.Millisecond}},
+		"chat:gone":  {&ymerrors.APIError{Kind: ymerrors.KindBadRequest, HTTPStatus: 400, Description: "chat not found"}},
+	}}
+	tmpl, err := ParseTemplate("Hi {{escape .Vars.name}}")
+	if err != nil {
+		t.Fatalf("parse template: %v", err)
+	}
+	recipients := []Recipient{
+		{ChatID: "flaky", Vars: map[string]string{"name": "*team*"}},
+		Chat("gone"),
+		{Login: "bob@org", Vars: map[string]string{"name": "Bob"}},
+	}
+	var done int
+	var mu sync.Mutex
+	b := New(sender, recipients, tmpl, Config{
+		ID:          "release-7",
+		Concurrency: 2,
+		Backoff:     time.Millisecond,
+		OnResult: func(Result) {
+			mu.Lock()
+			done++
+			mu.Unlock()
+		},
+	})
+
+	report, err := b.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s := report.Summary(); s != (Summary{Total: 3, Delivered: 2, Failed: 1}) {
+		t.Fatalf("unexpected summary: %+v", s)
+	}
+	flaky, gone, bob := report.Results[0], report.Results[1], report.Results[2]
+	if flaky.Status != StatusDelivered || flaky.Attempts != 2 || flaky.MessageID == 0 {
+		t.Fatalf("unexpected flaky result: %+v", flaky)
+	}
+	if gone.Status != StatusFailed || gone.Attempts != 1 || gone.ErrorKind != "bad_request" {
+		t.Fatalf("unexpected gone result: %+v", gone)
+	}
+	if bob.Status != StatusDelivered || bob.Recipient.Login != "bob@org" {
+		t.Fatalf("unexpected bob result: %+v", bob)
+	}
+	if done != 3 {
+		t.Fatalf("expected 3 OnResult calls, got %d", done)
+	}
+	if failed := report.Failed(); len(failed) != 1 || failed[0].ChatID != "gone" {
+		t.Fatalf("unexpected failed recipients: %+v", failed)
+	}
+
+	for _, s := range sender.sent {
+		if s.to == "chat:flaky" && (s.text != `Hi \*team\*` || s.opts.PayloadID != "release-7:chat:flaky") {
+			t.Fatalf("unexpected send: %+v", s)
+		}
+	}
+
+	var jsonOut bytes.Buffer
+	if err := report.WriteJSON(&jsonOut); err != nil {
+		t.Fatalf("write json: %v", err)
+	}
+	var decoded struct {
+		Summary Summary  `json:"summary"`
+		Results []Result `json:"results"`
+	}
+	if err := json.Unmarshal(jsonOut.Bytes(), &decoded); err != nil {
+		t.Fatalf("decode json report: %v", err)
+	}
+	if decoded.Summary.Delivered != 2 || decoded.Results[1].Error == "" {
+		t.Fatalf("unexpected json report: %s", jsonOut.String())
+	}
+
+	var csvOut bytes.Buffer
+	if err := report.WriteCSV(&csvOut); err != nil {
+		t.Fatalf("write csv: %v", err)
+	}
+	rows, err := csv.NewReader(&csvOut).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if len(rows) != 4 || rows[2][0] != "gone" || rows[2][2] != "failed" || rows[3][1] != "bob@org" {
+		t.Fatalf("unexpected csv rows: %v", rows)
+	}
+}
+
+func TestBroadcastAbortsOnRejectedCredentials(t *testing.T) {
+	cases := []struct {
+		err  *ymerrors.APIError
+		want error
+	}{
+		{&ymerrors.APIError{Kind: ymerrors.KindUnauthorized, HTTPStatus: 401}, ymerrors.ErrUnauthorized},
+		{&ymerrors.APIError{Kind: ymerrors.KindInvalidToken, HTTPStatus: 403}, ymerrors.ErrInvalidToken},
+	}
+	for _, tc := range cases {
+		sender := &fakeSender{errs: map[string][]error{"chat:a": {tc.err}}}
+		b := New(sender, []Recipient{Chat("a"), Chat("b"), Chat("c")}, Text("hi"), Config{Concurrency: 1})
+
+		report, err := b.Run(context.Background())
+		if !errors.Is(err, tc.want) {
+			t.Fatalf("%d: expected %v, got %v", tc.err.HTTPStatus, tc.want, err)
+		}
+		if s := report.Summary(); s.Failed != 1 || s.Canceled != 2 {
+			t.Fatalf("%d: unexpected summary: %+v", tc.err.HTTPStatus, s)
+		}
+		if len(sender.sent) != 1 {
+			t.Fatalf("%d: expected a single send, got %d", tc.err.HTTPStatus, len(sender.sent))
+		}
+	}
+}
+
+func TestBroadcastPauseResumeCancel(t *testing.T) {
+	sender := &fakeSender{}
+	recipients := []Recipient{Chat("a"), Chat("b"), Chat("c")}
+	b := New(sender, recipients, Text("hi"), Config{Concurrency: 1})
+	b.Pause()
+
+	results := make(chan error, 1)
+	var report *Report
+	go func() {
+		var err error
+		report, err = b.Run(context.Background())
+		results <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	sender.mu.Lock()
+	if len(sender.sent) != 0 {
+		t.Fatalf("expected no sends while paused, got %d", len(sender.sent))
+	}
+	sender.mu.Unlock()
+
+	sender.block = make(chan struct{})
+	b.Resume()
+	if b.Paused() {
+		t.Fatal("expected broadcast to be resumed")
+	}
+	b.Cancel()
+
+	if err := <-results; !errors.Is(err, ErrCanceled) {
+		t.Fatalf("expected ErrCanceled, got %v", err)
+	}
+	if s := report.Summary(); s.Canceled != 3 {
+		t.Fatalf("unexpected summary: %+v", s)
+	}
+	if _, err := b.Run(context.Background()); !errors.Is(err, ErrStarted) {
+		t.Fatalf("expected ErrStarted, got %v", err)
+	}
+}
+
+func TestRecipientString(t *testing.T) {
+	if got := Chat("c1").String(); got != "chat:c1" {
+		t.Fatalf("unexpected %q", got)
+	}
+	if got := Login("a@b").String(); !strings.HasPrefix(got, "login:") {
+		t.Fatalf("unexpected %q", got)
+	}
+}
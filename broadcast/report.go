@@ -0,0 +1,122 @@
+package broadcast
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym"
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+)
+
+// Status is the delivery state of a recipient.
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusDelivered Status = "delivered"
+	StatusFailed    Status = "failed"
+	// StatusCanceled means the broadcast stopped before the recipient was done.
+	StatusCanceled Status = "canceled"
+)
+
+// Result is the delivery outcome for one recipient.
+type Result struct {
+	Recipient Recipient    `json:"recipient"`
+	Status    Status       `json:"status"`
+	MessageID ym.MessageID `json:"message_id,omitempty"`
+	// ErrorKind is the ymerrors.ErrorKind of the last error, e.g. "rate_limited".
+	ErrorKind string `json:"error_kind,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Attempts  int    `json:"attempts"`
+}
+
+// Summary counts the results by status.
+type Summary struct {
+	Total     int `json:"total"`
+	Delivered int `json:"delivered"`
+	Failed    int `json:"failed"`
+	Canceled  int `json:"canceled"`
+}
+
+// Report is the outcome of a broadcast run.
+type Report struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Results    []Result  `json:"results"`
+}
+
+func (r *Result) fail(err error) {
+	r.Status = StatusFailed
+	r.ErrorKind = ymerrors.KindOf(err).String()
+	r.Error = err.Error()
+}
+
+// Summary counts the results by status.
+func (r *Report) Summary() Summary {
+	s := Summary{Total: len(r.Results)}
+	for _, res := range r.Results {
+		switch res.Status {
+		case StatusDelivered:
+			s.Delivered++
+		case StatusFailed:
+			s.Failed++
+		case StatusCanceled, StatusPending:
+			s.Canceled++
+		}
+	}
+
+	return s
+}
+
+// Failed returns the recipients that failed, e.g. to retry them in a new broadcast.
+func (r *Report) Failed() []Recipient {
+	var failed []Recipient
+	for _, res := range r.Results {
+		if res.Status == StatusFailed {
+			failed = append(failed, res.Recipient)
+		}
+	}
+
+	return failed
+}
+
+// WriteJSON writes the report with its summary as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	out := struct {
+		*Report
+		Summary Summary `json:"summary"`
+	}{r, r.Summary()}
+	if err := enc.Encode(out); err != nil {
+		return fmt.Errorf("yandex-messenger/broadcast: write json report: %w", err)
+	}
+
+	return nil
+}
+
+// WriteCSV writes one row per recipient with a header row.
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"chat_id", "login", "status", "message_id", "attempts", "error_kind", "error"})
+	for _, res := range r.Results {
+		id := ""
+		if res.MessageID != 0 {
+			id = strconv.FormatInt(int64(res.MessageID), 10)
+		}
+		_ = cw.Write([]string{
+			string(res.Recipient.ChatID), string(res.Recipient.Login), string(res.Status),
+			id, strconv.Itoa(res.Attempts), res.ErrorKind, res.Error,
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("yandex-messenger/broadcast: write csv report: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,235 @@
+package ym
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+)
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerSettings configures when a circuit opens and how it recovers.
+type BreakerSettings struct {
+	// FailureThreshold is the number of consecutive failures that opens the circuit. Defaults to 5.
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before probing. Defaults to 30s.
+	OpenTimeout time.Duration
+	// HalfOpenProbes is the number of successful probes required to close the circuit. Defaults to 1.
+	HalfOpenProbes int
+}
+
+type CircuitBreakerConfig struct {
+	// Default applies to every endpoint without an override.
+	Default BreakerSettings
+	// Endpoints overrides Default for specific paths.
+	Endpoints map[string]BreakerSettings
+	// IsFailure reports whether an attempt error counts as a failure. By default
+	// network errors and 5xx responses (KindNetwork) do.
+	IsFailure func(error) bool
+	// OnStateChange is called after a circuit changes state.
+	OnStateChange func(endpoint string, from, to BreakerState)
+}
+
+// CircuitBreaker fails calls fast with ymerrors.ErrCircuitOpen while an endpoint keeps failing.
+// Circuits are tracked per endpoint path.
+type CircuitBreaker struct {
+	cfg      CircuitBreakerConfig
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	settings  BreakerSettings
+	state     BreakerState
+	failures  int
+	successes int
+	probes    int
+	openedAt  time.Time
+}
+
+func NewCircuitBreaker(cfg CircuitBreakerConfig) *CircuitBreaker {
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = isBreakerFailure
+	}
+
+	return &CircuitBreaker{
+		cfg:      cfg,
+		circuits: make(map[string]*circuit),
+	}
+}
+
+// Interceptor returns a per-attempt interceptor guarding every attempt with the breaker.
+func (b *CircuitBreaker) Interceptor() Interceptor {
+	return func(ctx context.Context, req *Request, next Invoker) (*http.Response, error) {
+		endpoint := req.Endpoint()
+		if !b.allow(endpoint) {
+			return nil, fmt.Errorf("yandex-messenger/client: %w for %s %s", ymerrors.ErrCircuitOpen, req.Method, endpoint)
+		}
+
+		resp, err := next(ctx, req)
+		if err != nil && ctx.Err() != nil {
+			b.release(endpoint)
+
+			return nil, err
+		}
+		b.record(endpoint, err == nil || !b.cfg.IsFailure(err))
+
+		return resp, err
+	}
+}
+
+// State returns the current state of the endpoint circuit.
+func (b *CircuitBreaker) State(endpoint string) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[endpoint]
+	if !ok {
+		return BreakerClosed
+	}
+	if c.state == BreakerOpen && time.Since(c.openedAt) >= c.settings.OpenTimeout {
+		return BreakerHalfOpen
+	}
+
+	return c.state
+}
+
+func (b *CircuitBreaker) allow(endpoint string) bool {
+	b.mu.Lock()
+	c := b.circuit(endpoint)
+	from := c.state
+
+	allowed := true
+	switch c.state {
+	case BreakerClosed:
+	case BreakerOpen:
+		if time.Since(c.openedAt) < c.settings.OpenTimeout {
+			allowed = false
+
+			break
+		}
+		c.state = BreakerHalfOpen
+		c.successes = 0
+		c.probes = 1
+	case BreakerHalfOpen:
+		if c.probes >= c.settings.HalfOpenProbes {
+			allowed = false
+
+			break
+		}
+		c.probes++
+	}
+	to := c.state
+	b.mu.Unlock()
+
+	b.notify(endpoint, from, to)
+
+	return allowed
+}
+
+// release gives back a half-open probe slot for an attempt that was cancelled by the caller.
+func (b *CircuitBreaker) release(endpoint string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(endpoint)
+	if c.state == BreakerHalfOpen && c.probes > 0 {
+		c.probes--
+	}
+}
+
+func (b *CircuitBreaker) record(endpoint string, success bool) {
+	b.mu.Lock()
+	c := b.circuit(endpoint)
+	from := c.state
+
+	switch {
+	case success && c.state == BreakerHalfOpen:
+		c.successes++
+		if c.successes >= c.settings.HalfOpenProbes {
+			c.state = BreakerClosed
+			c.failures = 0
+		}
+	case success:
+		c.failures = 0
+	case c.state == BreakerHalfOpen:
+		c.state = BreakerOpen
+		c.openedAt = time.Now()
+	default:
+		c.failures++
+		if c.state == BreakerClosed && c.failures >= c.settings.FailureThreshold {
+			c.state = BreakerOpen
+			c.openedAt = time.Now()
+		}
+	}
+	to := c.state
+	b.mu.Unlock()
+
+	b.notify(endpoint, from, to)
+}
+
+// circuit returns the circuit for endpoint, creating it if needed. Callers must hold b.mu.
+func (b *CircuitBreaker) circuit(endpoint string) *circuit {
+	if c, ok := b.circuits[endpoint]; ok {
+		return c
+	}
+
+	settings := b.cfg.Default
+	if override, ok := b.cfg.Endpoints[endpoint]; ok {
+		settings = override
+	}
+	if settings.FailureThreshold < 1 {
+		settings.FailureThreshold = 5
+	}
+	if settings.OpenTimeout <= 0 {
+		settings.OpenTimeout = 30 * time.Second
+	}
+	if settings.HalfOpenProbes < 1 {
+		settings.HalfOpenProbes = 1
+	}
+	c := &circuit{settings: settings}
+	b.circuits[endpoint] = c
+
+	return c
+}
+
+func (b *CircuitBreaker) notify(endpoint string, from, to BreakerState) {
+	if from != to && b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(endpoint, from, to)
+	}
+}
+
+func isBreakerFailure(err error) bool {
+	var apiErr *ymerrors.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Kind == ymerrors.KindNetwork
+	}
+	var netErr net.Error
+
+	return errors.As(err, &netErr)
+}
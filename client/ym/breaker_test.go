@@ -0,0 +1,121 @@
+package ym
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+	"github.com/rekurt/ymsdk/internal/testutil"
+)
+
+func TestCircuitBreakerOpensAndFailsFast(t *testing.T) {
+	var transitions []string
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{
+		Default: BreakerSettings{FailureThreshold: 2, OpenTimeout: time.Hour},
+		OnStateChange: func(endpoint string, from, to BreakerState) {
+			transitions = append(transitions, endpoint+":"+from.String()+"->"+to.String())
+		},
+	})
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			newResponse(http.StatusBadGateway, `{"ok":false}`, nil),
+			newResponse(http.StatusServiceUnavailable, `{"ok":false}`, nil),
+			newResponse(http.StatusOK, `{"ok":true}`, nil),
+		},
+	}
+	client := NewClientWithHTTP(Config{
+		BaseURL:        "http://example.com",
+		CircuitBreaker: breaker,
+	}, doer)
+
+	for range 2 {
+		if _, err := client.DoRequest(context.Background(), http.MethodGet, "/path?a=1", nil); !errors.Is(err, ymerrors.ErrNetworkError) {
+			t.Fatalf("expected network error, got %v", err)
+		}
+	}
+
+	_, err := client.DoRequest(context.Background(), http.MethodGet, "/path?a=2", nil)
+	if !errors.Is(err, ymerrors.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if len(doer.Requests) != 2 {
+		t.Fatalf("open circuit must not reach the transport, got %d requests", len(doer.Requests))
+	}
+	if breaker.State("/path") != BreakerOpen {
+		t.Fatalf("expected open state, got %v", breaker.State("/path"))
+	}
+	if breaker.State("/other") != BreakerClosed {
+		t.Fatalf("circuits must be tracked per endpoint")
+	}
+	if len(transitions) != 1 || transitions[0] != "/path:closed->open" {
+		t.Fatalf("unexpected transitions: %v", transitions)
+	}
+}
+
+func TestCircuitBreakerHalfOpenProbe(t *testing.T) {
+	var transitions []BreakerState
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{
+		Default: BreakerSettings{FailureThreshold: 1, OpenTimeout: time.Millisecond},
+		OnStateChange: func(_ string, _, to BreakerState) {
+			transitions = append(transitions, to)
+		},
+	})
+	invoke := breaker.Interceptor()
+	req := NewRequest(http.MethodGet, "/path")
+	fail := func(context.Context, *Request) (*http.Response, error) {
+		return nil, &ymerrors.APIError{Kind: ymerrors.KindNetwork, HTTPStatus: http.StatusBadGateway}
+	}
+	succeed := func(context.Context, *Request) (*http.Response, error) {
+		return newResponse(http.StatusOK, `{}`, nil), nil
+	}
+
+	_, _ = invoke(context.Background(), req, fail)
+	time.Sleep(5 * time.Millisecond)
+	if breaker.State("/path") != BreakerHalfOpen {
+		t.Fatalf("expected half-open after timeout, got %v", breaker.State("/path"))
+	}
+
+	_, _ = invoke(context.Background(), req, fail)
+	if breaker.State("/path") != BreakerOpen {
+		t.Fatalf("failed probe must reopen the circuit")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if _, err := invoke(context.Background(), req, succeed); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if breaker.State("/path") != BreakerClosed {
+		t.Fatalf("successful probe must close the circuit")
+	}
+
+	want := []BreakerState{BreakerOpen, BreakerHalfOpen, BreakerOpen, BreakerHalfOpen, BreakerClosed}
+	if len(transitions) != len(want) {
+		t.Fatalf("expected transitions %v, got %v", want, transitions)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Fatalf("expected transitions %v, got %v", want, transitions)
+		}
+	}
+}
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{
+		Default: BreakerSettings{FailureThreshold: 1, OpenTimeout: time.Hour},
+	})
+	invoke := breaker.Interceptor()
+	req := NewRequest(http.MethodPost, "/path")
+	badRequest := func(context.Context, *Request) (*http.Response, error) {
+		return nil, &ymerrors.APIError{Kind: ymerrors.KindBadRequest, HTTPStatus: http.StatusBadRequest}
+	}
+
+	for range 3 {
+		_, _ = invoke(context.Background(), req, badRequest)
+	}
+	if breaker.State("/path") != BreakerClosed {
+		t.Fatalf("4xx responses must not open the circuit")
+	}
+}
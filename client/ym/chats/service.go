@@ -2,7 +2,6 @@ package chats
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -54,8 +53,8 @@ func (s *Service) Create(ctx context.Context, req *ChatCreateRequest) (*ym.Chat,
 	defer resp.Body.Close()
 
 	var parsed chatCreateResponse
-	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-		return nil, fmt.Errorf("%w: decode create chat response: %w", ymerrors.ErrInvalidResponse, err)
+	if err := s.client.DecodeJSON(ctx, resp, &parsed, "create chat"); err != nil {
+		return nil, err
 	}
 	if !parsed.OK {
 		return nil, &ymerrors.APIError{
@@ -105,8 +104,8 @@ func (s *Service) UpdateMembers(ctx context.Context, req *ChatUpdateMembersReque
 	defer resp.Body.Close()
 
 	var parsed chatUpdateResponse
-	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-		return fmt.Errorf("%w: decode updateMembers response: %w", ymerrors.ErrInvalidResponse, err)
+	if err := s.client.DecodeJSON(ctx, resp, &parsed, "updateMembers"); err != nil {
+		return err
 	}
 	if !parsed.OK {
 		return &ymerrors.APIError{
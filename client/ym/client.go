@@ -1,16 +1,14 @@
 package ym
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rekurt/ymsdk/client/ym/ymerrors"
@@ -23,17 +21,55 @@ type HttpDoer interface {
 }
 
 type Config struct {
-	BaseURL       string
-	Token         string
+	BaseURL string
+	Token   string
+	// TokenProvider, if set, is consulted for the token before every attempt instead of Token.
+	TokenProvider TokenProvider
 	UpdatesMode   ymerrors.UpdatesMode
 	ErrorHandling ymerrors.ErrorHandlingConfig
+	// Interceptors wrap every logical API call, outside of the retry loop.
+	Interceptors []Interceptor
+	// AttemptInterceptors wrap every HTTP attempt, inside of the retry loop.
+	AttemptInterceptors []Interceptor
+	// RateLimiter, if set, paces every attempt before it is sent.
+	RateLimiter *RateLimiter
+	// CircuitBreaker, if set, fails attempts fast while an endpoint keeps failing.
+	CircuitBreaker *CircuitBreaker
+	// Logger, if set, receives retry, rate limit, decode and deprecation logs
+	// filtered by ErrorHandling.LoggingLevel. An unknown level is logged as a
+	// warning and treated as info.
+	Logger Logger
+}
+
+// String formats the config with the token redacted, so it is safe to log.
+func (c Config) String() string {
+	return fmt.Sprintf("%+v", c.redacted())
+}
+
+// GoString implements fmt.GoStringer so that %#v redacts the token as well.
+func (c Config) GoString() string {
+	return fmt.Sprintf("%#v", c.redacted())
+}
+
+func (c Config) redacted() redactedConfig {
+	if c.Token != "" {
+		c.Token = "[REDACTED]"
+	}
+
+	return redactedConfig(c)
 }
 
 type Client struct {
-	http HttpDoer
-	cfg  Config
+	http       HttpDoer
+	cfg        Config
+	invoke     Invoker
+	log        *levelLogger
+	deprecated sync.Map
 }
 
+// redactedConfig has the fields of Config but not its methods, avoiding recursion when formatting.
+type redactedConfig Config
+
 func NewClient(cfg Config) *Client {
 	httpClient := &http.Client{Timeout: 15 * time.Second}
 
@@ -43,99 +79,118 @@ func NewClient(cfg Config) *Client {
 func NewClientWithHTTP(cfg Config, httpClient HttpDoer) *Client {
 	cfg = applyDefaults(cfg)
 
-	return &Client{
+	c := &Client{
 		http: httpClient,
 		cfg:  cfg,
+		log:  newLevelLogger(cfg.Logger, cfg.ErrorHandling.LoggingLevel),
+	}
+
+	tokens := cfg.TokenProvider
+	if tokens == nil {
+		tokens = StaticToken(cfg.Token)
+	}
+	attempt := []Interceptor{TokenAuthInterceptor(tokens)}
+	if cfg.CircuitBreaker != nil {
+		attempt = append(attempt, cfg.CircuitBreaker.Interceptor())
 	}
+	if cfg.RateLimiter != nil {
+		attempt = append(attempt, cfg.RateLimiter.interceptor(c.log))
+	}
+	attempt = append(attempt, cfg.AttemptInterceptors...)
+	retry := NewRetryPolicy(cfg.ErrorHandling.RetryStrategy, cfg.ErrorHandling.RateLimitHandling)
+	retry.logger = c.log
+	call := append(append([]Interceptor(nil), cfg.Interceptors...), retry.Interceptor())
+	c.invoke = chain(call, chain(attempt, c.transport))
+
+	return c
 }
 
+// DoRequest sends body encoded as JSON through the client pipeline.
 func (c *Client) DoRequest(ctx context.Context, method, path string, body any) (*http.Response, error) {
-	var payload []byte
-	var err error
-	if body != nil {
-		payload, err = json.Marshal(body)
-		if err != nil {
-			return nil, fmt.Errorf("yandex-messenger/client: marshal request body: %w", err)
-		}
+	req, err := NewJSONRequest(method, path, body)
+	if err != nil {
+		return nil, err
 	}
 
-	url := strings.TrimRight(c.cfg.BaseURL, "/") + path
-	retryCfg := c.cfg.ErrorHandling.RetryStrategy
-	rateCfg := c.cfg.ErrorHandling.RateLimitHandling
+	return c.Do(ctx, req)
+}
 
-	attempts := retryCfg.MaxAttempts
-	if attempts < 1 {
-		attempts = 1
+// Do executes req through the interceptor chain: call interceptors, retry,
+// authorization and attempt interceptors. Every service routes its calls here.
+func (c *Client) Do(ctx context.Context, req *Request) (*http.Response, error) {
+	if req.Header == nil {
+		req.Header = http.Header{}
 	}
-	backoff := retryCfg.InitialBackoff
-	if backoff <= 0 {
-		backoff = 500 * time.Millisecond
+
+	return c.invoke(ctx, req)
+}
+
+func (c *Client) transport(ctx context.Context, req *Request) (*http.Response, error) {
+	ctx, tr := startTransfer(ctx, req)
+	resp, err := c.send(ctx, req, tr)
+	if tr != nil && (err != nil || resp.Body == nil) {
+		tr.finish()
 	}
 
-	for attempt := 1; attempt <= attempts; attempt++ {
-		var bodyReader io.Reader
-		if payload != nil {
-			bodyReader = bytes.NewReader(payload)
-		}
+	return resp, err
+}
 
-		req, reqErr := http.NewRequestWithContext(ctx, method, url, bodyReader)
-		if reqErr != nil {
-			return nil, fmt.Errorf("yandex-messenger/client: build request: %w", reqErr)
-		}
-		if c.cfg.Token != "" {
-			req.Header.Set("Authorization", "OAuth "+c.cfg.Token)
+func (c *Client) send(ctx context.Context, req *Request, tr *transfer) (*http.Response, error) {
+	var body io.ReadCloser
+	if req.GetBody != nil {
+		var err error
+		body, err = req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("yandex-messenger/client: open request body: %w", err)
 		}
-		req.Header.Set("Content-Type", "application/json")
-
-		resp, doErr := c.http.Do(req)
-		if doErr != nil {
-			if ctxErr := ctx.Err(); ctxErr != nil {
-				return nil, fmt.Errorf("yandex-messenger/client: %w for %s %s", ctxErr, method, path)
-			}
-			var netErr net.Error
-			if errors.As(doErr, &netErr) && retryCfg.RetryNetwork && attempt < attempts {
-				time.Sleep(backoff)
-				backoff = nextBackoff(backoff, retryCfg.MaxBackoff)
-
-				continue
-			}
-
-			return nil, fmt.Errorf("yandex-messenger/client: %w for %s %s", doErr, method, path)
+		if tr != nil {
+			body = tr.wrap(ctx, body, Upload, req.ContentLength)
 		}
+	}
 
-		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			return resp, nil
+	url := strings.TrimRight(c.cfg.BaseURL, "/") + req.Path
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, url, body)
+	if err != nil {
+		if body != nil {
+			body.Close()
 		}
 
-		apiErr, parseErr := c.newAPIError(method, path, resp)
-		if parseErr != nil {
-			return nil, parseErr
-		}
+		return nil, fmt.Errorf("yandex-messenger/client: build request: %w", err)
+	}
+	httpReq.Header = req.Header.Clone()
+	if body != nil && req.ContentLength > 0 {
+		httpReq.ContentLength = req.ContentLength
+	}
 
-		if apiErr.Kind == ymerrors.KindRateLimited && attempt < attempts {
-			sleep := rateCfg.DefaultBackoff
-			if rateCfg.UseRetryAfter && apiErr.RetryAfter > 0 {
-				sleep = apiErr.RetryAfter
-			}
-			if sleep <= 0 {
-				sleep = rateCfg.DefaultBackoff
-			}
-			time.Sleep(sleep)
-
-			continue
+	resp, doErr := c.http.Do(httpReq)
+	if doErr != nil {
+		if tr != nil && tr.stalled(ctx) {
+			return nil, fmt.Errorf("yandex-messenger/client: %w for %s %s", tr.err(ctx, doErr), req.Method, req.Path)
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("yandex-messenger/client: %w for %s %s", ctxErr, req.Method, req.Path)
 		}
 
-		if shouldRetryHTTP(apiErr.HTTPStatus, retryCfg.RetryHTTP) && attempt < attempts {
-			time.Sleep(backoff)
-			backoff = nextBackoff(backoff, retryCfg.MaxBackoff)
+		return nil, fmt.Errorf("yandex-messenger/client: %w for %s %s", doErr, req.Method, req.Path)
+	}
+	if resp == nil {
+		return nil, fmt.Errorf("%w: empty response for %s %s", ymerrors.ErrInvalidResponse, req.Method, req.Path)
+	}
 
-			continue
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if tr != nil && resp.Body != nil {
+			resp.Body = &downloadBody{progressReader: tr.wrap(ctx, resp.Body, Download, resp.ContentLength)}
 		}
 
-		return nil, apiErr
+		return resp, nil
 	}
 
-	return nil, fmt.Errorf("yandex-messenger/client: retries exhausted for %s %s", method, path)
+	apiErr, parseErr := c.newAPIError(req.Method, req.Endpoint(), resp)
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	return nil, apiErr
 }
 
 func (c *Client) newAPIError(method, path string, resp *http.Response) (*ymerrors.APIError, error) {
@@ -193,21 +248,58 @@ func (c *Client) newAPIError(method, path string, resp *http.Response) (*ymerror
 	}, nil
 }
 
+// DecodeJSON decodes a successful response body into dst. Decode failures are
+// logged and reported as ymerrors.ErrInvalidResponse; what names the call in the error.
+// The caller remains responsible for closing the body.
+func (c *Client) DecodeJSON(ctx context.Context, resp *http.Response, dst any, what string) error {
+	if err := json.NewDecoder(resp.Body).Decode(dst); err != nil {
+		c.log.Log(ctx, LevelError, "decode response failed",
+			"call", what, "status", resp.StatusCode, "request_id", getRequestID(resp.Header), "error", err,
+		)
+
+		return fmt.Errorf("%w: decode %s response: %w", ymerrors.ErrInvalidResponse, what, err)
+	}
+
+	return nil
+}
+
+// Logger returns the client logger, filtered by ErrorHandling.LoggingLevel. It is never nil.
+func (c *Client) Logger() Logger {
+	return c.log
+}
+
 // Config returns a copy of client configuration.
 func (c *Client) Config() Config {
 	return c.cfg
 }
 
 // HTTPDoer exposes the underlying HTTP transport used by the client.
+//
+// Deprecated: bypassing the client pipeline skips retries, authorization and
+// interceptors. Use Do with a Request instead.
 func (c *Client) HTTPDoer() HttpDoer {
+	c.deprecation("HTTPDoer", "use Client.Do")
+
 	return c.http
 }
 
 // NewAPIError wraps newAPIError for external users that need to parse raw responses.
+//
+// Deprecated: non-2xx responses are already returned as *ymerrors.APIError by Do.
 func (c *Client) NewAPIError(method, path string, resp *http.Response) (*ymerrors.APIError, error) {
+	c.deprecation("NewAPIError", "errors returned by Client.Do are already *ymerrors.APIError")
+
 	return c.newAPIError(method, path, resp)
 }
 
+// deprecation logs the use of a deprecated method once per client.
+func (c *Client) deprecation(method, hint string) {
+	if _, seen := c.deprecated.LoadOrStore(method, struct{}{}); seen {
+		return
+	}
+	c.log.Log(context.Background(), LevelWarn, "deprecated client method called", "method", method, "hint", hint)
+}
+
 func applyDefaults(cfg Config) Config {
 	if cfg.BaseURL == "" {
 		cfg.BaseURL = defaultBaseURL
@@ -242,28 +334,6 @@ func applyDefaults(cfg Config) Config {
 	return cfg
 }
 
-func nextBackoff(current, maximum time.Duration) time.Duration {
-	if current <= 0 {
-		current = 500 * time.Millisecond
-	}
-	next := current * 2
-	if maximum > 0 && next > maximum {
-		return maximum
-	}
-
-	return next
-}
-
-func shouldRetryHTTP(status int, list []int) bool {
-	for _, s := range list {
-		if status == s {
-			return true
-		}
-	}
-
-	return false
-}
-
 func parseRetryAfter(value string) time.Duration {
 	if value == "" {
 		return 0
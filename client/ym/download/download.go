@@ -0,0 +1,517 @@
+// Package download saves files received by the bot to disk.
+//
+// Downloads are written to a partial file next to the cache entry and renamed into
+// place only after the size is verified against the server-reported length.
+// An interrupted download is resumed with an HTTP Range request when the server
+// supports it. Completed files are kept in a cache directory keyed by file_id and
+// evicted by total size and age.
+package download
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym"
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+)
+
+const (
+	partSuffix = ".part"
+	metaSuffix = ".json"
+	// DefaultResumes is the number of times an interrupted download is resumed within one call.
+	DefaultResumes = 3
+)
+
+var (
+	// ErrSizeMismatch is returned when the downloaded size differs from the size reported by the server.
+	ErrSizeMismatch = errors.New("yandex-messenger/download: size mismatch")
+
+	// errRestart reports a partial file that could not be resumed and was discarded.
+	errRestart = errors.New("yandex-messenger/download: partial download discarded")
+	// errInterrupted reports a body read that failed midway; the partial file is kept for resuming.
+	errInterrupted = errors.New("yandex-messenger/download: transfer interrupted")
+)
+
+type Config struct {
+	// Dir is the cache directory. It is created if missing.
+	Dir string
+	// MaxSize bounds the total size of cached files in bytes; zero means unlimited.
+	MaxSize int64
+	// MaxAge evicts cached files not used for this long; zero keeps them forever.
+	MaxAge time.Duration
+	// Resumes is how many times an interrupted transfer is resumed within one call.
+	// Zero means DefaultResumes; a negative value disables resuming.
+	Resumes int
+	// StallTimeout aborts a transfer making no progress for this long.
+	StallTimeout time.Duration
+	// Progress receives download progress of every transfer.
+	Progress ym.ProgressFunc
+}
+
+// File is a downloaded file in the cache.
+type File struct {
+	FileID      string
+	Path        string
+	Size        int64
+	ContentType string
+	// Cached is true when the file was served from the cache without a request.
+	Cached bool
+}
+
+// Downloader fetches files through a client and caches them on disk.
+// It is safe for concurrent use; concurrent requests for one file_id share a download.
+type Downloader struct {
+	client *ym.Client
+	cfg    Config
+
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+type meta struct {
+	FileID      string `json:"file_id"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+}
+
+func New(client *ym.Client, cfg Config) (*Downloader, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("yandex-messenger/download: cache dir is required")
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("yandex-messenger/download: create cache dir: %w", err)
+	}
+	if cfg.Resumes == 0 {
+		cfg.Resumes = DefaultResumes
+	}
+
+	return &Downloader{client: client, cfg: cfg, locks: make(map[string]*keyLock)}, nil
+}
+
+// Get returns the cached file for fileID, downloading it first if needed.
+func (d *Downloader) Get(ctx context.Context, fileID string) (*File, error) {
+	if fileID == "" {
+		return nil, errors.New("file_id is required")
+	}
+	key := cacheKey(fileID)
+	unlock := d.lock(key)
+	defer unlock()
+
+	if f, ok := d.lookup(fileID, key); ok {
+		return f, nil
+	}
+
+	f, err := d.fetch(ctx, fileID, key)
+	if err != nil {
+		return nil, err
+	}
+	d.evict(key)
+
+	return f, nil
+}
+
+// SaveTo downloads fileID (or takes it from the cache) and atomically writes a copy to dst.
+func (d *Downloader) SaveTo(ctx context.Context, fileID, dst string) (*File, error) {
+	f, err := d.Get(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if err := copyFile(f.Path, dst); err != nil {
+		return nil, fmt.Errorf("yandex-messenger/download: save %s: %w", dst, err)
+	}
+
+	return f, nil
+}
+
+// Open returns a reader for fileID from the cache, downloading it first if needed.
+func (d *Downloader) Open(ctx context.Context, fileID string) (io.ReadCloser, *File, error) {
+	f, err := d.Get(ctx, fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := os.Open(f.Path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("yandex-messenger/download: open cached file: %w", err)
+	}
+
+	return r, f, nil
+}
+
+// Remove deletes fileID from the cache, including a partial download.
+func (d *Downloader) Remove(fileID string) error {
+	key := cacheKey(fileID)
+	unlock := d.lock(key)
+	defer unlock()
+
+	var errs []error
+	for _, suffix := range []string{"", metaSuffix, partSuffix} {
+		if err := os.Remove(d.path(key) + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (d *Downloader) lookup(fileID, key string) (*File, bool) {
+	path := d.path(key)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+	now := time.Now()
+	if d.cfg.MaxAge > 0 && now.Sub(info.ModTime()) > d.cfg.MaxAge {
+		return nil, false
+	}
+
+	var m meta
+	if data, err := os.ReadFile(path + metaSuffix); err == nil {
+		_ = json.Unmarshal(data, &m)
+	}
+	if m.Size != info.Size() {
+		return nil, false
+	}
+	// The modification time records the last use for age and size eviction.
+	_ = os.Chtimes(path, now, now)
+
+	return &File{FileID: fileID, Path: path, Size: info.Size(), ContentType: m.ContentType, Cached: true}, true
+}
+
+func (d *Downloader) fetch(ctx context.Context, fileID, key string) (*File, error) {
+	path := d.path(key)
+	var lastErr error
+	for attempt := 0; attempt <= max(d.cfg.Resumes, 0); attempt++ {
+		m, err := d.transfer(ctx, fileID, path+partSuffix)
+		if err == nil {
+			if err := writeMeta(path+metaSuffix, m); err != nil {
+				return nil, err
+			}
+			if err := os.Rename(path+partSuffix, path); err != nil {
+				return nil, fmt.Errorf("yandex-messenger/download: finalize: %w", err)
+			}
+
+			return &File{FileID: fileID, Path: path, Size: m.Size, ContentType: m.ContentType}, nil
+		}
+		lastErr = err
+		if !resumable(ctx, err) {
+			break
+		}
+	}
+
+	return nil, lastErr
+}
+
+// transfer appends to the partial file at part, resuming from its current size.
+func (d *Downloader) transfer(ctx context.Context, fileID, part string) (meta, error) {
+	m := meta{FileID: fileID}
+	f, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return m, fmt.Errorf("yandex-messenger/download: open partial file: %w", err)
+	}
+	defer f.Close()
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return m, fmt.Errorf("yandex-messenger/download: seek partial file: %w", err)
+	}
+
+	req := ym.NewRequest(http.MethodGet, "/bot/v1/messages/getFile/?file_id="+url.QueryEscape(fileID))
+	req.Progress = d.cfg.Progress
+	req.StallTimeout = d.cfg.StallTimeout
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+
+	resp, err := d.client.Do(ctx, req)
+	if err != nil {
+		var apiErr *ymerrors.APIError
+		if offset > 0 && errors.As(err, &apiErr) && apiErr.HTTPStatus == http.StatusRequestedRangeNotSatisfiable {
+			// The partial file is stale; start over on the next attempt.
+			_ = f.Truncate(0)
+
+			return m, errRestart
+		}
+
+		return m, err
+	}
+	defer resp.Body.Close()
+
+	m.ContentType = resp.Header.Get("Content-Type")
+	if strings.HasPrefix(m.ContentType, "application/json") {
+		return m, decodeError(ctx, d.client, resp)
+	}
+
+	total := int64(-1)
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		start, size, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			_ = f.Truncate(0)
+
+			return m, errRestart
+		}
+		total = size
+	default:
+		// The server ignored the range; rewrite the file from the beginning.
+		if err := f.Truncate(0); err != nil {
+			return m, fmt.Errorf("yandex-messenger/download: truncate partial file: %w", err)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return m, fmt.Errorf("yandex-messenger/download: seek partial file: %w", err)
+		}
+		offset = 0
+		if resp.ContentLength >= 0 {
+			total = resp.ContentLength
+		}
+	}
+
+	n, err := io.Copy(f, resp.Body)
+	if err != nil {
+		return m, fmt.Errorf("%w: %w", errInterrupted, err)
+	}
+	if err := f.Sync(); err != nil {
+		return m, fmt.Errorf("yandex-messenger/download: sync partial file: %w", err)
+	}
+
+	m.Size = offset + n
+	if total >= 0 && m.Size != total {
+		_ = f.Truncate(0)
+
+		return m, fmt.Errorf("%w: got %d bytes, expected %d", ErrSizeMismatch, m.Size, total)
+	}
+
+	return m, nil
+}
+
+// evict removes expired entries and then the least recently used ones until the cache fits MaxSize.
+// The entry just stored under keep is never removed.
+func (d *Downloader) evict(keep string) {
+	if d.cfg.MaxSize <= 0 && d.cfg.MaxAge <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(d.cfg.Dir)
+	if err != nil {
+		return
+	}
+
+	type cached struct {
+		key  string
+		size int64
+		used time.Time
+	}
+	var files []cached
+	var total int64
+	now := time.Now()
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasSuffix(name, metaSuffix) || strings.HasPrefix(name, ".") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if key, ok := strings.CutSuffix(name, partSuffix); ok {
+			// Abandoned partial downloads are dropped once they exceed MaxAge.
+			if d.cfg.MaxAge > 0 && now.Sub(info.ModTime()) > d.cfg.MaxAge {
+				d.removeEntry(key)
+			}
+
+			continue
+		}
+		if name != keep && d.cfg.MaxAge > 0 && now.Sub(info.ModTime()) > d.cfg.MaxAge {
+			d.removeEntry(name)
+
+			continue
+		}
+		files = append(files, cached{key: name, size: info.Size(), used: info.ModTime()})
+		total += info.Size()
+	}
+	if d.cfg.MaxSize <= 0 || total <= d.cfg.MaxSize {
+		return
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].used.Before(files[j].used) })
+	for _, f := range files {
+		if total <= d.cfg.MaxSize {
+			return
+		}
+		if f.key == keep {
+			continue
+		}
+		d.removeEntry(f.key)
+		total -= f.size
+	}
+}
+
+// removeEntry deletes an entry unless another goroutine is using it.
+func (d *Downloader) removeEntry(key string) {
+	d.mu.Lock()
+	_, busy := d.locks[key]
+	d.mu.Unlock()
+	if busy {
+		return
+	}
+	for _, suffix := range []string{"", metaSuffix, partSuffix} {
+		_ = os.Remove(d.path(key) + suffix)
+	}
+}
+
+func (d *Downloader) lock(key string) func() {
+	d.mu.Lock()
+	l, ok := d.locks[key]
+	if !ok {
+		l = &keyLock{}
+		d.locks[key] = l
+	}
+	l.refs++
+	d.mu.Unlock()
+
+	l.mu.Lock()
+
+	return func() {
+		l.mu.Unlock()
+		d.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(d.locks, key)
+		}
+		d.mu.Unlock()
+	}
+}
+
+func (d *Downloader) path(key string) string {
+	return filepath.Join(d.cfg.Dir, key)
+}
+
+func resumable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	return errors.Is(err, errRestart) || errors.Is(err, errInterrupted) || errors.Is(err, ErrSizeMismatch)
+}
+
+func decodeError(ctx context.Context, client *ym.Client, resp *http.Response) error {
+	var parsed struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := client.DecodeJSON(ctx, resp, &parsed, "getFile"); err != nil {
+		return err
+	}
+
+	return &ymerrors.APIError{
+		Kind:        ymerrors.KindBadRequest,
+		HTTPStatus:  resp.StatusCode,
+		Description: parsed.Description,
+		Method:      http.MethodGet,
+		Endpoint:    "/bot/v1/messages/getFile/",
+	}
+}
+
+// parseContentRange parses "bytes start-end/size".
+func parseContentRange(value string) (start, size int64, ok bool) {
+	spec, found := strings.CutPrefix(value, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	rng, total, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, false
+	}
+	first, _, found := strings.Cut(rng, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if total == "*" {
+		return start, -1, true
+	}
+	size, err = strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return start, size, true
+}
+
+func cacheKey(fileID string) string {
+	sum := sha256.Sum256([]byte(fileID))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func writeMeta(path string, m meta) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	return writeAtomic(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+
+		return err
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	return writeAtomic(dst, func(w io.Writer) error {
+		_, err := io.Copy(w, in)
+
+		return err
+	})
+}
+
+// writeAtomic writes through a temp file in the target directory and renames it into place.
+func writeAtomic(path string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,146 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym"
+)
+
+func newDownloader(t *testing.T, handler http.HandlerFunc, cfg Config) *Downloader {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := ym.NewClientWithHTTP(ym.Config{BaseURL: server.URL, Token: "t"}, server.Client())
+	if cfg.Dir == "" {
+		cfg.Dir = t.TempDir()
+	}
+	d, err := New(client, cfg)
+	if err != nil {
+		t.Fatalf("new downloader: %v", err)
+	}
+
+	return d
+}
+
+func serveContent(content []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}
+}
+
+func TestGetCachesByFileID(t *testing.T) {
+	content := []byte("attachment body")
+	var requests atomic.Int32
+	d := newDownloader(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Query().Get("file_id") != "f1" {
+			t.Errorf("unexpected file_id %q", r.URL.Query().Get("file_id"))
+		}
+		serveContent(content)(w, r)
+	}, Config{})
+
+	first, err := d.Get(context.Background(), "f1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := d.Get(context.Background(), "f1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if requests.Load() != 1 {
+		t.Fatalf("expected one request, got %d", requests.Load())
+	}
+	if first.Cached || !second.Cached || second.Path != first.Path {
+		t.Fatalf("unexpected cache state: %+v %+v", first, second)
+	}
+	data, _ := os.ReadFile(second.Path)
+	if !bytes.Equal(data, content) {
+		t.Fatalf("unexpected content %q", data)
+	}
+
+	dst := filepath.Join(t.TempDir(), "copy.bin")
+	if _, err := d.SaveTo(context.Background(), "f1", dst); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if data, _ := os.ReadFile(dst); !bytes.Equal(data, content) {
+		t.Fatalf("unexpected saved content %q", data)
+	}
+}
+
+func TestGetResumesInterruptedDownload(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	var ranges []string
+	var requests atomic.Int32
+	d := newDownloader(t, func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if requests.Add(1) == 1 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(content[:4000])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		serveContent(content)(w, r)
+	}, Config{})
+
+	f, err := d.Get(context.Background(), "big")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ranges) != 2 || ranges[1] != "bytes=4000-" {
+		t.Fatalf("expected resume from 4000, got ranges %q", ranges)
+	}
+	data, _ := os.ReadFile(f.Path)
+	if !bytes.Equal(data, content) || f.Size != int64(len(content)) {
+		t.Fatalf("resumed file differs: %d bytes", len(data))
+	}
+	if _, err := os.Stat(f.Path + partSuffix); !os.IsNotExist(err) {
+		t.Fatalf("expected partial file to be renamed, stat err %v", err)
+	}
+}
+
+func TestGetEvictsLeastRecentlyUsed(t *testing.T) {
+	d := newDownloader(t, serveContent(bytes.Repeat([]byte("x"), 100)), Config{MaxSize: 150})
+
+	old, err := d.Get(context.Background(), "old")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	past := time.Now().Add(-time.Hour)
+	_ = os.Chtimes(old.Path, past, past)
+
+	recent, err := d.Get(context.Background(), "recent")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(old.Path); !os.IsNotExist(err) {
+		t.Fatalf("expected old entry evicted, stat err %v", err)
+	}
+	if _, err := os.Stat(recent.Path); err != nil {
+		t.Fatalf("expected recent entry kept: %v", err)
+	}
+}
+
+func TestGetReturnsAPIError(t *testing.T) {
+	d := newDownloader(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":false,"description":"file not found"}`))
+	}, Config{})
+
+	if _, err := d.Get(context.Background(), "missing"); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -1,18 +1,11 @@
 package files
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"mime/multipart"
-	"net"
 	"net/http"
-	"net/textproto"
-	"strings"
-	"time"
+	"strconv"
 
 	"github.com/rekurt/ymsdk/client/ym"
 	"github.com/rekurt/ymsdk/client/ym/ymerrors"
@@ -29,6 +22,8 @@ func NewService(client *ym.Client) *Service {
 type SendFileOptions struct {
 	Caption  string
 	MimeType string
+	ThreadID *ym.ThreadID
+	ym.SendOptions
 }
 
 type sendFileResponse struct {
@@ -39,40 +34,51 @@ type sendFileResponse struct {
 func (s *Service) SendToChat(
 	ctx context.Context, chatID, filename, contentType string, data []byte, opts *SendFileOptions,
 ) (*ym.Message, error) {
-	fields := map[string]string{
-		"chat_id": chatID,
-	}
-
-	return s.send(ctx, fields, filename, contentType, data, opts)
+	return s.SendSourceToChat(ctx, chatID, filename, contentType, ym.BytesSource(data), opts)
 }
 
 func (s *Service) SendToLogin(
 	ctx context.Context, login, filename, contentType string, data []byte, opts *SendFileOptions,
 ) (*ym.Message, error) {
-	fields := map[string]string{
-		"login": login,
+	return s.SendSourceToLogin(ctx, login, filename, contentType, ym.BytesSource(data), opts)
+}
+
+// SendSourceToChat streams the file from source without buffering it in memory.
+func (s *Service) SendSourceToChat(
+	ctx context.Context, chatID, filename, contentType string, source *ym.UploadSource, opts *SendFileOptions,
+) (*ym.Message, error) {
+	req, err := buildRequest(ym.MultipartField{Name: "chat_id", Value: chatID}, filename, contentType, source, opts)
+	if err != nil {
+		return nil, err
 	}
+	req.ChatID = ym.ChatID(chatID)
 
-	return s.send(ctx, fields, filename, contentType, data, opts)
+	return s.send(ctx, req)
 }
 
-func (s *Service) send(
-	ctx context.Context, fields map[string]string, filename, contentType string, data []byte, opts *SendFileOptions,
+// SendSourceToLogin streams the file from source without buffering it in memory.
+func (s *Service) SendSourceToLogin(
+	ctx context.Context, login, filename, contentType string, source *ym.UploadSource, opts *SendFileOptions,
 ) (*ym.Message, error) {
-	body, boundaryContentType, err := buildMultipartBody(fields, filename, contentType, data, opts)
+	req, err := buildRequest(ym.MultipartField{Name: "login", Value: login}, filename, contentType, source, opts)
 	if err != nil {
-		return nil, fmt.Errorf("yandex-messenger/files: build multipart: %w", err)
+		return nil, err
 	}
+	req.Login = ym.UserLogin(login)
+
+	return s.send(ctx, req)
+}
 
-	resp, err := s.doMultipartWithRetry(ctx, http.MethodPost, "/bot/v1/messages/sendFile", boundaryContentType, body)
+func (s *Service) send(ctx context.Context, req *ym.Request) (*ym.Message, error) {
+	resp, err := s.client.Do(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	var parsed sendFileResponse
-	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-		return nil, fmt.Errorf("%w: decode sendFile response: %w", ymerrors.ErrInvalidResponse, err)
+	if err := s.client.DecodeJSON(ctx, resp, &parsed, "sendFile"); err != nil {
+		return nil, err
 	}
 	if !parsed.OK || parsed.Message == nil {
 		return nil, fmt.Errorf(
@@ -83,146 +89,38 @@ func (s *Service) send(
 	return parsed.Message, nil
 }
 
-func buildMultipartBody(
-	fields map[string]string, filename, contentType string, data []byte, opts *SendFileOptions,
-) ([]byte, string, error) {
-	var buf bytes.Buffer
-	writer := multipart.NewWriter(&buf)
-
-	for k, v := range fields {
-		if err := writer.WriteField(k, v); err != nil {
-			return nil, "", err
-		}
+func buildRequest(
+	recipient ym.MultipartField, filename, contentType string, source *ym.UploadSource, opts *SendFileOptions,
+) (*ym.Request, error) {
+	if source == nil {
+		return nil, errors.New("yandex-messenger/files: source is required")
 	}
 
+	fields := []ym.MultipartField{recipient}
 	if opts != nil && opts.Caption != "" {
-		if err := writer.WriteField("caption", opts.Caption); err != nil {
-			return nil, "", err
+		fields = append(fields, ym.MultipartField{Name: "caption", Value: opts.Caption})
+	}
+	if opts != nil && opts.ThreadID != nil {
+		fields = append(fields, ym.MultipartField{Name: "thread_id", Value: strconv.FormatInt(int64(*opts.ThreadID), 10)})
+	}
+	if opts != nil {
+		options, err := opts.Fields()
+		if err != nil {
+			return nil, fmt.Errorf("yandex-messenger/files: %w", err)
 		}
+		fields = append(fields, options...)
 	}
 
 	ct := contentType
 	if opts != nil && opts.MimeType != "" {
 		ct = opts.MimeType
 	}
+	file := ym.MultipartFile{Field: "document", Filename: filename, ContentType: ct, Source: source}
 
-	headers := textproto.MIMEHeader{}
-	headers.Set("Content-Disposition", fmt.Sprintf(`form-data; name="document"; filename="%s"`, filename))
-	if ct != "" {
-		headers.Set("Content-Type", ct)
-	}
-	part, err := writer.CreatePart(headers)
+	req, err := ym.NewMultipartRequest(http.MethodPost, "/bot/v1/messages/sendFile", fields, []ym.MultipartFile{file})
 	if err != nil {
-		return nil, "", err
-	}
-	if _, err := io.Copy(part, bytes.NewReader(data)); err != nil {
-		return nil, "", err
-	}
-
-	if err := writer.Close(); err != nil {
-		return nil, "", err
-	}
-
-	return buf.Bytes(), writer.FormDataContentType(), nil
-}
-
-func (s *Service) doMultipartWithRetry(
-	ctx context.Context, method, path, contentType string, body []byte,
-) (*http.Response, error) {
-	cfg := s.client.Config()
-	retryCfg := cfg.ErrorHandling.RetryStrategy
-	rateCfg := cfg.ErrorHandling.RateLimitHandling
-
-	attempts := retryCfg.MaxAttempts
-	if attempts < 1 {
-		attempts = 1
-	}
-	backoff := retryCfg.InitialBackoff
-	if backoff <= 0 {
-		backoff = 500 * time.Millisecond
-	}
-
-	url := strings.TrimRight(cfg.BaseURL, "/") + path
-
-	for attempt := 1; attempt <= attempts; attempt++ {
-		req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
-		if err != nil {
-			return nil, fmt.Errorf("yandex-messenger/files: build request: %w", err)
-		}
-		if token := cfg.Token; token != "" {
-			req.Header.Set("Authorization", "OAuth "+token)
-		}
-		req.Header.Set("Content-Type", contentType)
-
-		resp, doErr := s.client.HTTPDoer().Do(req)
-		if doErr != nil {
-			if ctxErr := ctx.Err(); ctxErr != nil {
-				return nil, fmt.Errorf("yandex-messenger/files: %w for %s %s", ctxErr, method, path)
-			}
-			var netErr net.Error
-			if errors.As(doErr, &netErr) && retryCfg.RetryNetwork && attempt < attempts {
-				time.Sleep(backoff)
-				backoff = nextBackoffFiles(backoff, retryCfg.MaxBackoff)
-
-				continue
-			}
-
-			return nil, fmt.Errorf("yandex-messenger/files: %w for %s %s", doErr, method, path)
-		}
-
-		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			return resp, nil
-		}
-
-		apiErr, parseErr := s.client.NewAPIError(method, path, resp)
-		if parseErr != nil {
-			return nil, parseErr
-		}
-
-		if apiErr.Kind == ymerrors.KindRateLimited && attempt < attempts {
-			sleep := rateCfg.DefaultBackoff
-			if rateCfg.UseRetryAfter && apiErr.RetryAfter > 0 {
-				sleep = apiErr.RetryAfter
-			}
-			if sleep <= 0 {
-				sleep = rateCfg.DefaultBackoff
-			}
-			time.Sleep(sleep)
-
-			continue
-		}
-
-		if shouldRetryHTTPFiles(apiErr.HTTPStatus, retryCfg.RetryHTTP) && attempt < attempts {
-			time.Sleep(backoff)
-			backoff = nextBackoffFiles(backoff, retryCfg.MaxBackoff)
-
-			continue
-		}
-
-		return nil, apiErr
-	}
-
-	return nil, fmt.Errorf("yandex-messenger/files: retries exhausted for %s %s", method, path)
-}
-
-func nextBackoffFiles(current, maximum time.Duration) time.Duration {
-	if current <= 0 {
-		current = 500 * time.Millisecond
-	}
-	next := current * 2
-	if maximum > 0 && next > maximum {
-		return maximum
-	}
-
-	return next
-}
-
-func shouldRetryHTTPFiles(status int, list []int) bool {
-	for _, s := range list {
-		if status == s {
-			return true
-		}
+		return nil, fmt.Errorf("yandex-messenger/files: build multipart: %w", err)
 	}
 
-	return false
+	return req, nil
 }
@@ -95,3 +95,36 @@ func newResponse(status int, body string) *http.Response {
 		Header:     http.Header{},
 	}
 }
+
+func TestSendSourceToChatStreamsFile(t *testing.T) {
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			newResponse(http.StatusOK, `{"ok":true,"message":{"message_id":2,"chat":{"id":"c1","type":"private"},"from":{"login":"u1"}}}`),
+		},
+	}
+	client := ym.NewClientWithHTTP(ym.Config{BaseURL: "http://example.com"}, doer)
+
+	svc := NewService(client)
+	source := ym.ReaderSource(strings.NewReader("streamed"))
+	thread := ym.ThreadID(3)
+	opts := &SendFileOptions{Caption: "hi", ThreadID: &thread, SendOptions: ym.SendOptions{DisableNotification: true, PayloadID: "f-1"}}
+	msg, err := svc.SendSourceToChat(context.Background(), "c1", "f.txt", "text/plain", source, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if msg.ID != 2 {
+		t.Fatalf("unexpected message id %d", msg.ID)
+	}
+
+	body, err := io.ReadAll(doer.Requests[0].Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	for _, want := range []string{
+		`name="chat_id"`, `name="caption"`, `name="thread_id"`, `name="disable_notification"`, `name="payload_id"`, "streamed", "Content-Type: text/plain",
+	} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Fatalf("expected %q in body: %s", want, body)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package format
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	headingRe  = regexp.MustCompile(`^ {0,3}#{1,6}(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	bulletRe   = regexp.MustCompile(`^( *)[-*+][ \t]+(.*)$`)
+	orderedRe  = regexp.MustCompile(`^( *)(\d{1,9})[.)][ \t]+(.*)$`)
+	quoteRe    = regexp.MustCompile(`^ {0,3}>[ \t]?(.*)$`)
+	ruleRe     = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	fenceRe    = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})[ \t]*([^`\\s]*)")
+	autolinkRe = regexp.MustCompile(`^<((?:https?|mailto):[^<>\s]+)>`)
+)
+
+// FromCommonMark converts CommonMark to the messenger markup. Emphasis, strong
+// emphasis, GFM strikethrough, code spans, fenced code blocks, links and
+// autolinks are translated; headings become bold lines, bullet lists use "•",
+// images become links and thematic breaks become a line of dashes. Other
+// constructs, including raw HTML, are kept as text.
+func FromCommonMark(md string) string {
+	lines := strings.Split(strings.ReplaceAll(md, "\r\n", "\n"), "\n")
+	out := make([]string, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if m := fenceRe.FindStringSubmatch(line); m != nil {
+			end := i + 1
+			for end < len(lines) && !closesFence(lines[end], m[1]) {
+				end++
+			}
+			out = append(out, Pre(m[2], strings.Join(lines[i+1:min(end, len(lines))], "\n")))
+			i = end
+
+			continue
+		}
+		out = append(out, convertLine(line))
+	}
+
+	return strings.Join(out, "\n")
+}
+
+func closesFence(line, open string) bool {
+	trimmed := strings.TrimSpace(line)
+
+	return strings.HasPrefix(trimmed, open[:3]) && strings.Trim(trimmed, open[:1]) == "" && len(trimmed) >= len(open)
+}
+
+func convertLine(line string) string {
+	switch {
+	case ruleRe.MatchString(line):
+		return "———"
+	case headingRe.MatchString(line):
+		text := headingRe.FindStringSubmatch(line)[1]
+		if text == "" {
+			return ""
+		}
+
+		return "**" + inline(text) + "**"
+	case quoteRe.MatchString(line):
+		return "> " + inline(quoteRe.FindStringSubmatch(line)[1])
+	case bulletRe.MatchString(line):
+		m := bulletRe.FindStringSubmatch(line)
+
+		return m[1] + "• " + inline(m[2])
+	case orderedRe.MatchString(line):
+		m := orderedRe.FindStringSubmatch(line)
+
+		return m[1] + m[2] + ". " + inline(m[3])
+	default:
+		return inline(line)
+	}
+}
+
+// inline converts the inline constructs of a line. Delimiters without a closing
+// counterpart are escaped so that they are shown as typed.
+func inline(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			if strings.IndexByte(specials, s[i+1]) >= 0 {
+				sb.WriteByte('\\')
+			}
+			sb.WriteByte(s[i+1])
+			i += 2
+		case c == '`':
+			n := runLen(s[i:], '`')
+			closing := strings.Index(s[i+n:], strings.Repeat("`", n))
+			if closing < 0 {
+				sb.WriteString(Escape(s[i : i+n]))
+				i += n
+
+				continue
+			}
+			code := s[i+n : i+n+closing]
+			if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' {
+				code = code[1 : len(code)-1]
+			}
+			sb.WriteString(Code(code))
+			i += n + closing + n
+		case c == '!' && strings.HasPrefix(s[i+1:], "["):
+			if text, url, n, ok := parseLink(s[i+1:]); ok {
+				sb.WriteString("[" + inline(text) + "](" + url + ")")
+				i += 1 + n
+
+				continue
+			}
+			sb.WriteByte(c)
+			i++
+		case c == '[':
+			if text, url, n, ok := parseLink(s[i:]); ok {
+				sb.WriteString("[" + inline(text) + "](" + url + ")")
+				i += n
+
+				continue
+			}
+			sb.WriteString(`\[`)
+			i++
+		case c == '<':
+			if m := autolinkRe.FindStringSubmatch(s[i:]); m != nil {
+				sb.WriteString(m[1])
+				i += len(m[0])
+
+				continue
+			}
+			sb.WriteByte(c)
+			i++
+		case c == '*' || c == '_' || c == '~':
+			n, out := emphasis(s, i)
+			sb.WriteString(out)
+			i += n
+		case strings.IndexByte(specials, c) >= 0:
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+			i++
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+
+	return sb.String()
+}
+
+// emphasis converts the delimiter run at s[i]. It returns the number of bytes
+// consumed and the converted text.
+func emphasis(s string, i int) (int, string) {
+	c := s[i]
+	n := runLen(s[i:], c)
+	if c == '~' && n != 2 {
+		return n, Escape(s[i : i+n])
+	}
+	size := 1
+	if n >= 2 {
+		size = 2
+	}
+	delim := s[i : i+size]
+	if !leftFlanking(s, i+size) || (c == '_' && wordBefore(s, i)) {
+		return n, Escape(s[i : i+n])
+	}
+
+	closing := findCloser(s, i+size, delim)
+	if closing < 0 {
+		return n, Escape(s[i : i+n])
+	}
+
+	marker := "_"
+	switch {
+	case c == '~':
+		marker = "~~"
+	case size == 2:
+		marker = "**"
+	}
+	prefix := Escape(s[i+size : i+n])
+
+	return closing + size - i, marker + prefix + inline(s[i+n:closing]) + marker
+}
+
+// findCloser returns the index of the first delim after from that can close emphasis, or -1.
+func findCloser(s string, from int, delim string) int {
+	for j := from; j < len(s); j++ {
+		if s[j] == '\\' {
+			j++
+
+			continue
+		}
+		if s[j] == '`' {
+			n := runLen(s[j:], '`')
+			if k := strings.Index(s[j+n:], strings.Repeat("`", n)); k >= 0 {
+				j += n + k + n - 1
+			}
+
+			continue
+		}
+		if !strings.HasPrefix(s[j:], delim) || j == from {
+			continue
+		}
+		if runLen(s[j:], delim[0]) != len(delim) && len(delim) == 2 {
+			continue
+		}
+		if rightFlanking(s, j) && !(delim[0] == '_' && wordAt(s, j+len(delim))) {
+			return j
+		}
+	}
+
+	return -1
+}
+
+// parseLink parses [text](url) at the start of s and returns the number of bytes consumed.
+func parseLink(s string) (string, string, int, bool) {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth > 0 {
+				continue
+			}
+			if i+1 >= len(s) || s[i+1] != '(' {
+				return "", "", 0, false
+			}
+			end := strings.IndexByte(s[i+2:], ')')
+			if end < 0 {
+				return "", "", 0, false
+			}
+			dest := strings.TrimSpace(s[i+2 : i+2+end])
+			// A link title is dropped.
+			if sp := strings.IndexAny(dest, " \t"); sp >= 0 {
+				dest = dest[:sp]
+			}
+			dest = strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">")
+
+			return s[1:i], dest, i + 3 + end, true
+		}
+	}
+
+	return "", "", 0, false
+}
+
+func leftFlanking(s string, end int) bool {
+	if end >= len(s) {
+		return false
+	}
+	next, _ := utf8.DecodeRuneInString(s[end:])
+
+	return !unicode.IsSpace(next)
+}
+
+func rightFlanking(s string, i int) bool {
+	if i == 0 {
+		return false
+	}
+	prev, _ := utf8.DecodeLastRuneInString(s[:i])
+
+	return !unicode.IsSpace(prev)
+}
+
+func wordBefore(s string, i int) bool {
+	if i <= 0 {
+		return false
+	}
+	r, _ := utf8.DecodeLastRuneInString(s[:i])
+
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func wordAt(s string, i int) bool {
+	if i >= len(s) {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(s[i:])
+
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isPunct(c byte) bool {
+	return c < utf8.RuneSelf && unicode.IsPunct(rune(c)) || strings.IndexByte("$+<=>^`|~", c) >= 0
+}
+
+func runLen(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+
+	return n
+}
@@ -0,0 +1,230 @@
+// Package format builds message text in the messenger markup.
+//
+// The markup is **bold**, _italic_, ~~strikethrough~~, `code`, fenced code blocks,
+// [links](https://example.com) and @login mentions. Helpers escape the content
+// they are given, so user-supplied strings cannot break the surrounding markup;
+// Raw is the only way to insert unescaped text.
+package format
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rekurt/ymsdk/client/ym"
+)
+
+// specials are the characters escaped with a backslash by Escape. '@' is
+// included so that plain text such as an e-mail address does not start a mention.
+const specials = "\\`*_~[]@|"
+
+// ErrTooLong is returned by Builder.Build when the text exceeds the builder limit.
+var ErrTooLong = errors.New("yandex-messenger/format: text too long")
+
+// Builder accumulates formatted text and tracks its length against a limit.
+// The zero value uses ym.MaxTextLength.
+type Builder struct {
+	sb    strings.Builder
+	limit int
+	runes int
+}
+
+// Escape escapes the markup characters of s with backslashes.
+func Escape(s string) string {
+	if !strings.ContainsAny(s, specials) {
+		return s
+	}
+	var sb strings.Builder
+	sb.Grow(len(s) + 8)
+	for _, r := range s {
+		if strings.ContainsRune(specials, r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
+
+func Bold(s string) string {
+	return "**" + Escape(s) + "**"
+}
+
+func Italic(s string) string {
+	return "_" + Escape(s) + "_"
+}
+
+func Strike(s string) string {
+	return "~~" + Escape(s) + "~~"
+}
+
+// Code formats s as inline code. Backticks cannot be escaped inside code and are
+// replaced with U+02CB, which looks the same.
+func Code(s string) string {
+	return "`" + strings.ReplaceAll(s, "`", "ˋ") + "`"
+}
+
+// Pre formats s as a fenced code block with an optional language. Fences inside
+// s are broken up with U+02CB, like backticks in Code.
+func Pre(lang, s string) string {
+	s = strings.ReplaceAll(strings.TrimRight(s, "\n"), "```", "``ˋ")
+	lang = strings.Map(func(r rune) rune {
+		if r == '`' || r == '\n' || r == ' ' {
+			return -1
+		}
+
+		return r
+	}, lang)
+
+	return "```" + lang + "\n" + s + "\n```"
+}
+
+// Link formats a link with escaped text. Closing parentheses and spaces in url
+// are percent-encoded so they do not end the link.
+func Link(text, url string) string {
+	url = strings.NewReplacer(")", "%29", "(", "%28", " ", "%20", "\n", "").Replace(url)
+
+	return "[" + Escape(text) + "](" + url + ")"
+}
+
+// Mention formats a mention of the user with login. The login is not escaped,
+// since escaped characters would keep the mention from resolving; a login that
+// is not letters, digits, '.' and '-' with an optional @domain of the same
+// characters is written as escaped plain text instead.
+func Mention(login ym.UserLogin) string {
+	if !validLogin(string(login)) {
+		return Escape("@" + string(login))
+	}
+
+	return "@" + string(login)
+}
+
+func validLogin(login string) bool {
+	name, domain, hasDomain := strings.Cut(login, "@")
+	if !validLoginPart(name) {
+		return false
+	}
+
+	return !hasDomain || validLoginPart(domain)
+}
+
+func validLoginPart(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.' && r != '-' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewBuilder creates a builder limited to limit runes, or ym.MaxTextLength if limit is not positive.
+func NewBuilder(limit int) *Builder {
+	return &Builder{limit: limit}
+}
+
+// Text appends s escaped.
+func (b *Builder) Text(s string) *Builder {
+	return b.Raw(Escape(s))
+}
+
+// Textf appends fmt.Sprintf(format, args...) escaped.
+func (b *Builder) Textf(format string, args ...any) *Builder {
+	return b.Text(fmt.Sprintf(format, args...))
+}
+
+// Raw appends markup as is.
+func (b *Builder) Raw(markup string) *Builder {
+	b.sb.WriteString(markup)
+	b.runes += utf8.RuneCountInString(markup)
+
+	return b
+}
+
+func (b *Builder) Bold(s string) *Builder {
+	return b.Raw(Bold(s))
+}
+
+func (b *Builder) Italic(s string) *Builder {
+	return b.Raw(Italic(s))
+}
+
+func (b *Builder) Strike(s string) *Builder {
+	return b.Raw(Strike(s))
+}
+
+func (b *Builder) Code(s string) *Builder {
+	return b.Raw(Code(s))
+}
+
+// Pre appends a code block on its own lines.
+func (b *Builder) Pre(lang, s string) *Builder {
+	if b.runes > 0 && !strings.HasSuffix(b.sb.String(), "\n") {
+		b.Line()
+	}
+
+	return b.Raw(Pre(lang, s)).Line()
+}
+
+func (b *Builder) Link(text, url string) *Builder {
+	return b.Raw(Link(text, url))
+}
+
+func (b *Builder) Mention(login ym.UserLogin) *Builder {
+	return b.Raw(Mention(login))
+}
+
+// Line ends the current line.
+func (b *Builder) Line() *Builder {
+	return b.Raw("\n")
+}
+
+// Len returns the length of the text in runes.
+func (b *Builder) Len() int {
+	return b.runes
+}
+
+// Limit returns the maximum length of the text in runes.
+func (b *Builder) Limit() int {
+	if b.limit <= 0 {
+		return ym.MaxTextLength
+	}
+
+	return b.limit
+}
+
+// Remaining returns the number of runes left before the limit, negative once it is exceeded.
+func (b *Builder) Remaining() int {
+	return b.Limit() - b.runes
+}
+
+// Fits reports whether markup can be appended without exceeding the limit.
+func (b *Builder) Fits(markup string) bool {
+	return utf8.RuneCountInString(markup) <= b.Remaining()
+}
+
+// String returns the text built so far, regardless of the limit.
+func (b *Builder) String() string {
+	return b.sb.String()
+}
+
+// Build returns the text, or ErrTooLong if it exceeds the limit. Longer texts can
+// be sent with messages.Service.SendChainToChat.
+func (b *Builder) Build() (string, error) {
+	if b.runes > b.Limit() {
+		return "", fmt.Errorf("%w: %d runes, limit %d", ErrTooLong, b.runes, b.Limit())
+	}
+
+	return b.sb.String(), nil
+}
+
+// Reset clears the text, keeping the limit.
+func (b *Builder) Reset() {
+	b.sb.Reset()
+	b.runes = 0
+}
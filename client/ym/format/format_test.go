@@ -0,0 +1,79 @@
+package format
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rekurt/ymsdk/client/ym"
+)
+
+func TestHelpersEscapeContent(t *testing.T) {
+	cases := []struct {
+		got, want string
+	}{
+		{Escape("a*b_c [x](y) @me"), `a\*b\_c \[x\](y) \@me`},
+		{Bold("**x**"), `**\*\*x\*\***`},
+		{Italic("snake_case"), `_snake\_case_`},
+		{Strike("~"), `~~\~~~`},
+		{Code("a`b*c"), "`aˋb*c`"},
+		{Pre("go lang", "x := 1\n```\n"), "```golang\nx := 1\n``ˋ\n```"},
+		{Link("[docs]", "https://x.y/a (b)"), `[\[docs\]](https://x.y/a%20%28b%29)`},
+		{Mention(ym.UserLogin("alice@org")), "@alice@org"},
+		{Mention(ym.UserLogin("ivan.petrov-2@example.org")), "@ivan.petrov-2@example.org"},
+		{Mention(ym.UserLogin("a_b*c@org")), `\@a\_b\*c\@org`},
+		{Mention(ym.UserLogin("a@b@c")), `\@a\@b\@c`},
+	}
+	for _, tc := range cases {
+		if tc.got != tc.want {
+			t.Fatalf("got %q, want %q", tc.got, tc.want)
+		}
+	}
+}
+
+func TestBuilderTracksLimit(t *testing.T) {
+	b := NewBuilder(20)
+	b.Text("Hi ").Mention("bob").Text(", ").Bold("ok")
+	text, err := b.Build()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text != "Hi @bob, **ok**" {
+		t.Fatalf("unexpected text %q", text)
+	}
+	if b.Remaining() != 5 || !b.Fits("12345") || b.Fits("123456") {
+		t.Fatalf("unexpected remaining %d", b.Remaining())
+	}
+
+	b.Pre("", "too long for the limit")
+	if _, err := b.Build(); !errors.Is(err, ErrTooLong) {
+		t.Fatalf("expected ErrTooLong, got %v", err)
+	}
+	if NewBuilder(0).Limit() != ym.MaxTextLength {
+		t.Fatal("expected default limit")
+	}
+}
+
+func TestFromCommonMark(t *testing.T) {
+	cases := []struct {
+		name, md, want string
+	}{
+		{"emphasis", "*em* and _em_ and **strong** and __strong__", "_em_ and _em_ and **strong** and **strong**"},
+		{"strike", "~~gone~~ ~x", `~~gone~~ \~x`},
+		{"intraword underscore", "snake_case_name", `snake\_case\_name`},
+		{"unclosed", "2 * 3 = 6 and **open", `2 \* 3 = 6 and \*\*open`},
+		{"code span", "run `a*b` and ``x`y``", "run `a*b` and `xˋy`"},
+		{"link", "[the *docs*](https://x.y \"title\") and <https://a.b>", "[the _docs_](https://x.y) and https://a.b"},
+		{"image", "![logo](https://x.y/l.png)", "[logo](https://x.y/l.png)"},
+		{"escapes", `\*not em\* @team`, `\*not em\* \@team`},
+		{"heading", "## Release *notes* ##", "**Release _notes_**"},
+		{"lists", "- one\n  * two\n3) three", "• one\n  • two\n3. three"},
+		{"quote and rule", "> quoted **text**\n\n---", "> quoted **text**\n\n———"},
+		{"fence", "```go\nx := a*b\n```\nafter", "```go\nx := a*b\n```\nafter"},
+		{"tilde fence", "~~~\n*raw*\n~~~", "```\n*raw*\n```"},
+	}
+	for _, tc := range cases {
+		if got := FromCommonMark(tc.md); got != tc.want {
+			t.Fatalf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileStore keeps records in a JSON file, rewritten atomically on every change.
+type FileStore struct {
+	mu      sync.Mutex
+	path    string
+	ttl     time.Duration
+	records map[string]Record
+}
+
+// NewFileStore opens or creates the store at path. Records older than ttl are
+// dropped; zero ttl keeps them forever.
+func NewFileStore(path string, ttl time.Duration) (*FileStore, error) {
+	s := &FileStore{
+		path:    path,
+		ttl:     ttl,
+		records: make(map[string]Record),
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return s, nil
+	case err != nil:
+		return nil, fmt.Errorf("yandex-messenger/idempotency: read store: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.records); err != nil {
+			return nil, fmt.Errorf("yandex-messenger/idempotency: decode store: %w", err)
+		}
+	}
+
+	now := time.Now()
+	for key, rec := range s.records {
+		if expired(rec, ttl, now) {
+			delete(s.records, key)
+		}
+	}
+
+	return s, nil
+}
+
+func (s *FileStore) Get(_ context.Context, key string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.records[key]
+	if !ok || expired(rec, s.ttl, time.Now()) {
+		return nil, nil
+	}
+
+	return &rec, nil
+}
+
+func (s *FileStore) Put(_ context.Context, rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[rec.Key] = rec
+
+	return s.flush()
+}
+
+func (s *FileStore) Reserve(_ context.Context, rec Record) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if existing, ok := s.records[rec.Key]; ok && !expired(existing, s.ttl, now) && !Reclaimable(existing, now) {
+		return &existing, nil
+	}
+	s.records[rec.Key] = rec
+
+	return nil, s.flush()
+}
+
+func (s *FileStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.records[key]; !ok {
+		return nil
+	}
+	delete(s.records, key)
+
+	return s.flush()
+}
+
+// flush writes all records to a temp file and renames it over the store. Callers must hold s.mu.
+func (s *FileStore) flush() error {
+	now := time.Now()
+	for key, rec := range s.records {
+		if expired(rec, s.ttl, now) {
+			delete(s.records, key)
+		}
+	}
+
+	data, err := json.Marshal(s.records)
+	if err != nil {
+		return fmt.Errorf("yandex-messenger/idempotency: encode store: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("yandex-messenger/idempotency: write store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+
+		return fmt.Errorf("yandex-messenger/idempotency: write store: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+
+		return fmt.Errorf("yandex-messenger/idempotency: write store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("yandex-messenger/idempotency: write store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("yandex-messenger/idempotency: write store: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+// Package idempotency tracks message sends by key so that retried sends are not delivered twice.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym"
+)
+
+type State string
+
+const (
+	// StatePending marks a send in flight.
+	StatePending State = "pending"
+	// StateUnknown marks a send whose outcome is unknown: the request may or may
+	// not have been delivered. The next send with the key resends it.
+	StateUnknown State = "unknown"
+	// StateDone marks a delivered send.
+	StateDone State = "done"
+)
+
+// PendingLease is how long a pending record blocks other sends with its key.
+// Older pending records are left by a crashed process and may be reclaimed.
+const PendingLease = 5 * time.Minute
+
+type Record struct {
+	Key       string      `json:"key"`
+	State     State       `json:"state"`
+	Message   *ym.Message `json:"message,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// Store persists send records. Get returns nil without error when the key is unknown.
+//
+// Reserve stores rec atomically unless a record that is not Reclaimable already
+// exists for its key; it returns that record, or nil if rec was stored.
+type Store interface {
+	Get(ctx context.Context, key string) (*Record, error)
+	Put(ctx context.Context, rec Record) error
+	Reserve(ctx context.Context, rec Record) (*Record, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Reclaimable reports whether rec may be replaced by a new send with its key:
+// its outcome is unknown, or it is pending for longer than PendingLease.
+func Reclaimable(rec Record, now time.Time) bool {
+	return rec.State == StateUnknown || rec.State == StatePending && now.Sub(rec.UpdatedAt) > PendingLease
+}
+
+// Key derives a stable idempotency key from the given payload parts.
+func Key(parts ...[]byte) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write(p)
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// MemoryStore keeps records in process memory.
+type MemoryStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	records map[string]Record
+}
+
+// NewMemoryStore creates an in-memory store. Records older than ttl are forgotten; zero ttl keeps them forever.
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		ttl:     ttl,
+		records: make(map[string]Record),
+	}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.records[key]
+	if !ok {
+		return nil, nil
+	}
+	if expired(rec, s.ttl, time.Now()) {
+		delete(s.records, key)
+
+		return nil, nil
+	}
+
+	return &rec, nil
+}
+
+func (s *MemoryStore) Put(_ context.Context, rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[rec.Key] = rec
+
+	return nil
+}
+
+func (s *MemoryStore) Reserve(_ context.Context, rec Record) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if existing, ok := s.records[rec.Key]; ok && !expired(existing, s.ttl, now) && !Reclaimable(existing, now) {
+		return &existing, nil
+	}
+	s.records[rec.Key] = rec
+
+	return nil, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+
+	return nil
+}
+
+func expired(rec Record, ttl time.Duration, now time.Time) bool {
+	return ttl > 0 && now.Sub(rec.CreatedAt) > ttl
+}
@@ -0,0 +1,115 @@
+package idempotency
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym"
+)
+
+func TestMemoryStoreTTL(t *testing.T) {
+	store := NewMemoryStore(time.Minute)
+	ctx := context.Background()
+
+	if err := store.Put(ctx, Record{Key: "k", State: StateDone, CreatedAt: time.Now().Add(-time.Hour)}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rec, err := store.Get(ctx, "k")
+	if err != nil || rec != nil {
+		t.Fatalf("expected expired record to be dropped, got %+v, %v", rec, err)
+	}
+}
+
+func TestFileStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idempotency.json")
+	ctx := context.Background()
+
+	store, err := NewFileStore(path, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Now()
+	if err := store.Put(ctx, Record{
+		Key: "k1", State: StateDone, Message: &ym.Message{ID: 42}, CreatedAt: now, UpdatedAt: now,
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Put(ctx, Record{Key: "k2", State: StatePending, CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Delete(ctx, "k2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, err := NewFileStore(path, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rec, err := reopened.Get(ctx, "k1")
+	if err != nil || rec == nil {
+		t.Fatalf("expected persisted record, got %+v, %v", rec, err)
+	}
+	if rec.State != StateDone || rec.Message == nil || rec.Message.ID != 42 {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+	if rec, _ := reopened.Get(ctx, "k2"); rec != nil {
+		t.Fatalf("expected deleted record to stay deleted")
+	}
+}
+
+func TestReserveIsExclusive(t *testing.T) {
+	ctx := context.Background()
+	file, err := NewFileStore(filepath.Join(t.TempDir(), "idempotency.json"), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, store := range map[string]Store{"memory": NewMemoryStore(0), "file": file} {
+		now := time.Now()
+		var reserved atomic.Int32
+		var wg sync.WaitGroup
+		for range 16 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				existing, err := store.Reserve(ctx, Record{Key: "k", State: StatePending, CreatedAt: now, UpdatedAt: now})
+				if err != nil {
+					t.Errorf("%s: unexpected error: %v", name, err)
+				}
+				if existing == nil {
+					reserved.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		if reserved.Load() != 1 {
+			t.Fatalf("%s: expected exactly one reservation, got %d", name, reserved.Load())
+		}
+
+		if err := store.Put(ctx, Record{Key: "k", State: StateUnknown, CreatedAt: now, UpdatedAt: now}); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if existing, _ := store.Reserve(ctx, Record{Key: "k", State: StatePending, CreatedAt: now, UpdatedAt: now}); existing != nil {
+			t.Fatalf("%s: expected an unknown record to be reclaimed, got %+v", name, existing)
+		}
+		stale := now.Add(-2 * PendingLease)
+		if err := store.Put(ctx, Record{Key: "s", State: StatePending, CreatedAt: stale, UpdatedAt: stale}); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if existing, _ := store.Reserve(ctx, Record{Key: "s", State: StatePending, CreatedAt: now, UpdatedAt: now}); existing != nil {
+			t.Fatalf("%s: expected a stale pending record to be reclaimed, got %+v", name, existing)
+		}
+	}
+}
+
+func TestKeyIsStable(t *testing.T) {
+	if Key([]byte("a"), []byte("b")) != Key([]byte("a"), []byte("b")) {
+		t.Fatalf("expected stable key")
+	}
+	if Key([]byte("ab")) == Key([]byte("a"), []byte("b")) {
+		t.Fatalf("expected parts to be separated")
+	}
+}
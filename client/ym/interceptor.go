@@ -0,0 +1,38 @@
+package ym
+
+import (
+	"context"
+	"net/http"
+)
+
+// Invoker executes a request and returns a successful (2xx) response or an error.
+// Non-2xx responses are reported as *ymerrors.APIError.
+type Invoker func(ctx context.Context, req *Request) (*http.Response, error)
+
+// Interceptor wraps request execution. It may inspect or modify the request,
+// call next any number of times, and inspect or replace the result.
+type Interceptor func(ctx context.Context, req *Request, next Invoker) (*http.Response, error)
+
+// Chain composes interceptors into a single one. The first interceptor is the outermost.
+func Chain(interceptors ...Interceptor) Interceptor {
+	return func(ctx context.Context, req *Request, next Invoker) (*http.Response, error) {
+		return chain(interceptors, next)(ctx, req)
+	}
+}
+
+// AuthInterceptor sets a static OAuth Authorization header on every attempt.
+func AuthInterceptor(token string) Interceptor {
+	return TokenAuthInterceptor(StaticToken(token))
+}
+
+func chain(interceptors []Interceptor, final Invoker) Invoker {
+	invoker := final
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(ctx context.Context, req *Request) (*http.Response, error) {
+			return interceptor(ctx, req, next)
+		}
+	}
+
+	return invoker
+}
@@ -0,0 +1,103 @@
+package ym
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+	"github.com/rekurt/ymsdk/internal/testutil"
+)
+
+func TestDoRunsInterceptorsInOrder(t *testing.T) {
+	var calls []string
+	record := func(name string) Interceptor {
+		return func(ctx context.Context, req *Request, next Invoker) (*http.Response, error) {
+			calls = append(calls, name)
+
+			return next(ctx, req)
+		}
+	}
+
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			newResponse(http.StatusBadGateway, `{"ok":false}`, nil),
+			newResponse(http.StatusOK, `{"ok":true}`, nil),
+		},
+	}
+	client := NewClientWithHTTP(Config{
+		BaseURL: "http://example.com",
+		Token:   "secret",
+		ErrorHandling: ymerrors.ErrorHandlingConfig{
+			RetryStrategy: ymerrors.RetryStrategy{
+				MaxAttempts:    2,
+				InitialBackoff: time.Millisecond,
+				MaxBackoff:     time.Millisecond,
+			},
+		},
+		Interceptors:        []Interceptor{record("call")},
+		AttemptInterceptors: []Interceptor{record("attempt")},
+	}, doer)
+
+	resp, err := client.DoRequest(context.Background(), http.MethodPost, "/path", map[string]string{"k": "v"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	want := []string{"call", "attempt", "attempt"}
+	if len(calls) != len(want) {
+		t.Fatalf("expected calls %v, got %v", want, calls)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Fatalf("expected calls %v, got %v", want, calls)
+		}
+	}
+	for _, req := range doer.Requests {
+		if got := req.Header.Get("Authorization"); got != "OAuth secret" {
+			t.Fatalf("expected auth header on every attempt, got %q", got)
+		}
+	}
+}
+
+func TestDoReplaysBodyOnRetry(t *testing.T) {
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			newResponse(http.StatusTooManyRequests, `{"ok":false}`, nil),
+			newResponse(http.StatusOK, `{"ok":true}`, nil),
+		},
+	}
+	client := NewClientWithHTTP(Config{
+		BaseURL: "http://example.com",
+		ErrorHandling: ymerrors.ErrorHandlingConfig{
+			RetryStrategy:     ymerrors.RetryStrategy{MaxAttempts: 2},
+			RateLimitHandling: ymerrors.RateLimitHandling{DefaultBackoff: time.Millisecond},
+		},
+	}, doer)
+
+	req := NewBytesRequest(http.MethodPost, "/upload", "multipart/form-data; boundary=x", []byte("payload"))
+	resp, err := client.Do(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if len(doer.Requests) != 2 {
+		t.Fatalf("expected two attempts, got %d", len(doer.Requests))
+	}
+	for _, r := range doer.Requests {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "payload" {
+			t.Fatalf("expected replayed body, got %q", body)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "multipart/form-data; boundary=x" {
+			t.Fatalf("unexpected content type: %s", ct)
+		}
+	}
+	if req.Attempt != 2 {
+		t.Fatalf("expected attempt counter 2, got %d", req.Attempt)
+	}
+}
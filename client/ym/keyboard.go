@@ -0,0 +1,219 @@
+package ym
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"unicode/utf8"
+)
+
+// Keyboard limits enforced before a request is sent.
+const (
+	MaxKeyboardRows     = 10
+	MaxRowButtons       = 8
+	MaxButtonTextLength = 128
+	MaxCallbackDataSize = 4096
+)
+
+// Button is a keyboard button. A button sends CallbackData back to the bot in an
+// update when pressed, or opens URL.
+type Button struct {
+	Text         string         `json:"text"`
+	CallbackData map[string]any `json:"callback_data,omitempty"`
+	URL          string         `json:"url,omitempty"`
+}
+
+// InlineKeyboard is a set of button rows attached to a message.
+type InlineKeyboard [][]Button
+
+// SuggestButtons are reply suggestions shown under the input field.
+type SuggestButtons struct {
+	Buttons [][]Button `json:"buttons"`
+	// Persist keeps the suggestions after one of them is used.
+	Persist bool `json:"persist,omitempty"`
+}
+
+// KeyboardBuilder builds keyboards row by row:
+//
+//	kb, err := ym.NewKeyboard().
+//		Callback("Yes", map[string]any{"answer": "yes"}).
+//		Callback("No", map[string]any{"answer": "no"}).
+//		Row().
+//		URL("Docs", "https://yandex.ru/dev/messenger/").
+//		Inline()
+type KeyboardBuilder struct {
+	rows    [][]Button
+	current []Button
+}
+
+func TextButton(text string) Button {
+	return Button{Text: text}
+}
+
+func CallbackButton(text string, data map[string]any) Button {
+	return Button{Text: text, CallbackData: data}
+}
+
+func URLButton(text, link string) Button {
+	return Button{Text: text, URL: link}
+}
+
+// Validate checks the button text, URL and callback payload size.
+func (b Button) Validate() error {
+	if b.Text == "" {
+		return errors.New("button text is required")
+	}
+	if n := utf8.RuneCountInString(b.Text); n > MaxButtonTextLength {
+		return fmt.Errorf("button text is %d characters, max %d", n, MaxButtonTextLength)
+	}
+	if b.URL != "" {
+		u, err := url.Parse(b.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("button %q url %q must be an absolute http(s) URL", b.Text, b.URL)
+		}
+	}
+	if b.CallbackData != nil {
+		data, err := json.Marshal(b.CallbackData)
+		if err != nil {
+			return fmt.Errorf("button %q callback data: %w", b.Text, err)
+		}
+		if len(data) > MaxCallbackDataSize {
+			return fmt.Errorf("button %q callback data is %d bytes, max %d", b.Text, len(data), MaxCallbackDataSize)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the keyboard layout and every button.
+func (k InlineKeyboard) Validate() error {
+	return validateRows(k)
+}
+
+// Validate checks the suggestion layout and every button.
+func (s *SuggestButtons) Validate() error {
+	return validateRows(s.Buttons)
+}
+
+func validateRows(rows [][]Button) error {
+	if len(rows) == 0 {
+		return errors.New("keyboard has no buttons")
+	}
+	if len(rows) > MaxKeyboardRows {
+		return fmt.Errorf("keyboard has %d rows, max %d", len(rows), MaxKeyboardRows)
+	}
+	for i, row := range rows {
+		if len(row) == 0 {
+			return fmt.Errorf("keyboard row %d is empty", i)
+		}
+		if len(row) > MaxRowButtons {
+			return fmt.Errorf("keyboard row %d has %d buttons, max %d", i, len(row), MaxRowButtons)
+		}
+		for _, b := range row {
+			if err := b.Validate(); err != nil {
+				return fmt.Errorf("keyboard row %d: %w", i, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func NewKeyboard() *KeyboardBuilder {
+	return &KeyboardBuilder{}
+}
+
+// Add appends buttons to the current row.
+func (kb *KeyboardBuilder) Add(buttons ...Button) *KeyboardBuilder {
+	kb.current = append(kb.current, buttons...)
+
+	return kb
+}
+
+func (kb *KeyboardBuilder) Text(text string) *KeyboardBuilder {
+	return kb.Add(TextButton(text))
+}
+
+func (kb *KeyboardBuilder) Callback(text string, data map[string]any) *KeyboardBuilder {
+	return kb.Add(CallbackButton(text, data))
+}
+
+func (kb *KeyboardBuilder) URL(text, link string) *KeyboardBuilder {
+	return kb.Add(URLButton(text, link))
+}
+
+// Row ends the current row; following buttons go to a new row.
+func (kb *KeyboardBuilder) Row() *KeyboardBuilder {
+	if len(kb.current) > 0 {
+		kb.rows = append(kb.rows, kb.current)
+		kb.current = nil
+	}
+
+	return kb
+}
+
+// Inline returns the validated inline keyboard.
+func (kb *KeyboardBuilder) Inline() (InlineKeyboard, error) {
+	rows := kb.build()
+	if err := validateRows(rows); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// Suggest returns the validated suggest buttons.
+func (kb *KeyboardBuilder) Suggest(persist bool) (*SuggestButtons, error) {
+	s := &SuggestButtons{Buttons: kb.build(), Persist: persist}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (kb *KeyboardBuilder) build() [][]Button {
+	rows := make([][]Button, 0, len(kb.rows)+1)
+	rows = append(rows, kb.rows...)
+	if len(kb.current) > 0 {
+		rows = append(rows, kb.current)
+	}
+
+	return rows
+}
+
+// KeyboardFields validates the keyboards and encodes them as multipart form fields.
+// Nil keyboards are skipped.
+func KeyboardFields(inline InlineKeyboard, suggest *SuggestButtons) ([]MultipartField, error) {
+	var fields []MultipartField
+	if inline != nil {
+		if err := inline.Validate(); err != nil {
+			return nil, fmt.Errorf("inline_keyboard: %w", err)
+		}
+		data, err := json.Marshal(inline)
+		if err != nil {
+			return nil, fmt.Errorf("inline_keyboard: %w", err)
+		}
+		fields = append(fields, MultipartField{Name: "inline_keyboard", Value: string(data)})
+	}
+	if suggest != nil {
+		if err := suggest.Validate(); err != nil {
+			return nil, fmt.Errorf("suggest_buttons: %w", err)
+		}
+		data, err := json.Marshal(suggest)
+		if err != nil {
+			return nil, fmt.Errorf("suggest_buttons: %w", err)
+		}
+		fields = append(fields, MultipartField{Name: "suggest_buttons", Value: string(data)})
+	}
+
+	return fields, nil
+}
+
+// ValidateKeyboards validates the keyboards of a JSON request. Nil keyboards are skipped.
+func ValidateKeyboards(inline InlineKeyboard, suggest *SuggestButtons) error {
+	_, err := KeyboardFields(inline, suggest)
+
+	return err
+}
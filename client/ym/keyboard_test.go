@@ -0,0 +1,69 @@
+package ym
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestKeyboardBuilderRows(t *testing.T) {
+	kb, err := NewKeyboard().
+		Callback("Yes", map[string]any{"answer": "yes"}).
+		Callback("No", map[string]any{"answer": "no"}).
+		Row().
+		URL("Docs", "https://example.com/docs").
+		Inline()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(kb) != 2 || len(kb[0]) != 2 || len(kb[1]) != 1 {
+		t.Fatalf("unexpected layout: %+v", kb)
+	}
+
+	data, err := json.Marshal(kb)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	want := `[[{"text":"Yes","callback_data":{"answer":"yes"}},{"text":"No","callback_data":{"answer":"no"}}],` +
+		`[{"text":"Docs","url":"https://example.com/docs"}]]`
+	if string(data) != want {
+		t.Fatalf("unexpected json:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestSuggestButtonsJSON(t *testing.T) {
+	s, err := NewKeyboard().Text("Hi").Text("Bye").Suggest(true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := json.Marshal(s)
+	if string(data) != `{"buttons":[[{"text":"Hi"},{"text":"Bye"}]],"persist":true}` {
+		t.Fatalf("unexpected json: %s", data)
+	}
+}
+
+func TestKeyboardValidation(t *testing.T) {
+	tooManyRows := NewKeyboard()
+	for range MaxKeyboardRows + 1 {
+		tooManyRows.Text("b").Row()
+	}
+	wideRow := NewKeyboard()
+	for range MaxRowButtons + 1 {
+		wideRow.Text("b")
+	}
+
+	cases := map[string]*KeyboardBuilder{
+		"no buttons": NewKeyboard(),
+		"rows":       tooManyRows,
+		"row 0 has":  wideRow,
+		"text":       NewKeyboard().Text(""),
+		"url":        NewKeyboard().URL("bad", "ftp://example.com"),
+		"callback":   NewKeyboard().Callback("big", map[string]any{"x": strings.Repeat("a", MaxCallbackDataSize)}),
+		"characters": NewKeyboard().Text(strings.Repeat("я", MaxButtonTextLength+1)),
+	}
+	for want, kb := range cases {
+		if _, err := kb.Inline(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected error containing %q, got %v", want, err)
+		}
+	}
+}
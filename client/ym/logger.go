@@ -0,0 +1,123 @@
+package ym
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// LogLevel is the severity of a client log entry.
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+	// LevelSilent disables client logging when used as a threshold.
+	LevelSilent
+)
+
+// Logger receives structured log entries from the client. Keyvals are alternating
+// keys and values, as in log/slog. Implementations must be safe for concurrent use.
+type Logger interface {
+	Log(ctx context.Context, level LogLevel, msg string, keyvals ...any)
+}
+
+// LoggerFunc adapts a function to Logger.
+type LoggerFunc func(ctx context.Context, level LogLevel, msg string, keyvals ...any)
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// levelLogger drops entries below the configured threshold.
+type levelLogger struct {
+	logger    Logger
+	threshold LogLevel
+}
+
+func (f LoggerFunc) Log(ctx context.Context, level LogLevel, msg string, keyvals ...any) {
+	f(ctx, level, msg, keyvals...)
+}
+
+func (l LogLevel) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	case LevelSilent:
+		return "silent"
+	default:
+		return fmt.Sprintf("LogLevel(%d)", int(l))
+	}
+}
+
+// ParseLogLevel parses ErrorHandlingConfig.LoggingLevel. An empty string means info.
+func ParseLogLevel(s string) (LogLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LevelDebug, nil
+	case "", "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	case "silent", "off", "none":
+		return LevelSilent, nil
+	default:
+		return LevelInfo, fmt.Errorf("yandex-messenger/client: unknown logging level %q", s)
+	}
+}
+
+// NewSlogLogger adapts a *slog.Logger. A nil logger uses slog.Default().
+func NewSlogLogger(logger *slog.Logger) Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return slogLogger{logger: logger}
+}
+
+func (l slogLogger) Log(ctx context.Context, level LogLevel, msg string, keyvals ...any) {
+	var lvl slog.Level
+	switch level {
+	case LevelDebug:
+		lvl = slog.LevelDebug
+	case LevelInfo:
+		lvl = slog.LevelInfo
+	case LevelWarn:
+		lvl = slog.LevelWarn
+	default:
+		lvl = slog.LevelError
+	}
+	l.logger.Log(ctx, lvl, msg, keyvals...)
+}
+
+// newLevelLogger filters logger by level. An unknown level falls back to info
+// with a warning, since NewClient cannot fail; config.Validate rejects it.
+func newLevelLogger(logger Logger, level string) *levelLogger {
+	if logger == nil {
+		return &levelLogger{threshold: LevelSilent}
+	}
+	threshold, err := ParseLogLevel(level)
+	if err != nil {
+		logger.Log(context.Background(), LevelWarn, "unknown logging level, using info", "level", level)
+	}
+
+	return &levelLogger{logger: logger, threshold: threshold}
+}
+
+func (l *levelLogger) Log(ctx context.Context, level LogLevel, msg string, keyvals ...any) {
+	if l == nil || level < l.threshold || l.threshold >= LevelSilent {
+		return
+	}
+	l.logger.Log(ctx, level, msg, keyvals...)
+}
@@ -0,0 +1,138 @@
+package ym
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+	"github.com/rekurt/ymsdk/internal/testutil"
+)
+
+type logEntry struct {
+	level LogLevel
+	msg   string
+}
+
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (l *recordingLogger) Log(_ context.Context, level LogLevel, msg string, _ ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, logEntry{level: level, msg: msg})
+}
+
+func (l *recordingLogger) count(msg string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, e := range l.entries {
+		if e.msg == msg {
+			n++
+		}
+	}
+
+	return n
+}
+
+func TestClientLogsRetriesAndDecodeFailures(t *testing.T) {
+	logger := &recordingLogger{}
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			newResponse(http.StatusBadGateway, `{"ok":false}`, nil),
+			newResponse(http.StatusOK, `not json`, nil),
+		},
+	}
+	client := NewClientWithHTTP(Config{
+		BaseURL: "http://example.com",
+		Logger:  logger,
+		ErrorHandling: ymerrors.ErrorHandlingConfig{
+			RetryStrategy: ymerrors.RetryStrategy{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+			LoggingLevel:  "debug",
+		},
+	}, doer)
+
+	resp, err := client.DoRequest(context.Background(), http.MethodGet, "/path", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var dst struct{}
+	err = client.DecodeJSON(context.Background(), resp, &dst, "test")
+	if !errors.Is(err, ymerrors.ErrInvalidResponse) {
+		t.Fatalf("expected ErrInvalidResponse, got %v", err)
+	}
+	if logger.count("retrying request") != 1 {
+		t.Fatalf("expected one retry log, got %+v", logger.entries)
+	}
+	if logger.count("decode response failed") != 1 {
+		t.Fatalf("expected one decode log, got %+v", logger.entries)
+	}
+}
+
+func TestClientLogLevelFilters(t *testing.T) {
+	logger := &recordingLogger{}
+	client := NewClientWithHTTP(Config{
+		Logger:        logger,
+		ErrorHandling: ymerrors.ErrorHandlingConfig{LoggingLevel: "error"},
+	}, &testutil.FakeDoer{})
+
+	client.Logger().Log(context.Background(), LevelWarn, "dropped")
+	client.Logger().Log(context.Background(), LevelError, "kept")
+	if logger.count("dropped") != 0 || logger.count("kept") != 1 {
+		t.Fatalf("unexpected entries: %+v", logger.entries)
+	}
+
+	silent := NewClientWithHTTP(Config{
+		Logger:        logger,
+		ErrorHandling: ymerrors.ErrorHandlingConfig{LoggingLevel: "silent"},
+	}, &testutil.FakeDoer{})
+	silent.Logger().Log(context.Background(), LevelError, "silenced")
+	if logger.count("silenced") != 0 {
+		t.Fatalf("expected silent level to drop entries")
+	}
+}
+
+func TestClientWarnsAboutUnknownLogLevel(t *testing.T) {
+	logger := &recordingLogger{}
+	client := NewClientWithHTTP(Config{
+		Logger:        logger,
+		ErrorHandling: ymerrors.ErrorHandlingConfig{LoggingLevel: "degub"},
+	}, &testutil.FakeDoer{})
+
+	if n := logger.count("unknown logging level, using info"); n != 1 {
+		t.Fatalf("expected one warning, got %d", n)
+	}
+	client.Logger().Log(context.Background(), LevelDebug, "debug")
+	client.Logger().Log(context.Background(), LevelInfo, "info")
+	if logger.count("debug") != 0 || logger.count("info") != 1 {
+		t.Fatalf("expected the info level, got %+v", logger.entries)
+	}
+}
+
+func TestClientLogsDeprecationOnce(t *testing.T) {
+	logger := &recordingLogger{}
+	client := NewClientWithHTTP(Config{Logger: logger}, &testutil.FakeDoer{})
+
+	client.HTTPDoer()
+	client.HTTPDoer()
+	if n := logger.count("deprecated client method called"); n != 1 {
+		t.Fatalf("expected one deprecation log, got %d", n)
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	if level, err := ParseLogLevel("WARN"); err != nil || level != LevelWarn {
+		t.Fatalf("unexpected result: %v %v", level, err)
+	}
+	if _, err := ParseLogLevel("verbose"); err == nil {
+		t.Fatal("expected error for unknown level")
+	}
+}
@@ -1,17 +1,13 @@
 package messages
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"mime/multipart"
-	"net"
 	"net/http"
-	"net/textproto"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -23,8 +19,19 @@ type SendFileRequest struct {
 	ChatID   *ym.ChatID
 	Login    *ym.UserLogin
 	ThreadID *ym.ThreadID
+	// Document is replayed on retries from a buffer of up to ym.DefaultReplayBuffer
+	// bytes unless it is an io.ReadSeeker; larger readers fail a retry with
+	// ymerrors.ErrNotRewindable.
 	Document io.Reader
+	// Source, if set, is used instead of Document and allows retries to resend the file.
+	Source   *ym.UploadSource
 	Filename string
+	Caption  string
+	ym.SendOptions
+	// Progress, if set, receives upload progress; it restarts from zero on every retry.
+	Progress ym.ProgressFunc
+	// StallTimeout, if positive, aborts an upload that makes no progress for this long.
+	StallTimeout time.Duration
 }
 
 type FileMeta struct {
@@ -37,12 +44,24 @@ type SendImageRequest struct {
 	ChatID   *ym.ChatID
 	Login    *ym.UserLogin
 	ThreadID *ym.ThreadID
-	Image    io.Reader
+	// Image is replayed on retries like SendFileRequest.Document.
+	Image io.Reader
+	// Source, if set, is used instead of Image and allows retries to resend the file.
+	Source   *ym.UploadSource
 	Filename string
+	Caption  string
+	ym.SendOptions
+	// Progress, if set, receives upload progress; it restarts from zero on every retry.
+	Progress ym.ProgressFunc
+	// StallTimeout, if positive, aborts an upload that makes no progress for this long.
+	StallTimeout time.Duration
 }
 
 type FilePart struct {
-	Reader   io.Reader
+	// Reader is replayed on retries like SendFileRequest.Document.
+	Reader io.Reader
+	// Source, if set, is used instead of Reader and allows retries to resend the file.
+	Source   *ym.UploadSource
 	Filename string
 }
 
@@ -51,6 +70,20 @@ type SendGalleryRequest struct {
 	Login    *ym.UserLogin
 	ThreadID *ym.ThreadID
 	Images   []FilePart
+	Caption  string
+	ym.SendOptions
+	// Progress, if set, receives upload progress of the whole gallery; it restarts from zero on every retry.
+	Progress ym.ProgressFunc
+	// StallTimeout, if positive, aborts an upload that makes no progress for this long.
+	StallTimeout time.Duration
+}
+
+// GetFileOptions configures GetFileWithOptions.
+type GetFileOptions struct {
+	// Progress, if set, receives download progress while the returned body is read.
+	Progress ym.ProgressFunc
+	// StallTimeout, if positive, aborts a download that makes no progress for this long.
+	StallTimeout time.Duration
 }
 
 type DeleteMessageRequest struct {
@@ -60,38 +93,51 @@ type DeleteMessageRequest struct {
 	ThreadID  *ym.ThreadID  `json:"thread_id,omitempty"`
 }
 
+// upload describes a multipart send.
+type upload struct {
+	path         string
+	chatID       *ym.ChatID
+	login        *ym.UserLogin
+	threadID     *ym.ThreadID
+	files        []ym.MultipartFile
+	caption      string
+	options      ym.SendOptions
+	progress     ym.ProgressFunc
+	stallTimeout time.Duration
+}
+
 func (s *Service) SendFile(ctx context.Context, req *SendFileRequest) (*ym.Message, error) {
 	if err := validateRecipient(req.ChatID, req.Login); err != nil {
 		return nil, err
 	}
-	if req.Document == nil || req.Filename == "" {
+	source := uploadSource(req.Source, req.Document)
+	if source == nil || req.Filename == "" {
 		return nil, errors.New("document and filename are required")
 	}
-	payload, contentType, err := buildSingleFilePayload(
-		req.ChatID, req.Login, req.ThreadID, "document", req.Filename, req.Document,
-	)
-	if err != nil {
-		return nil, err
-	}
+	files := []ym.MultipartFile{{Field: "document", Filename: req.Filename, Source: source}}
 
-	return s.doMultipart(ctx, "/bot/v1/messages/sendFile/", contentType, payload)
+	return s.doMultipart(ctx, upload{
+		path: "/bot/v1/messages/sendFile/", chatID: req.ChatID, login: req.Login, threadID: req.ThreadID,
+		files: files, caption: req.Caption, options: req.SendOptions,
+		progress: req.Progress, stallTimeout: req.StallTimeout,
+	})
 }
 
 func (s *Service) SendImage(ctx context.Context, req *SendImageRequest) (*ym.Message, error) {
 	if err := validateRecipient(req.ChatID, req.Login); err != nil {
 		return nil, err
 	}
-	if req.Image == nil || req.Filename == "" {
+	source := uploadSource(req.Source, req.Image)
+	if source == nil || req.Filename == "" {
 		return nil, errors.New("image and filename are required")
 	}
-	payload, contentType, err := buildSingleFilePayload(
-		req.ChatID, req.Login, req.ThreadID, "image", req.Filename, req.Image,
-	)
-	if err != nil {
-		return nil, err
-	}
+	files := []ym.MultipartFile{{Field: "image", Filename: req.Filename, Source: source}}
 
-	return s.doMultipart(ctx, "/bot/v1/messages/sendImage/", contentType, payload)
+	return s.doMultipart(ctx, upload{
+		path: "/bot/v1/messages/sendImage/", chatID: req.ChatID, login: req.Login, threadID: req.ThreadID,
+		files: files, caption: req.Caption, options: req.SendOptions,
+		progress: req.Progress, stallTimeout: req.StallTimeout,
+	})
 }
 
 func (s *Service) SendGallery(ctx context.Context, req *SendGalleryRequest) (*ym.Message, error) {
@@ -102,42 +148,20 @@ func (s *Service) SendGallery(ctx context.Context, req *SendGalleryRequest) (*ym
 		return nil, errors.New("at least one image is required")
 	}
 
-	var buf bytes.Buffer
-	writer := multipart.NewWriter(&buf)
-	if req.ChatID != nil {
-		if err := writer.WriteField("chat_id", string(*req.ChatID)); err != nil {
-			return nil, err
-		}
-	}
-	if req.Login != nil {
-		if err := writer.WriteField("login", string(*req.Login)); err != nil {
-			return nil, err
-		}
-	}
-	if req.ThreadID != nil {
-		if err := writer.WriteField("thread_id", fmt.Sprintf("%d", *req.ThreadID)); err != nil {
-			return nil, err
-		}
-	}
+	files := make([]ym.MultipartFile, 0, len(req.Images))
 	for i, img := range req.Images {
-		if img.Reader == nil || img.Filename == "" {
+		source := uploadSource(img.Source, img.Reader)
+		if source == nil || img.Filename == "" {
 			return nil, fmt.Errorf("image %d missing reader or filename", i)
 		}
-		headers := textproto.MIMEHeader{}
-		headers.Set("Content-Disposition", fmt.Sprintf(`form-data; name="images"; filename="%s"`, img.Filename))
-		part, err := writer.CreatePart(headers)
-		if err != nil {
-			return nil, err
-		}
-		if _, err := io.Copy(part, img.Reader); err != nil {
-			return nil, err
-		}
-	}
-	if err := writer.Close(); err != nil {
-		return nil, err
+		files = append(files, ym.MultipartFile{Field: "images", Filename: img.Filename, Source: source})
 	}
 
-	return s.doMultipart(ctx, "/bot/v1/messages/sendGallery/", writer.FormDataContentType(), buf.Bytes())
+	return s.doMultipart(ctx, upload{
+		path: "/bot/v1/messages/sendGallery/", chatID: req.ChatID, login: req.Login, threadID: req.ThreadID,
+		files: files, caption: req.Caption, options: req.SendOptions,
+		progress: req.Progress, stallTimeout: req.StallTimeout,
+	})
 }
 
 func (s *Service) Delete(ctx context.Context, req *DeleteMessageRequest) error {
@@ -147,7 +171,13 @@ func (s *Service) Delete(ctx context.Context, req *DeleteMessageRequest) error {
 	if req.MessageID == 0 {
 		return errors.New("message_id is required")
 	}
-	resp, err := s.client.DoRequest(ctx, http.MethodPost, "/bot/v1/messages/delete/", req)
+	apiReq, err := ym.NewJSONRequest(http.MethodPost, "/bot/v1/messages/delete/", req)
+	if err != nil {
+		return err
+	}
+	setRecipient(apiReq, req.ChatID, req.Login)
+
+	resp, err := s.client.Do(ctx, apiReq)
 	if err != nil {
 		return err
 	}
@@ -157,8 +187,8 @@ func (s *Service) Delete(ctx context.Context, req *DeleteMessageRequest) error {
 		OK          bool   `json:"ok"`
 		Description string `json:"description"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-		return fmt.Errorf("%w: decode delete response: %w", ymerrors.ErrInvalidResponse, err)
+	if err := s.client.DecodeJSON(ctx, resp, &parsed, "delete"); err != nil {
+		return err
 	}
 	if !parsed.OK {
 		return &ymerrors.APIError{
@@ -174,11 +204,23 @@ func (s *Service) Delete(ctx context.Context, req *DeleteMessageRequest) error {
 }
 
 func (s *Service) GetFile(ctx context.Context, fileID string) (io.ReadCloser, *FileMeta, error) {
+	return s.GetFileWithOptions(ctx, fileID, nil)
+}
+
+// GetFileWithOptions is GetFile with download progress reporting and stall detection.
+// The returned body must be closed.
+func (s *Service) GetFileWithOptions(
+	ctx context.Context, fileID string, opts *GetFileOptions,
+) (io.ReadCloser, *FileMeta, error) {
 	if fileID == "" {
 		return nil, nil, errors.New("file_id is required")
 	}
-	path := "/bot/v1/messages/getFile/?file_id=" + url.QueryEscape(fileID)
-	resp, err := s.client.DoRequest(ctx, http.MethodGet, path, nil)
+	req := ym.NewRequest(http.MethodGet, "/bot/v1/messages/getFile/?file_id="+url.QueryEscape(fileID))
+	if opts != nil {
+		req.Progress = opts.Progress
+		req.StallTimeout = opts.StallTimeout
+	}
+	resp, err := s.client.Do(ctx, req)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -195,8 +237,8 @@ func (s *Service) GetFile(ctx context.Context, fileID string) (io.ReadCloser, *F
 			OK          bool   `json:"ok"`
 			Description string `json:"description"`
 		}
-		if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-			return nil, nil, fmt.Errorf("%w: decode getFile response: %w", ymerrors.ErrInvalidResponse, err)
+		if err := s.client.DecodeJSON(ctx, resp, &parsed, "getFile"); err != nil {
+			return nil, nil, err
 		}
 		if !parsed.OK {
 			return nil, nil, &ymerrors.APIError{
@@ -212,6 +254,19 @@ func (s *Service) GetFile(ctx context.Context, fileID string) (io.ReadCloser, *F
 	return resp.Body, meta, nil
 }
 
+// uploadProgress filters out the progress of the small JSON response.
+func uploadProgress(progress ym.ProgressFunc) ym.ProgressFunc {
+	if progress == nil {
+		return nil
+	}
+
+	return func(p ym.Progress) {
+		if p.Direction == ym.Upload {
+			progress(p)
+		}
+	}
+}
+
 func validateRecipient(chatID *ym.ChatID, login *ym.UserLogin) error {
 	if (chatID == nil || *chatID == "") && (login == nil || *login == "") {
 		return errors.New("either chat_id or login is required")
@@ -223,154 +278,80 @@ func validateRecipient(chatID *ym.ChatID, login *ym.UserLogin) error {
 	return nil
 }
 
-func buildSingleFilePayload(
-	chatID *ym.ChatID, login *ym.UserLogin, threadID *ym.ThreadID, field, filename string, reader io.Reader,
-) ([]byte, string, error) {
-	var buf bytes.Buffer
-	writer := multipart.NewWriter(&buf)
+func setRecipient(req *ym.Request, chatID *ym.ChatID, login *ym.UserLogin) {
 	if chatID != nil {
-		if err := writer.WriteField("chat_id", string(*chatID)); err != nil {
-			return nil, "", err
-		}
+		req.ChatID = *chatID
 	}
 	if login != nil {
-		if err := writer.WriteField("login", string(*login)); err != nil {
-			return nil, "", err
-		}
+		req.Login = *login
 	}
-	if threadID != nil {
-		if err := writer.WriteField("thread_id", fmt.Sprintf("%d", *threadID)); err != nil {
-			return nil, "", err
-		}
-	}
-	headers := textproto.MIMEHeader{}
-	headers.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, field, filename))
-	part, err := writer.CreatePart(headers)
-	if err != nil {
-		return nil, "", err
-	}
-	if _, err := io.Copy(part, reader); err != nil {
-		return nil, "", err
+}
+
+func uploadSource(source *ym.UploadSource, reader io.Reader) *ym.UploadSource {
+	if source != nil {
+		return source
 	}
-	if err := writer.Close(); err != nil {
-		return nil, "", err
+	if reader == nil {
+		return nil
 	}
 
-	return buf.Bytes(), writer.FormDataContentType(), nil
+	return ym.BufferedReaderSource(reader, ym.DefaultReplayBuffer)
 }
 
-func (s *Service) doMultipart(ctx context.Context, path, contentType string, payload []byte) (*ym.Message, error) {
-	cfg := s.client.Config()
-	retryCfg := cfg.ErrorHandling.RetryStrategy
-	rateCfg := cfg.ErrorHandling.RateLimitHandling
-
-	attempts := retryCfg.MaxAttempts
-	if attempts < 1 {
-		attempts = 1
+func recipientFields(chatID *ym.ChatID, login *ym.UserLogin, threadID *ym.ThreadID) []ym.MultipartField {
+	var fields []ym.MultipartField
+	if chatID != nil {
+		fields = append(fields, ym.MultipartField{Name: "chat_id", Value: string(*chatID)})
 	}
-	backoff := retryCfg.InitialBackoff
-	if backoff <= 0 {
-		backoff = 500 * time.Millisecond
+	if login != nil {
+		fields = append(fields, ym.MultipartField{Name: "login", Value: string(*login)})
 	}
-
-	baseUrl := strings.TrimRight(cfg.BaseURL, "/") + path
-
-	for attempt := 1; attempt <= attempts; attempt++ {
-		req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseUrl, bytes.NewReader(payload))
-		if err != nil {
-			return nil, fmt.Errorf("yandex-messenger/messages: build request: %w", err)
-		}
-
-		if cfg.Token != "" {
-			req.Header.Set("Authorization", "OAuth "+cfg.Token)
-		}
-		req.Header.Set("Content-Type", contentType)
-
-		resp, doErr := s.client.HTTPDoer().Do(req)
-		if doErr != nil {
-			if ctxErr := ctx.Err(); ctxErr != nil {
-				return nil, fmt.Errorf("yandex-messenger/messages: %w for %s", ctxErr, path)
-			}
-			var netErr net.Error
-			if errors.As(doErr, &netErr) && retryCfg.RetryNetwork && attempt < attempts {
-				time.Sleep(backoff)
-				backoff = nextBackoffFiles(backoff, retryCfg.MaxBackoff)
-
-				continue
-			}
-
-			return nil, fmt.Errorf("yandex-messenger/messages: %w for %s", doErr, path)
-		}
-
-		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			var parsed struct {
-				OK        bool         `json:"ok"`
-				Message   *ym.Message  `json:"message"`
-				MessageID ym.MessageID `json:"message_id"`
-			}
-			if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-				resp.Body.Close()
-
-				return nil, fmt.Errorf("%w: decode multipart response: %w", ymerrors.ErrInvalidResponse, err)
-			}
-
-			resp.Body.Close()
-
-			if parsed.Message != nil {
-				return parsed.Message, nil
-			}
-			if parsed.MessageID != 0 {
-				return &ym.Message{ID: parsed.MessageID}, nil
-			}
-
-			return nil, fmt.Errorf("%w: ok=%v message missing", ymerrors.ErrInvalidResponse, parsed.OK)
-		}
-
-		apiErr, parseErr := s.client.NewAPIError(http.MethodPost, path, resp)
-		if parseErr != nil {
-			return nil, parseErr
-		}
-
-		if apiErr.Kind == ymerrors.KindRateLimited && attempt < attempts {
-			sleep := rateCfg.DefaultBackoff
-			if rateCfg.UseRetryAfter && apiErr.RetryAfter > 0 {
-				sleep = apiErr.RetryAfter
-			}
-			time.Sleep(sleep)
-
-			continue
-		}
-		if shouldRetryHTTPFiles(apiErr.HTTPStatus, retryCfg.RetryHTTP) && attempt < attempts {
-			time.Sleep(backoff)
-			backoff = nextBackoffFiles(backoff, retryCfg.MaxBackoff)
-
-			continue
-		}
-
-		return nil, apiErr
+	if threadID != nil {
+		fields = append(fields, ym.MultipartField{Name: "thread_id", Value: strconv.FormatInt(int64(*threadID), 10)})
 	}
 
-	return nil, fmt.Errorf("yandex-messenger/messages: retries exhausted for %s", path)
+	return fields
 }
 
-func nextBackoffFiles(current, maximum time.Duration) time.Duration {
-	if current <= 0 {
-		current = 500 * time.Millisecond
+func (s *Service) doMultipart(ctx context.Context, u upload) (*ym.Message, error) {
+	options, err := u.options.Fields()
+	if err != nil {
+		return nil, err
+	}
+	fields := recipientFields(u.chatID, u.login, u.threadID)
+	if u.caption != "" {
+		fields = append(fields, ym.MultipartField{Name: "caption", Value: u.caption})
 	}
-	next := current * 2
-	if maximum > 0 && next > maximum {
-		return maximum
+	fields = append(fields, options...)
+	req, err := ym.NewMultipartRequest(http.MethodPost, u.path, fields, u.files)
+	if err != nil {
+		return nil, err
 	}
+	setRecipient(req, u.chatID, u.login)
+	req.Progress = uploadProgress(u.progress)
+	req.StallTimeout = u.stallTimeout
 
-	return next
-}
+	resp, err := s.client.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 
-func shouldRetryHTTPFiles(status int, list []int) bool {
-	for _, s := range list {
-		if status == s {
-			return true
-		}
+	var parsed struct {
+		OK        bool         `json:"ok"`
+		Message   *ym.Message  `json:"message"`
+		MessageID ym.MessageID `json:"message_id"`
+	}
+	if err := s.client.DecodeJSON(ctx, resp, &parsed, "multipart"); err != nil {
+		return nil, err
+	}
+
+	if parsed.Message != nil {
+		return parsed.Message, nil
+	}
+	if parsed.MessageID != 0 {
+		return &ym.Message{ID: parsed.MessageID}, nil
 	}
 
-	return false
+	return nil, fmt.Errorf("%w: ok=%v message missing", ymerrors.ErrInvalidResponse, parsed.OK)
 }
@@ -3,9 +3,16 @@ package messages
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"os"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/rekurt/ymsdk/client/ym"
 	"github.com/rekurt/ymsdk/client/ym/ymerrors"
@@ -85,3 +92,115 @@ func TestGetFileJSONError(t *testing.T) {
 }
 
 func ptrChat(id ym.ChatID) *ym.ChatID { return &id }
+
+func TestSendImageRetriesThroughClientPipeline(t *testing.T) {
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			testutil.NewResponse(http.StatusServiceUnavailable, `{"ok":false}`),
+			testutil.NewResponse(http.StatusOK, `{"ok":true,"message_id":7}`),
+		},
+	}
+	var seen int
+	client := ym.NewClientWithHTTP(ym.Config{
+		BaseURL: "http://example.com",
+		ErrorHandling: ymerrors.ErrorHandlingConfig{
+			RetryStrategy: ymerrors.RetryStrategy{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+		},
+		AttemptInterceptors: []ym.Interceptor{
+			func(ctx context.Context, req *ym.Request, next ym.Invoker) (*http.Response, error) {
+				seen++
+
+				return next(ctx, req)
+			},
+		},
+	}, doer)
+	svc := NewService(client)
+
+	msg, err := svc.SendImage(context.Background(), &SendImageRequest{
+		ChatID:   ptrChat("c1"),
+		Image:    bytes.NewBufferString("img"),
+		Filename: "a.png",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if msg.ID != 7 {
+		t.Fatalf("expected message id 7, got %d", msg.ID)
+	}
+	if seen != 2 || len(doer.Requests) != 2 {
+		t.Fatalf("expected two attempts through interceptors, got %d/%d", seen, len(doer.Requests))
+	}
+}
+
+func TestSendGalleryOptionsMatchRecordedPayload(t *testing.T) {
+	recorded, err := os.ReadFile("testdata/send_gallery.json")
+	if err != nil {
+		t.Fatalf("read recorded payload: %v", err)
+	}
+	var want map[string][]string
+	if err := json.Unmarshal(recorded, &want); err != nil {
+		t.Fatalf("decode recorded payload: %v", err)
+	}
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{testutil.NewResponse(http.StatusOK, `{"ok":true,"message_id":9}`)},
+	}
+	svc := NewService(ym.NewClientWithHTTP(ym.Config{BaseURL: "http://example.com"}, doer))
+
+	thread := ym.ThreadID(12)
+	_, err = svc.SendGallery(context.Background(), &SendGalleryRequest{
+		ChatID:   ptrChat("c1"),
+		ThreadID: &thread,
+		Images:   []FilePart{{Source: ym.BytesSource([]byte("a")), Filename: "a.png"}, {Source: ym.BytesSource([]byte("b")), Filename: "b.png"}},
+		Caption:  "release screenshots",
+		SendOptions: ym.SendOptions{
+			PayloadID:             "gallery-42",
+			ReplyMessageID:        41,
+			DisableNotification:   true,
+			Important:             true,
+			DisableWebPagePreview: true,
+			SuggestButtons:        &ym.SuggestButtons{Buttons: [][]ym.Button{{ym.TextButton("Thanks")}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, files := multipartFields(t, doer.Requests[0])
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("fields mismatch:\n got %v\nwant %v", got, want)
+	}
+	if files != 2 {
+		t.Fatalf("expected 2 files, got %d", files)
+	}
+}
+
+// multipartFields returns the form fields of a multipart request and the number of file parts.
+func multipartFields(t *testing.T, req *http.Request) (map[string][]string, int) {
+	t.Helper()
+	_, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("parse content type: %v", err)
+	}
+	reader := multipart.NewReader(req.Body, params["boundary"])
+	fields := map[string][]string{}
+	files := 0
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return fields, files
+		}
+		if err != nil {
+			t.Fatalf("read part: %v", err)
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("read part: %v", err)
+		}
+		if part.FileName() != "" {
+			files++
+
+			continue
+		}
+		fields[part.FormName()] = append(fields[part.FormName()], string(data))
+	}
+}
@@ -0,0 +1,113 @@
+package messages
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rekurt/ymsdk/client/ym"
+)
+
+// ChainOptions configures SendChainToChat and SendChainToLogin.
+type ChainOptions struct {
+	SendMessageOptions
+	// MaxLength is the maximum part length in runes, ym.MaxTextLength if zero.
+	MaxLength int
+	// ReplyChain makes every part after the first a reply to the previous part.
+	// Otherwise every part replies to ReplyMessageID, if set.
+	ReplyChain bool
+}
+
+// PartialSendError reports a chain that stopped at a failed part. The parts
+// before it were delivered; the parts after it were not sent.
+type PartialSendError struct {
+	// Delivered holds the messages of the delivered parts, in order.
+	Delivered []*ym.Message
+	// Failed is the 0-based index of the part that failed.
+	Failed int
+	// Parts is the total number of parts.
+	Parts int
+	Err   error
+}
+
+func (e *PartialSendError) Error() string {
+	return fmt.Sprintf(
+		"yandex-messenger/messages: delivered %d of %d parts, part %d failed: %v", len(e.Delivered), e.Parts, e.Failed+1, e.Err,
+	)
+}
+
+func (e *PartialSendError) Unwrap() error {
+	return e.Err
+}
+
+// SendChainToChat sends text split with SplitText as a chain of messages, in
+// order. Keyboards are attached to the last part and payload ids get a per-part
+// suffix. If a part fails, the delivered messages are returned with a *PartialSendError.
+func (s *Service) SendChainToChat(
+	ctx context.Context, chatID ym.ChatID, text string, opts *ChainOptions,
+) ([]*ym.Message, error) {
+	return s.sendChain(ctx, text, opts, func(req *sendMessageRequest) { req.ChatID = chatID })
+}
+
+// SendChainToLogin is SendChainToChat for a private chat with login.
+func (s *Service) SendChainToLogin(
+	ctx context.Context, login ym.UserLogin, text string, opts *ChainOptions,
+) ([]*ym.Message, error) {
+	return s.sendChain(ctx, text, opts, func(req *sendMessageRequest) { req.Login = login })
+}
+
+func (s *Service) sendChain(
+	ctx context.Context, text string, opts *ChainOptions, recipient func(*sendMessageRequest),
+) ([]*ym.Message, error) {
+	if opts == nil {
+		opts = &ChainOptions{}
+	}
+	// Options are validated once, before any part is sent.
+	if _, err := buildRequest(text, &opts.SendMessageOptions); err != nil {
+		return nil, err
+	}
+
+	parts := SplitText(text, opts.MaxLength)
+	messages := make([]*ym.Message, 0, len(parts))
+	for i, part := range parts {
+		partOpts := chainPartOptions(opts, i, len(parts), messages)
+		req, err := buildRequest(part, &partOpts)
+		if err != nil {
+			return messages, err
+		}
+		recipient(&req)
+
+		msg, err := s.send(ctx, req)
+		if err != nil {
+			if len(parts) == 1 {
+				return nil, err
+			}
+
+			return messages, &PartialSendError{Delivered: messages, Failed: i, Parts: len(parts), Err: err}
+		}
+		messages = append(messages, msg)
+	}
+
+	return messages, nil
+}
+
+func chainPartOptions(opts *ChainOptions, i, total int, sent []*ym.Message) SendMessageOptions {
+	partOpts := opts.SendMessageOptions
+	if total == 1 {
+		return partOpts
+	}
+	if i < total-1 {
+		partOpts.InlineKeyboard = nil
+		partOpts.SuggestButtons = nil
+	}
+	if partOpts.PayloadID != "" {
+		partOpts.PayloadID = fmt.Sprintf("%s:%d", partOpts.PayloadID, i+1)
+	}
+	if partOpts.IdempotencyKey != "" {
+		partOpts.IdempotencyKey = fmt.Sprintf("%s:%d", partOpts.IdempotencyKey, i+1)
+	}
+	if opts.ReplyChain && i > 0 {
+		partOpts.ReplyMessageID = sent[i-1].ID
+	}
+
+	return partOpts
+}
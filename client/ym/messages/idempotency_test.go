@@ -0,0 +1,168 @@
+package messages
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym"
+	"github.com/rekurt/ymsdk/client/ym/idempotency"
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+	"github.com/rekurt/ymsdk/internal/testutil"
+)
+
+const sentMessage = `{"ok":true,"message":{"message_id":5,"chat":{"id":"c1","type":"private"},"from":{"login":"bot"},"text":"hi"}}`
+
+func TestSendToChatSuppressesDuplicates(t *testing.T) {
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{testutil.NewResponse(http.StatusOK, sentMessage)},
+	}
+	client := ym.NewClientWithHTTP(ym.Config{BaseURL: "http://example.com"}, doer)
+	svc := NewService(client, WithIdempotencyStore(idempotency.NewMemoryStore(0)))
+	opts := &SendMessageOptions{IdempotencyKey: "alert-1"}
+
+	first, err := svc.SendToChat(context.Background(), "c1", "hi", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := svc.SendToChat(context.Background(), "c1", "hi", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(doer.Requests) != 1 {
+		t.Fatalf("expected duplicate send to be suppressed, got %d requests", len(doer.Requests))
+	}
+	if first.ID != 5 || second.ID != 5 {
+		t.Fatalf("expected the original message to be returned, got %d and %d", first.ID, second.ID)
+	}
+}
+
+func TestSendToChatWithoutKeyIsNotDeduplicated(t *testing.T) {
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			testutil.NewResponse(http.StatusOK, sentMessage),
+			testutil.NewResponse(http.StatusOK, sentMessage),
+		},
+	}
+	client := ym.NewClientWithHTTP(ym.Config{BaseURL: "http://example.com"}, doer)
+	svc := NewService(client, WithIdempotencyStore(idempotency.NewMemoryStore(0)))
+
+	for range 2 {
+		if _, err := svc.SendToChat(context.Background(), "c1", "build failed", nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(doer.Requests) != 2 {
+		t.Fatalf("expected both sends to go out, got %d requests", len(doer.Requests))
+	}
+	body, _ := io.ReadAll(doer.Requests[0].Body)
+	if strings.Contains(string(body), "payload_id") {
+		t.Fatalf("expected no derived payload_id, got %s", body)
+	}
+}
+
+func TestSendToChatDerivesKeysWhenEnabled(t *testing.T) {
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			testutil.NewResponse(http.StatusOK, sentMessage),
+			testutil.NewResponse(http.StatusOK, sentMessage),
+		},
+	}
+	client := ym.NewClientWithHTTP(ym.Config{BaseURL: "http://example.com"}, doer)
+	svc := NewService(client, WithIdempotencyStore(idempotency.NewMemoryStore(time.Minute)), WithDerivedIdempotencyKeys())
+
+	for _, text := range []string{"build failed", "build failed", "build fixed"} {
+		if _, err := svc.SendToChat(context.Background(), "c1", text, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(doer.Requests) != 2 {
+		t.Fatalf("expected the repeated text to be suppressed, got %d requests", len(doer.Requests))
+	}
+	body, _ := io.ReadAll(doer.Requests[0].Body)
+	if !strings.Contains(string(body), "payload_id") {
+		t.Fatalf("expected a derived payload_id, got %s", body)
+	}
+}
+
+func TestSendToChatRejectsSendInProgress(t *testing.T) {
+	doer := &testutil.FakeDoer{}
+	client := ym.NewClientWithHTTP(ym.Config{BaseURL: "http://example.com"}, doer)
+	store := idempotency.NewMemoryStore(0)
+	now := time.Now()
+	_ = store.Put(context.Background(), idempotency.Record{Key: "k", State: idempotency.StatePending, CreatedAt: now, UpdatedAt: now})
+	svc := NewService(client, WithIdempotencyStore(store))
+
+	_, err := svc.SendToChat(context.Background(), "c1", "hi", &SendMessageOptions{IdempotencyKey: "k"})
+	if !errors.Is(err, ymerrors.ErrSendInProgress) {
+		t.Fatalf("expected ErrSendInProgress, got %v", err)
+	}
+	if len(doer.Requests) != 0 {
+		t.Fatalf("expected no request, got %d", len(doer.Requests))
+	}
+}
+
+func TestSendToChatReconcilesUnknownOutcome(t *testing.T) {
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			testutil.NewResponse(http.StatusBadGateway, `{"ok":false}`),
+			testutil.NewResponse(http.StatusOK, sentMessage),
+		},
+	}
+	client := ym.NewClientWithHTTP(ym.Config{
+		BaseURL: "http://example.com",
+		ErrorHandling: ymerrors.ErrorHandlingConfig{
+			RetryStrategy: ymerrors.RetryStrategy{MaxAttempts: 1},
+		},
+	}, doer)
+	store := idempotency.NewMemoryStore(0)
+	svc := NewService(client, WithIdempotencyStore(store))
+	opts := &SendMessageOptions{IdempotencyKey: "order-17"}
+
+	if _, err := svc.SendToChat(context.Background(), "c1", "hi", opts); !errors.Is(err, ymerrors.ErrNetworkError) {
+		t.Fatalf("expected network error, got %v", err)
+	}
+	rec, _ := store.Get(context.Background(), "order-17")
+	if rec == nil || rec.State != idempotency.StateUnknown {
+		t.Fatalf("expected unknown record after unknown outcome, got %+v", rec)
+	}
+
+	if _, err := svc.SendToChat(context.Background(), "c1", "hi", opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, req := range doer.Requests {
+		body, _ := io.ReadAll(req.Body)
+		var payload map[string]any
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Fatalf("decode request %d: %v", i, err)
+		}
+		if payload["payload_id"] != "order-17" {
+			t.Fatalf("expected payload_id on request %d, got %v", i, payload["payload_id"])
+		}
+	}
+	if rec, _ := store.Get(context.Background(), "order-17"); rec == nil || rec.State != idempotency.StateDone {
+		t.Fatalf("expected done record, got %+v", rec)
+	}
+}
+
+func TestSendToChatForgetsRejectedSend(t *testing.T) {
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{testutil.NewResponse(http.StatusBadRequest, `{"ok":false}`)},
+	}
+	client := ym.NewClientWithHTTP(ym.Config{BaseURL: "http://example.com"}, doer)
+	store := idempotency.NewMemoryStore(0)
+	svc := NewService(client, WithIdempotencyStore(store))
+
+	_, err := svc.SendToChat(context.Background(), "c1", "hi", &SendMessageOptions{IdempotencyKey: "k"})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if rec, _ := store.Get(context.Background(), "k"); rec != nil {
+		t.Fatalf("expected rejected send to be forgotten, got %+v", rec)
+	}
+}
@@ -3,32 +3,78 @@ package messages
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/rekurt/ymsdk/client/ym"
+	"github.com/rekurt/ymsdk/client/ym/idempotency"
 	"github.com/rekurt/ymsdk/client/ym/ymerrors"
 )
 
 type Service struct {
-	client *ym.Client
+	client      *ym.Client
+	idempotency idempotency.Store
+	deriveKeys  bool
 }
 
-func NewService(client *ym.Client) *Service {
-	return &Service{client: client}
+type Option func(*Service)
+
+// WithIdempotencyStore enables duplicate suppression for text sends with an
+// IdempotencyKey or PayloadID. The key is passed to the API as payload_id and
+// tracked in store; sends without a key are not deduplicated unless
+// WithDerivedIdempotencyKeys is set.
+func WithIdempotencyStore(store idempotency.Store) Option {
+	return func(s *Service) {
+		s.idempotency = store
+	}
+}
+
+// WithDerivedIdempotencyKeys makes text sends without an IdempotencyKey or
+// PayloadID use a key derived from the hash of the request, so identical texts
+// to the same recipient are suppressed while the store keeps the record. Use a
+// store with a TTL, or a repeated text is never sent again. It has no effect
+// without WithIdempotencyStore.
+func WithDerivedIdempotencyKeys() Option {
+	return func(s *Service) {
+		s.deriveKeys = true
+	}
+}
+
+func NewService(client *ym.Client, opts ...Option) *Service {
+	s := &Service{client: client}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
 type SendMessageOptions struct {
-	MarkImportant    bool
+	ym.SendOptions
+	ThreadID *ym.ThreadID
+	// MarkImportant is sent as important.
+	//
+	// Deprecated: use Important.
+	MarkImportant bool
+	// ReplyToMessageID is sent as reply_message_id and must be numeric.
+	//
+	// Deprecated: use ReplyMessageID.
 	ReplyToMessageID string
+	// IdempotencyKey identifies the send when an idempotency store is configured.
+	// If empty, PayloadID is used; without either the send is deduplicated only
+	// with WithDerivedIdempotencyKeys.
+	IdempotencyKey string
 }
 
 type sendMessageRequest struct {
-	ChatID           ym.ChatID    `json:"chat_id,omitempty"`
-	Login            ym.UserLogin `json:"login,omitempty"`
-	Text             string       `json:"text"`
-	MarkImportant    bool         `json:"mark_important,omitempty"`
-	ReplyToMessageID string       `json:"reply_to_message_id,omitempty"`
+	ChatID   ym.ChatID    `json:"chat_id,omitempty"`
+	Login    ym.UserLogin `json:"login,omitempty"`
+	Text     string       `json:"text"`
+	ThreadID *ym.ThreadID `json:"thread_id,omitempty"`
+	ym.SendOptions
 }
 
 type sendMessageResponse struct {
@@ -39,7 +85,10 @@ type sendMessageResponse struct {
 func (s *Service) SendToChat(
 	ctx context.Context, chatID ym.ChatID, text string, opts *SendMessageOptions,
 ) (*ym.Message, error) {
-	req := buildRequest(text, opts)
+	req, err := buildRequest(text, opts)
+	if err != nil {
+		return nil, err
+	}
 	req.ChatID = chatID
 
 	return s.send(ctx, req)
@@ -48,22 +97,85 @@ func (s *Service) SendToChat(
 func (s *Service) SendToLogin(
 	ctx context.Context, login ym.UserLogin, text string, opts *SendMessageOptions,
 ) (*ym.Message, error) {
-	req := buildRequest(text, opts)
+	req, err := buildRequest(text, opts)
+	if err != nil {
+		return nil, err
+	}
 	req.Login = login
 
 	return s.send(ctx, req)
 }
 
 func (s *Service) send(ctx context.Context, reqBody sendMessageRequest) (*ym.Message, error) {
-	resp, err := s.client.DoRequest(ctx, http.MethodPost, "/bot/v1/messages/sendText", reqBody)
+	if s.idempotency != nil && s.deriveKeys && reqBody.PayloadID == "" {
+		payload, err := json.Marshal(reqBody)
+		if err != nil {
+			return nil, fmt.Errorf("yandex-messenger/messages: marshal request body: %w", err)
+		}
+		reqBody.PayloadID = idempotency.Key(payload)
+	}
+	key := reqBody.PayloadID
+	if s.idempotency == nil || key == "" {
+		return s.sendText(ctx, reqBody)
+	}
+
+	now := time.Now()
+	pending := idempotency.Record{Key: key, State: idempotency.StatePending, CreatedAt: now, UpdatedAt: now}
+	existing, err := s.idempotency.Reserve(ctx, pending)
+	if err != nil {
+		return nil, fmt.Errorf("yandex-messenger/messages: idempotency store: %w", err)
+	}
+	if existing != nil {
+		if existing.State == idempotency.StateDone && existing.Message != nil {
+			return existing.Message, nil
+		}
+
+		return nil, fmt.Errorf("yandex-messenger/messages: %w: %s", ymerrors.ErrSendInProgress, key)
+	}
+
+	// After an unknown outcome the next send with the key is resent with the
+	// same payload_id, so that the server can deduplicate it.
+	msg, sendErr := s.sendText(ctx, reqBody)
+	if sendErr != nil {
+		if deliveryUnknown(sendErr) {
+			unknown := pending
+			unknown.State = idempotency.StateUnknown
+			unknown.UpdatedAt = time.Now()
+			_ = s.idempotency.Put(context.WithoutCancel(ctx), unknown)
+		} else {
+			_ = s.idempotency.Delete(context.WithoutCancel(ctx), key)
+		}
+
+		return nil, sendErr
+	}
+
+	done := pending
+	done.State = idempotency.StateDone
+	done.Message = msg
+	done.UpdatedAt = time.Now()
+	if err := s.idempotency.Put(context.WithoutCancel(ctx), done); err != nil {
+		return msg, fmt.Errorf("yandex-messenger/messages: idempotency store: %w", err)
+	}
+
+	return msg, nil
+}
+
+func (s *Service) sendText(ctx context.Context, reqBody sendMessageRequest) (*ym.Message, error) {
+	req, err := ym.NewJSONRequest(http.MethodPost, "/bot/v1/messages/sendText", reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.ChatID, req.Login = reqBody.ChatID, reqBody.Login
+
+	resp, err := s.client.Do(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	var parsed sendMessageResponse
-	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-		return nil, fmt.Errorf("%w: decode sendText response: %w", ymerrors.ErrInvalidResponse, err)
+	if err := s.client.DecodeJSON(ctx, resp, &parsed, "sendText"); err != nil {
+		return nil, err
 	}
 	if !parsed.OK || parsed.Message == nil {
 		return nil, fmt.Errorf(
@@ -74,14 +186,39 @@ func (s *Service) send(ctx context.Context, reqBody sendMessageRequest) (*ym.Mes
 	return parsed.Message, nil
 }
 
-func buildRequest(text string, opts *SendMessageOptions) sendMessageRequest {
+func buildRequest(text string, opts *SendMessageOptions) (sendMessageRequest, error) {
 	if opts == nil {
-		return sendMessageRequest{Text: text}
+		return sendMessageRequest{Text: text}, nil
+	}
+	if err := opts.Validate(); err != nil {
+		return sendMessageRequest{}, err
+	}
+
+	req := sendMessageRequest{Text: text, ThreadID: opts.ThreadID, SendOptions: opts.SendOptions}
+	if opts.MarkImportant {
+		req.Important = true
+	}
+	if opts.ReplyToMessageID != "" && req.ReplyMessageID == 0 {
+		id, err := strconv.ParseInt(opts.ReplyToMessageID, 10, 64)
+		if err != nil {
+			return sendMessageRequest{}, fmt.Errorf("yandex-messenger/messages: reply_to_message_id: %w", err)
+		}
+		req.ReplyMessageID = ym.MessageID(id)
+	}
+	if opts.IdempotencyKey != "" {
+		req.PayloadID = opts.IdempotencyKey
 	}
 
-	return sendMessageRequest{
-		Text:             text,
-		MarkImportant:    opts.MarkImportant,
-		ReplyToMessageID: opts.ReplyToMessageID,
+	return req, nil
+}
+
+// deliveryUnknown reports whether err leaves it unclear if the server accepted the message.
+// Only responses that the server definitely rejected are known to be undelivered.
+func deliveryUnknown(err error) bool {
+	var apiErr *ymerrors.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Kind == ymerrors.KindNetwork || apiErr.Kind == ymerrors.KindUnknown
 	}
+
+	return !errors.Is(err, ymerrors.ErrCircuitOpen)
 }
@@ -1,9 +1,13 @@
 package messages
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
+	"os"
 	"testing"
 
 	"github.com/rekurt/ymsdk/client/ym"
@@ -72,3 +76,131 @@ func TestSendToLoginBadJSON(t *testing.T) {
 }
 
 // helper in attachments_test.go
+
+func TestSendToChatWithKeyboards(t *testing.T) {
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			testutil.NewResponse(http.StatusOK, `{"ok":true,"message":{"message_id":1,"chat":{"id":"c1","type":"private"},"from":{"login":"u1"}}}`),
+		},
+	}
+	service := NewService(ym.NewClientWithHTTP(ym.Config{BaseURL: "http://example.com"}, doer))
+
+	inline, err := ym.NewKeyboard().Callback("Approve", map[string]any{"id": 7}).Inline()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	suggest := &ym.SuggestButtons{Buttons: [][]ym.Button{{ym.TextButton("Later")}}}
+	_, err = service.SendToChat(context.Background(), "c1", "review?", &SendMessageOptions{
+		SendOptions: ym.SendOptions{InlineKeyboard: inline, SuggestButtons: suggest},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.NewDecoder(doer.Requests[0].Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if string(body["inline_keyboard"]) != `[[{"text":"Approve","callback_data":{"id":7}}]]` {
+		t.Fatalf("unexpected inline_keyboard: %s", body["inline_keyboard"])
+	}
+	if string(body["suggest_buttons"]) != `{"buttons":[[{"text":"Later"}]]}` {
+		t.Fatalf("unexpected suggest_buttons: %s", body["suggest_buttons"])
+	}
+}
+
+func TestSendToChatRejectsInvalidKeyboard(t *testing.T) {
+	doer := &testutil.FakeDoer{}
+	service := NewService(ym.NewClientWithHTTP(ym.Config{BaseURL: "http://example.com"}, doer))
+
+	_, err := service.SendToChat(context.Background(), "c1", "hi", &SendMessageOptions{
+		SendOptions: ym.SendOptions{InlineKeyboard: ym.InlineKeyboard{{}}},
+	})
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	if len(doer.Requests) != 0 {
+		t.Fatalf("expected no request, got %d", len(doer.Requests))
+	}
+}
+
+func TestSendToChatOptionsMatchRecordedPayload(t *testing.T) {
+	recorded, err := os.ReadFile("testdata/send_text.json")
+	if err != nil {
+		t.Fatalf("read recorded payload: %v", err)
+	}
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			testutil.NewResponse(http.StatusOK, `{"ok":true,"message":{"message_id":42,"chat":{"id":"c1","type":"group"},"from":{"login":"bot"}}}`),
+		},
+	}
+	service := NewService(ym.NewClientWithHTTP(ym.Config{BaseURL: "http://example.com"}, doer))
+
+	thread := ym.ThreadID(12)
+	_, err = service.SendToChat(context.Background(), "c1", "deploy finished", &SendMessageOptions{
+		ThreadID: &thread,
+		SendOptions: ym.SendOptions{
+			PayloadID:             "deploy-42",
+			ReplyMessageID:        41,
+			DisableNotification:   true,
+			Important:             true,
+			DisableWebPagePreview: true,
+			InlineKeyboard:        ym.InlineKeyboard{{ym.URLButton("Logs", "https://ci.example.com/42")}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sent, err := io.ReadAll(doer.Requests[0].Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	if want := compactJSON(t, recorded); string(sent) != want {
+		t.Fatalf("payload mismatch:\n got %s\nwant %s", sent, want)
+	}
+
+	var decoded sendMessageRequest
+	if err := json.Unmarshal(recorded, &decoded); err != nil {
+		t.Fatalf("decode recorded payload: %v", err)
+	}
+	again, err := json.Marshal(decoded)
+	if err != nil {
+		t.Fatalf("encode payload: %v", err)
+	}
+	if string(again) != string(sent) {
+		t.Fatalf("round trip mismatch:\n got %s\nwant %s", again, sent)
+	}
+}
+
+func TestSendToChatDeprecatedOptions(t *testing.T) {
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			testutil.NewResponse(http.StatusOK, `{"ok":true,"message":{"message_id":1,"chat":{"id":"c1","type":"private"},"from":{"login":"u1"}}}`),
+		},
+	}
+	service := NewService(ym.NewClientWithHTTP(ym.Config{BaseURL: "http://example.com"}, doer))
+
+	_, err := service.SendToChat(context.Background(), "c1", "hi", &SendMessageOptions{MarkImportant: true, ReplyToMessageID: "5"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sent, _ := io.ReadAll(doer.Requests[0].Body)
+	if want := `{"chat_id":"c1","text":"hi","reply_message_id":5,"important":true}`; string(sent) != want {
+		t.Fatalf("unexpected payload %s", sent)
+	}
+
+	_, err = service.SendToChat(context.Background(), "c1", "hi", &SendMessageOptions{ReplyToMessageID: "abc"})
+	if err == nil {
+		t.Fatal("expected error for non-numeric reply id")
+	}
+}
+
+func compactJSON(t *testing.T, data []byte) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		t.Fatalf("compact json: %v", err)
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,293 @@
+package messages
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rekurt/ymsdk/client/ym"
+)
+
+const fence = "```"
+
+// breakKind ranks the places where text may be split, from most to least preferred.
+type breakKind int
+
+const (
+	breakWord breakKind = iota
+	breakLine
+	breakParagraph
+)
+
+// textBreak is a byte offset where a new part may start.
+type textBreak struct {
+	pos  int
+	kind breakKind
+}
+
+// SplitText splits text into parts of at most limit runes, or ym.MaxTextLength
+// if limit is not positive. Parts are cut at paragraph, line and word boundaries,
+// in that order of preference, and never inside inline markup or a code fence;
+// a fence longer than limit is split by lines into several closed fences. Only a
+// single word longer than limit is cut at an arbitrary rune boundary. Whitespace
+// at the cuts is dropped. Text that fits is returned as is.
+func SplitText(text string, limit int) []string {
+	if limit <= 0 {
+		limit = ym.MaxTextLength
+	}
+	if utf8.RuneCountInString(text) <= limit {
+		return []string{text}
+	}
+
+	text = splitFences(text, limit)
+	breaks := findBreaks(text)
+
+	var parts []string
+	start := 0
+	for start < len(text) {
+		end := runeOffset(text, start, limit)
+		if end == len(text) {
+			if part := strings.TrimRight(text[start:], " \t\n"); part != "" {
+				parts = append(parts, part)
+			}
+
+			break
+		}
+		cut := chooseBreak(text, breaks, start, end, limit)
+		if part := strings.TrimRight(text[start:cut], " \t\n"); part != "" {
+			parts = append(parts, part)
+		}
+		start = cut
+		for start < len(text) && text[start] == '\n' {
+			start++
+		}
+	}
+
+	return parts
+}
+
+// chooseBreak returns the preferred break in (start, end]. Breaks leaving the
+// part less than half full are used only when there is nothing better.
+func chooseBreak(text string, breaks []textBreak, start, end, limit int) int {
+	minFill := runeOffset(text, start, limit/2)
+	best := [breakParagraph + 1]int{}
+	last := 0
+	first := sort.Search(len(breaks), func(i int) bool { return breaks[i].pos > start })
+	for _, b := range breaks[first:] {
+		if b.pos > end {
+			break
+		}
+		last = b.pos
+		if b.pos >= minFill {
+			best[b.kind] = b.pos
+		}
+	}
+	for kind := breakParagraph; kind >= breakWord; kind-- {
+		if best[kind] > 0 {
+			return best[kind]
+		}
+	}
+	if last > 0 {
+		return last
+	}
+
+	return end
+}
+
+// findBreaks lists the allowed breaks of text in increasing order.
+func findBreaks(text string) []textBreak {
+	var breaks []textBreak
+	inFence := false
+	blank := false
+	pos := 0
+	for _, line := range strings.SplitAfter(text, "\n") {
+		isFence := strings.HasPrefix(strings.TrimSpace(line), fence)
+		if pos > 0 && !inFence {
+			kind := breakLine
+			if blank {
+				kind = breakParagraph
+			}
+			breaks = append(breaks, textBreak{pos: pos, kind: kind})
+		}
+		if isFence {
+			inFence = !inFence
+		} else if !inFence {
+			breaks = append(breaks, wordBreaks(line, pos)...)
+		}
+		blank = strings.TrimSpace(line) == ""
+		pos += len(line)
+	}
+
+	return breaks
+}
+
+// wordBreaks lists the breaks after runs of spaces in line that are not inside
+// inline code, emphasis or a link. Backslash-escaped characters are literal.
+func wordBreaks(line string, offset int) []textBreak {
+	var breaks []textBreak
+	var code bool
+	open := map[string]bool{}
+	brackets, parens := 0, 0
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '`':
+			code = !code
+		case code:
+		case c == '\\':
+			i++
+		case c == '[':
+			brackets++
+		case c == ']' && brackets > 0:
+			brackets--
+			if i+1 < len(line) && line[i+1] == '(' {
+				parens++
+				i++
+			}
+		case c == ')' && parens > 0:
+			parens--
+		case i+1 < len(line) && isMarker(line[i:i+2]):
+			open[line[i:i+2]] = !open[line[i:i+2]]
+			i++
+		case c == '*' || c == '_':
+			toggleEmphasis(open, line, i)
+		case (c == ' ' || c == '\t') && !code && brackets == 0 && parens == 0 && !anyOpen(open):
+			j := i
+			for j < len(line) && (line[j] == ' ' || line[j] == '\t') {
+				j++
+			}
+			if j < len(line) && line[j] != '\n' {
+				breaks = append(breaks, textBreak{pos: offset + j, kind: breakWord})
+			}
+			i = j - 1
+		}
+	}
+
+	return breaks
+}
+
+func isMarker(s string) bool {
+	return s == "**" || s == "__" || s == "~~" || s == "||"
+}
+
+// toggleEmphasis opens or closes the single '*' or '_' emphasis at line[i].
+// An opener is followed by a non-space and not preceded by a word character,
+// and a closer is preceded by a non-space; a '_' closer is also not followed
+// by a word character, so snake_case is not emphasis.
+func toggleEmphasis(open map[string]bool, line string, i int) {
+	m := line[i : i+1]
+	if open[m] {
+		if i > 0 && !isSpace(line[i-1]) && (m == "*" || i+1 == len(line) || !isWordByte(line[i+1])) {
+			open[m] = false
+		}
+
+		return
+	}
+	if i+1 < len(line) && !isSpace(line[i+1]) && (i == 0 || !isWordByte(line[i-1])) {
+		open[m] = true
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+// isWordByte reports whether c is a letter, a digit or part of a multibyte rune.
+func isWordByte(c byte) bool {
+	return c >= utf8.RuneSelf || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func anyOpen(open map[string]bool) bool {
+	for _, v := range open {
+		if v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitFences rewrites code fences longer than limit into consecutive fences
+// that fit, each closed and reopened with the original opening line.
+func splitFences(text string, limit int) string {
+	lines := strings.SplitAfter(text, "\n")
+	var out strings.Builder
+	for i := 0; i < len(lines); i++ {
+		if !strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+			out.WriteString(lines[i])
+
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[end]), fence) {
+			end++
+		}
+		if end == len(lines) {
+			// An unterminated fence is left as is.
+			out.WriteString(strings.Join(lines[i:], ""))
+
+			break
+		}
+		block := strings.Join(lines[i:end+1], "")
+		if utf8.RuneCountInString(strings.TrimRight(block, "\n")) <= limit {
+			out.WriteString(block)
+		} else {
+			out.WriteString(rewriteFence(lines[i], lines[i+1:end], lines[end], limit))
+		}
+		i = end
+	}
+
+	return out.String()
+}
+
+func rewriteFence(open string, body []string, closing string, limit int) string {
+	open = strings.TrimRight(open, "\n") + "\n"
+	closeLine := strings.TrimRight(closing, "\n")
+	room := limit - utf8.RuneCountInString(open) - utf8.RuneCountInString(closeLine) - 1
+	if room < 1 {
+		room = 1
+	}
+
+	var chunks []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			chunks = append(chunks, open+strings.TrimRight(current.String(), "\n")+"\n"+closeLine)
+			current.Reset()
+		}
+	}
+	for _, line := range body {
+		for _, piece := range cutRunes(line, room) {
+			if utf8.RuneCountInString(current.String())+utf8.RuneCountInString(strings.TrimRight(piece, "\n")) > room {
+				flush()
+			}
+			current.WriteString(piece)
+		}
+	}
+	flush()
+
+	return strings.Join(chunks, "\n") + strings.TrimPrefix(closing, closeLine)
+}
+
+// cutRunes cuts s into pieces of at most n runes, not counting a trailing newline.
+func cutRunes(s string, n int) []string {
+	var pieces []string
+	for utf8.RuneCountInString(strings.TrimRight(s, "\n")) > n {
+		at := runeOffset(s, 0, n)
+		pieces = append(pieces, s[:at]+"\n")
+		s = s[at:]
+	}
+
+	return append(pieces, s)
+}
+
+// runeOffset returns the byte offset n runes after start, or len(s).
+func runeOffset(s string, start, n int) int {
+	pos := start
+	for ; n > 0 && pos < len(s); n-- {
+		_, size := utf8.DecodeRuneInString(s[pos:])
+		pos += size
+	}
+
+	return pos
+}
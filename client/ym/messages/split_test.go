@@ -0,0 +1,145 @@
+package messages
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/rekurt/ymsdk/client/ym"
+	"github.com/rekurt/ymsdk/internal/testutil"
+)
+
+func TestSplitTextBoundaries(t *testing.T) {
+	cases := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{"fits", "short text", 20, []string{"short text"}},
+		{"paragraph", "first paragraph\nline two\n\nsecond one", 30, []string{"first paragraph\nline two", "second one"}},
+		{"line", "alpha beta gamma\ndelta", 20, []string{"alpha beta gamma", "delta"}},
+		{"word", "one two three four", 10, []string{"one two", "three four"}},
+		{"runes", "приветмир", 4, []string{"прив", "етми", "р"}},
+		{"markup", "aa **bold text** bb", 16, []string{"aa", "**bold text** bb"}},
+		{"link", "see [the docs](http://x.y/a b) ok", 29, []string{"see", "[the docs](http://x.y/a b) ok"}},
+		{"inline code", "x `a b c d` y", 11, []string{"x", "`a b c d` y"}},
+		{"italic", "aa _italic text_ bb", 16, []string{"aa", "_italic text_ bb"}},
+		{"emphasis", "aa *em the text* bb", 16, []string{"aa", "*em the text* bb"}},
+		{"snake case", "one snake_case two three", 14, []string{"one", "snake_case", "two three"}},
+		{"escaped marker", `x\** one two three`, 10, []string{`x\** one`, "two three"}},
+	}
+	for _, tc := range cases {
+		got := SplitText(tc.text, tc.limit)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSplitTextKeepsFences(t *testing.T) {
+	text := "intro text here\n```go\nfmt.Println(1)\nfmt.Println(2)\n```\noutro"
+	got := SplitText(text, 40)
+	want := []string{"intro text here", "```go\nfmt.Println(1)\nfmt.Println(2)\n```", "outro"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestSplitTextReopensLongFence(t *testing.T) {
+	body := strings.Repeat("line of code\n", 10)
+	text := "```sh\n" + body + "```"
+	parts := SplitText(text, 50)
+	if len(parts) < 2 {
+		t.Fatalf("expected several parts, got %q", parts)
+	}
+	var joined strings.Builder
+	for _, part := range parts {
+		if n := utf8.RuneCountInString(part); n > 50 {
+			t.Fatalf("part of %d runes exceeds limit: %q", n, part)
+		}
+		if !strings.HasPrefix(part, "```sh\n") || !strings.HasSuffix(part, "\n```") {
+			t.Fatalf("part is not a closed fence: %q", part)
+		}
+		joined.WriteString(strings.TrimSuffix(strings.TrimPrefix(part, "```sh\n"), "```"))
+	}
+	if joined.String() != body {
+		t.Fatalf("fence content changed: %q", joined.String())
+	}
+}
+
+func TestSplitTextRespectsLimit(t *testing.T) {
+	text := strings.Repeat("Съешь же ещё этих мягких французских булок, да выпей чаю. ", 300)
+	parts := SplitText(text, ym.MaxTextLength)
+	if len(parts) != 3 {
+		t.Fatalf("expected 3 parts, got %d", len(parts))
+	}
+	for _, part := range parts {
+		if !utf8.ValidString(part) || utf8.RuneCountInString(part) > ym.MaxTextLength {
+			t.Fatalf("invalid part of %d runes", utf8.RuneCountInString(part))
+		}
+	}
+	if strings.Join(parts, " ") != strings.TrimSpace(text) {
+		t.Fatal("parts do not add up to the text")
+	}
+}
+
+func TestSendChainToChatRepliesInOrder(t *testing.T) {
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			testutil.NewResponse(http.StatusOK, `{"ok":true,"message":{"message_id":1,"chat":{"id":"c1","type":"group"},"from":{"login":"bot"}}}`),
+			testutil.NewResponse(http.StatusOK, `{"ok":true,"message":{"message_id":2,"chat":{"id":"c1","type":"group"},"from":{"login":"bot"}}}`),
+		},
+	}
+	service := NewService(ym.NewClientWithHTTP(ym.Config{BaseURL: "http://example.com"}, doer))
+
+	inline := ym.InlineKeyboard{{ym.TextButton("ok")}}
+	msgs, err := service.SendChainToChat(context.Background(), "c1", "first part\n\nsecond part", &ChainOptions{
+		SendMessageOptions: SendMessageOptions{SendOptions: ym.SendOptions{PayloadID: "p", InlineKeyboard: inline}},
+		MaxLength:          15,
+		ReplyChain:         true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(msgs) != 2 || msgs[0].ID != 1 || msgs[1].ID != 2 {
+		t.Fatalf("unexpected messages: %+v", msgs)
+	}
+
+	first, _ := io.ReadAll(doer.Requests[0].Body)
+	second, _ := io.ReadAll(doer.Requests[1].Body)
+	if want := `{"chat_id":"c1","text":"first part","payload_id":"p:1"}`; string(first) != want {
+		t.Fatalf("unexpected first payload %s", first)
+	}
+	want := `{"chat_id":"c1","text":"second part","payload_id":"p:2","reply_message_id":1,"inline_keyboard":[[{"text":"ok"}]]}`
+	if string(second) != want {
+		t.Fatalf("unexpected second payload %s", second)
+	}
+}
+
+func TestSendChainToChatReportsPartialFailure(t *testing.T) {
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			testutil.NewResponse(http.StatusOK, `{"ok":true,"message":{"message_id":1,"chat":{"id":"c1","type":"group"},"from":{"login":"bot"}}}`),
+			testutil.NewResponse(http.StatusBadRequest, `{"ok":false,"description":"bad"}`),
+		},
+	}
+	service := NewService(ym.NewClientWithHTTP(ym.Config{BaseURL: "http://example.com"}, doer))
+
+	msgs, err := service.SendChainToChat(context.Background(), "c1", "one two three", &ChainOptions{MaxLength: 5})
+	var partial *PartialSendError
+	if !errors.As(err, &partial) {
+		t.Fatalf("expected PartialSendError, got %v", err)
+	}
+	if partial.Failed != 1 || partial.Parts != 3 || len(partial.Delivered) != 1 || len(msgs) != 1 {
+		t.Fatalf("unexpected partial error: %+v", partial)
+	}
+	if len(doer.Requests) != 2 {
+		t.Fatalf("expected sending to stop after the failure, got %d requests", len(doer.Requests))
+	}
+}
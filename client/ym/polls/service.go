@@ -2,9 +2,7 @@ package polls
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -22,18 +20,20 @@ func NewService(client *ym.Client) *Service {
 }
 
 type CreatePollRequest struct {
-	ChatID                *ym.ChatID    `json:"chat_id,omitempty"`
-	Login                 *ym.UserLogin `json:"login,omitempty"`
-	Title                 string        `json:"title"`
-	Answers               []string      `json:"answers"`
-	MaxChoices            *int          `json:"max_choices,omitempty"`
-	IsAnonymous           *bool         `json:"is_anonymous,omitempty"`
-	PayloadID             *string       `json:"payload_id,omitempty"`
-	ReplyMessageID        *ym.MessageID `json:"reply_message_id,omitempty"`
-	DisableNotification   *bool         `json:"disable_notification,omitempty"`
-	Important             *bool         `json:"important,omitempty"`
-	DisableWebPagePreview *bool         `json:"disable_web_page_preview,omitempty"`
-	ThreadID              *ym.ThreadID  `json:"thread_id,omitempty"`
+	ChatID                *ym.ChatID         `json:"chat_id,omitempty"`
+	Login                 *ym.UserLogin      `json:"login,omitempty"`
+	Title                 string             `json:"title"`
+	Answers               []string           `json:"answers"`
+	MaxChoices            *int               `json:"max_choices,omitempty"`
+	IsAnonymous           *bool              `json:"is_anonymous,omitempty"`
+	PayloadID             *string            `json:"payload_id,omitempty"`
+	ReplyMessageID        *ym.MessageID      `json:"reply_message_id,omitempty"`
+	DisableNotification   *bool              `json:"disable_notification,omitempty"`
+	Important             *bool              `json:"important,omitempty"`
+	DisableWebPagePreview *bool              `json:"disable_web_page_preview,omitempty"`
+	ThreadID              *ym.ThreadID       `json:"thread_id,omitempty"`
+	InlineKeyboard        ym.InlineKeyboard  `json:"inline_keyboard,omitempty"`
+	SuggestButtons        *ym.SuggestButtons `json:"suggest_buttons,omitempty"`
 }
 
 func (s *Service) Create(ctx context.Context, req *CreatePollRequest) (*ym.Message, error) {
@@ -46,8 +46,22 @@ func (s *Service) Create(ctx context.Context, req *CreatePollRequest) (*ym.Messa
 	if req.MaxChoices != nil && *req.MaxChoices <= 0 {
 		return nil, errors.New("max_choices must be > 0")
 	}
+	if err := ym.ValidateKeyboards(req.InlineKeyboard, req.SuggestButtons); err != nil {
+		return nil, err
+	}
 
-	resp, err := s.client.DoRequest(ctx, http.MethodPost, "/bot/v1/messages/createPoll/", req)
+	apiReq, err := ym.NewJSONRequest(http.MethodPost, "/bot/v1/messages/createPoll/", req)
+	if err != nil {
+		return nil, err
+	}
+	if req.ChatID != nil {
+		apiReq.ChatID = *req.ChatID
+	}
+	if req.Login != nil {
+		apiReq.Login = *req.Login
+	}
+
+	resp, err := s.client.Do(ctx, apiReq)
 	if err != nil {
 		return nil, err
 	}
@@ -57,8 +71,8 @@ func (s *Service) Create(ctx context.Context, req *CreatePollRequest) (*ym.Messa
 		OK      bool        `json:"ok"`
 		Message *ym.Message `json:"message"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-		return nil, fmt.Errorf("%w: decode create poll response: %w", ymerrors.ErrInvalidResponse, err)
+	if err := s.client.DecodeJSON(ctx, resp, &parsed, "create poll"); err != nil {
+		return nil, err
 	}
 	if !parsed.OK || parsed.Message == nil {
 		return nil, &ymerrors.APIError{
@@ -117,8 +131,8 @@ func (s *Service) GetResults(ctx context.Context, params PollResultsParams) (*ym
 		Answers     map[string]int `json:"answers"`
 		Description string         `json:"description"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-		return nil, fmt.Errorf("%w: decode getResults response: %w", ymerrors.ErrInvalidResponse, err)
+	if err := s.client.DecodeJSON(ctx, resp, &parsed, "getResults"); err != nil {
+		return nil, err
 	}
 	if !parsed.OK {
 		return nil, &ymerrors.APIError{
@@ -198,8 +212,8 @@ func (s *Service) GetVotersPage(ctx context.Context, params PollVotersParams) (*
 		Votes       []ym.Vote `json:"votes"`
 		Description string    `json:"description"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-		return nil, fmt.Errorf("%w: decode getVoters response: %w", ymerrors.ErrInvalidResponse, err)
+	if err := s.client.DecodeJSON(ctx, resp, &parsed, "getVoters"); err != nil {
+		return nil, err
 	}
 	if !parsed.OK {
 		return nil, &ymerrors.APIError{
@@ -0,0 +1,181 @@
+package ym
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+)
+
+// TransferDirection tells whether progress refers to the request or the response body.
+type TransferDirection int
+
+const (
+	Upload TransferDirection = iota
+	Download
+)
+
+// Progress is a snapshot of a body transfer. Every attempt starts a new transfer
+// from zero, so a retry is reported as Transferred going back to 0 with a higher Attempt.
+type Progress struct {
+	Direction   TransferDirection
+	Attempt     int
+	Transferred int64
+	// Total is the body size in bytes, or -1 when it is unknown.
+	Total int64
+}
+
+// ProgressFunc receives progress updates. It is called synchronously from the
+// transfer and must not block.
+type ProgressFunc func(Progress)
+
+// transfer tracks one attempt: it reports progress and cancels the attempt when
+// no bytes move for the stall timeout.
+type transfer struct {
+	progress ProgressFunc
+	attempt  int
+	cancel   context.CancelCauseFunc
+
+	mu    sync.Mutex
+	timer *time.Timer
+	stall time.Duration
+}
+
+type progressReader struct {
+	io.ReadCloser
+	ctx       context.Context
+	t         *transfer
+	direction TransferDirection
+	total     int64
+	n         atomic.Int64
+	onEOF     func()
+	eofOnce   sync.Once
+}
+
+// ProgressChannel returns a ProgressFunc sending updates to ch. Updates are
+// dropped while ch is full, so a slow consumer never stalls the transfer.
+func ProgressChannel(ch chan<- Progress) ProgressFunc {
+	return func(p Progress) {
+		select {
+		case ch <- p:
+		default:
+		}
+	}
+}
+
+func (d TransferDirection) String() string {
+	if d == Download {
+		return "download"
+	}
+
+	return "upload"
+}
+
+// startTransfer derives the attempt context. It returns nil when req needs no tracking.
+func startTransfer(ctx context.Context, req *Request) (context.Context, *transfer) {
+	if req.Progress == nil && req.StallTimeout <= 0 {
+		return ctx, nil
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	return ctx, &transfer{
+		progress: req.Progress,
+		attempt:  req.Attempt,
+		cancel:   cancel,
+		stall:    req.StallTimeout,
+	}
+}
+
+// wrap tracks body. The stall timer runs until body is drained or closed.
+func (t *transfer) wrap(ctx context.Context, body io.ReadCloser, direction TransferDirection, total int64) *progressReader {
+	if total <= 0 {
+		total = -1
+	}
+	t.report(Progress{Direction: direction, Attempt: t.attempt, Total: total})
+	t.arm()
+
+	return &progressReader{ReadCloser: body, ctx: ctx, t: t, direction: direction, total: total, onEOF: t.disarm}
+}
+
+// finish releases the attempt context. Call it once the attempt is over.
+func (t *transfer) finish() {
+	t.disarm()
+	t.cancel(nil)
+}
+
+func (t *transfer) stalled(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ymerrors.ErrTransferStalled)
+}
+
+// err replaces a cancellation caused by a stall with ymerrors.ErrTransferStalled.
+func (t *transfer) err(ctx context.Context, err error) error {
+	if t.stalled(ctx) {
+		return fmt.Errorf("%w: no progress for %s", ymerrors.ErrTransferStalled, t.stall)
+	}
+
+	return err
+}
+
+func (t *transfer) report(p Progress) {
+	if t.progress != nil {
+		t.progress(p)
+	}
+}
+
+func (t *transfer) arm() {
+	if t.stall <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.timer == nil {
+		t.timer = time.AfterFunc(t.stall, func() { t.cancel(ymerrors.ErrTransferStalled) })
+
+		return
+	}
+	t.timer.Reset(t.stall)
+}
+
+func (t *transfer) disarm() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.t.arm()
+		r.t.report(Progress{Direction: r.direction, Attempt: r.t.attempt, Transferred: r.n.Add(int64(n)), Total: r.total})
+	}
+	if err != nil {
+		r.eofOnce.Do(r.onEOF)
+		if !errors.Is(err, io.EOF) {
+			err = r.t.err(r.ctx, err)
+		}
+	}
+
+	return n, err
+}
+
+// downloadBody finishes the transfer when the response body is closed.
+type downloadBody struct {
+	*progressReader
+	once sync.Once
+}
+
+func (b *downloadBody) Close() error {
+	err := b.progressReader.Close()
+	b.once.Do(b.t.finish)
+
+	return err
+}
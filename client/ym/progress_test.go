@@ -0,0 +1,163 @@
+package ym
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+)
+
+// ctxDoer mimics a transport that gives up on a request once its context is done.
+type ctxDoer struct {
+	resp func(req *http.Request) *http.Response
+}
+
+func (d ctxDoer) Do(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		done := make(chan error, 1)
+		go func() {
+			_, err := io.Copy(io.Discard, req.Body)
+			done <- err
+		}()
+		select {
+		case <-req.Context().Done():
+			req.Body.Close()
+
+			return nil, req.Context().Err()
+		case err := <-done:
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return d.resp(req), nil
+}
+
+// blockingReader blocks until its context is done, like a stalled network read.
+type blockingReader struct {
+	ctx     context.Context
+	release chan struct{}
+}
+
+func (r blockingReader) Read([]byte) (int, error) {
+	select {
+	case <-r.ctx.Done():
+		return 0, r.ctx.Err()
+	case <-r.release:
+		return 0, io.EOF
+	}
+}
+
+func (r blockingReader) Close() error { return nil }
+
+func TestProgressRestartsOnRetry(t *testing.T) {
+	doer := &readingDoer{responses: []*http.Response{
+		newResponse(http.StatusServiceUnavailable, `{"ok":false}`, nil),
+		newResponse(http.StatusOK, `{"ok":true}`, nil),
+	}}
+	req, err := NewMultipartRequest(http.MethodPost, "/upload", nil,
+		[]MultipartFile{{Field: "document", Filename: "a.bin", Source: BytesSource(bytes.Repeat([]byte("x"), 1<<16))}},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var mu sync.Mutex
+	var events []Progress
+	req.Progress = func(p Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+		if p.Direction == Upload {
+			events = append(events, p)
+		}
+	}
+
+	resp, err := uploadClient(doer).Do(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	last := map[int]Progress{}
+	starts := 0
+	for _, p := range events {
+		if p.Transferred == 0 {
+			starts++
+		}
+		if p.Total != req.ContentLength {
+			t.Fatalf("expected total %d, got %d", req.ContentLength, p.Total)
+		}
+		last[p.Attempt] = p
+	}
+	if starts != 2 {
+		t.Fatalf("expected a reset per attempt, got %d", starts)
+	}
+	for attempt := 1; attempt <= 2; attempt++ {
+		if last[attempt].Transferred != req.ContentLength {
+			t.Fatalf("attempt %d transferred %d of %d", attempt, last[attempt].Transferred, req.ContentLength)
+		}
+	}
+}
+
+func TestDownloadStallAborts(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	doer := ctxDoer{resp: func(req *http.Request) *http.Response {
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{},
+			Body:          blockingReader{ctx: req.Context(), release: release},
+			ContentLength: 10,
+		}
+	}}
+	client := NewClientWithHTTP(Config{BaseURL: "http://example.com"}, doer)
+
+	req := NewRequest(http.MethodGet, "/file")
+	req.StallTimeout = 20 * time.Millisecond
+	var total int64
+	req.Progress = func(p Progress) { total = p.Total }
+	resp, err := client.Do(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	_, err = io.ReadAll(resp.Body)
+	if !errors.Is(err, ymerrors.ErrTransferStalled) {
+		t.Fatalf("expected ErrTransferStalled, got %v", err)
+	}
+	if total != 10 {
+		t.Fatalf("expected total 10, got %d", total)
+	}
+}
+
+func TestUploadStallAborts(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	doer := ctxDoer{resp: func(*http.Request) *http.Response {
+		return newResponse(http.StatusOK, `{"ok":true}`, nil)
+	}}
+	client := NewClientWithHTTP(Config{BaseURL: "http://example.com"}, doer)
+
+	source := OpenerSource(func() (io.ReadCloser, error) {
+		return blockingReader{ctx: context.Background(), release: release}, nil
+	})
+	req, err := NewMultipartRequest(http.MethodPost, "/upload", nil,
+		[]MultipartFile{{Field: "document", Filename: "a.bin", Source: source}},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req.StallTimeout = 20 * time.Millisecond
+
+	_, err = client.Do(context.Background(), req)
+	if !errors.Is(err, ymerrors.ErrTransferStalled) {
+		t.Fatalf("expected ErrTransferStalled, got %v", err)
+	}
+}
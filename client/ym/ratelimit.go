@@ -0,0 +1,278 @@
+package ym
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+)
+
+// maxIdleBuckets bounds the number of per-recipient buckets kept before the least
+// recently used idle ones are dropped.
+const maxIdleBuckets = 10000
+
+// Limit describes a token bucket refilled at Rate tokens per second up to Burst tokens.
+// A zero Rate means the bucket does not pace requests.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+type RateLimiterConfig struct {
+	// Endpoint is the limit applied to every endpoint path separately.
+	Endpoint Limit
+	// Endpoints overrides Endpoint for specific paths, e.g. "/bot/v1/messages/sendText/".
+	Endpoints map[string]Limit
+	// Recipient is the limit applied to every ChatID and UserLogin separately.
+	Recipient Limit
+	// LearnRetryAfter pauses the endpoint and recipient buckets for the Retry-After
+	// duration reported by a 429 response.
+	LearnRetryAfter bool
+	// OnWait is called whenever a request had to wait before being sent.
+	OnWait func(req *Request, wait time.Duration)
+}
+
+// RateLimiterStats reports how much the limiter delayed outgoing requests.
+type RateLimiterStats struct {
+	Requests  int64
+	Waits     int64
+	TotalWait time.Duration
+	MaxWait   time.Duration
+	Throttled int64
+}
+
+// RateLimiter paces outgoing requests before they are sent, per endpoint and per recipient.
+type RateLimiter struct {
+	cfg     RateLimiterConfig
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	// lru orders the buckets from the most to the least recently used.
+	lru   *list.List
+	stats RateLimiterStats
+}
+
+func NewRateLimiter(cfg RateLimiterConfig) *RateLimiter {
+	return &RateLimiter{
+		cfg:     cfg,
+		buckets: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Interceptor returns a per-attempt interceptor that waits for the limiter
+// before every attempt and learns from rate limited responses.
+func (l *RateLimiter) Interceptor() Interceptor {
+	return l.interceptor(nil)
+}
+
+func (l *RateLimiter) interceptor(logger Logger) Interceptor {
+	return func(ctx context.Context, req *Request, next Invoker) (*http.Response, error) {
+		if err := l.wait(ctx, req, logger); err != nil {
+			return nil, err
+		}
+		resp, err := next(ctx, req)
+		l.Observe(req, err)
+
+		return resp, err
+	}
+}
+
+// Wait blocks until req may be sent or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context, req *Request) error {
+	return l.wait(ctx, req, nil)
+}
+
+func (l *RateLimiter) wait(ctx context.Context, req *Request, logger Logger) error {
+	now := time.Now()
+	keys := l.keys(req)
+
+	l.mu.Lock()
+	var wait time.Duration
+	reserved := make([]*bucket, 0, len(keys))
+	for _, key := range keys {
+		b := l.bucket(key, now)
+		if d := b.reserve(now); d > wait {
+			wait = d
+		}
+		reserved = append(reserved, b)
+	}
+	l.stats.Requests++
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	if l.cfg.OnWait != nil {
+		l.cfg.OnWait(req, wait)
+	}
+	if logger != nil {
+		logger.Log(ctx, LevelDebug, "waiting for rate limiter", "method", req.Method, "endpoint", req.Endpoint(), "wait", wait)
+	}
+
+	err := Sleep(ctx, wait)
+
+	l.mu.Lock()
+	l.stats.Waits++
+	l.stats.TotalWait += wait
+	if wait > l.stats.MaxWait {
+		l.stats.MaxWait = wait
+	}
+	if err != nil {
+		for _, b := range reserved {
+			b.cancel()
+		}
+	}
+	l.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("yandex-messenger/client: %w while waiting for rate limiter on %s %s", err, req.Method, req.Path)
+	}
+
+	return nil
+}
+
+// Observe records the outcome of an attempt. Rate limited responses pause the
+// request buckets when LearnRetryAfter is enabled.
+func (l *RateLimiter) Observe(req *Request, err error) {
+	var apiErr *ymerrors.APIError
+	if !errors.As(err, &apiErr) || apiErr.Kind != ymerrors.KindRateLimited {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stats.Throttled++
+	if !l.cfg.LearnRetryAfter || apiErr.RetryAfter <= 0 {
+		return
+	}
+	now := time.Now()
+	until := now.Add(apiErr.RetryAfter)
+	for _, key := range l.keys(req) {
+		b := l.bucket(key, now)
+		if until.After(b.blockedUntil) {
+			b.blockedUntil = until
+		}
+	}
+}
+
+// Stats returns a snapshot of the wait statistics.
+func (l *RateLimiter) Stats() RateLimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.stats
+}
+
+func (l *RateLimiter) keys(req *Request) []string {
+	keys := []string{"endpoint:" + req.Endpoint()}
+	if req.ChatID != "" {
+		keys = append(keys, "chat:"+string(req.ChatID))
+	}
+	if req.Login != "" {
+		keys = append(keys, "login:"+string(req.Login))
+	}
+
+	return keys
+}
+
+// bucket returns the bucket for key, creating it if needed. Callers must hold l.mu.
+func (l *RateLimiter) bucket(key string, now time.Time) *bucket {
+	if e, ok := l.buckets[key]; ok {
+		l.lru.MoveToFront(e)
+
+		return e.Value.(*bucket)
+	}
+	// Evicting from the back stops at the first busy bucket, so the cost is
+	// constant per created bucket.
+	for len(l.buckets) >= maxIdleBuckets {
+		oldest := l.lru.Back()
+		b := oldest.Value.(*bucket)
+		if !b.idle(now) {
+			break
+		}
+		delete(l.buckets, b.key)
+		l.lru.Remove(oldest)
+	}
+
+	limit := l.cfg.Recipient
+	if path, ok := strings.CutPrefix(key, "endpoint:"); ok {
+		limit = l.cfg.Endpoint
+		if override, ok := l.cfg.Endpoints[path]; ok {
+			limit = override
+		}
+	}
+	b := newBucket(key, limit, now)
+	l.buckets[key] = l.lru.PushFront(b)
+
+	return b
+}
+
+type bucket struct {
+	key          string
+	rate         float64
+	burst        float64
+	tokens       float64
+	last         time.Time
+	blockedUntil time.Time
+}
+
+func newBucket(key string, limit Limit, now time.Time) *bucket {
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &bucket{
+		key:    key,
+		rate:   limit.Rate,
+		burst:  burst,
+		tokens: burst,
+		last:   now,
+	}
+}
+
+// reserve takes a token and returns how long the caller must wait before using it.
+func (b *bucket) reserve(now time.Time) time.Duration {
+	var wait time.Duration
+	if b.rate > 0 {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+		b.tokens--
+		if b.tokens < 0 {
+			wait = time.Duration(-b.tokens / b.rate * float64(time.Second))
+		}
+	}
+	if blocked := b.blockedUntil.Sub(now); blocked > wait {
+		wait = blocked
+	}
+
+	return wait
+}
+
+// cancel returns a token taken by reserve.
+func (b *bucket) cancel() {
+	if b.rate > 0 {
+		b.tokens++
+	}
+}
+
+func (b *bucket) idle(now time.Time) bool {
+	if now.Before(b.blockedUntil) {
+		return false
+	}
+	if b.rate <= 0 {
+		return true
+	}
+
+	return b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst
+}
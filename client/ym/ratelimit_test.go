@@ -0,0 +1,103 @@
+package ym
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+)
+
+func TestRateLimiterPacesPerEndpoint(t *testing.T) {
+	limiter := NewRateLimiter(RateLimiterConfig{
+		Endpoint: Limit{Rate: 50, Burst: 1},
+	})
+	req := NewRequest(http.MethodPost, "/bot/v1/messages/sendText?x=1")
+
+	started := time.Now()
+	for range 3 {
+		if err := limiter.Wait(context.Background(), req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if elapsed := time.Since(started); elapsed < 30*time.Millisecond {
+		t.Fatalf("expected requests to be paced, took %v", elapsed)
+	}
+
+	stats := limiter.Stats()
+	if stats.Requests != 3 || stats.Waits != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if stats.MaxWait <= 0 || stats.TotalWait < stats.MaxWait {
+		t.Fatalf("unexpected wait stats: %+v", stats)
+	}
+}
+
+func TestRateLimiterSeparatesRecipients(t *testing.T) {
+	limiter := NewRateLimiter(RateLimiterConfig{
+		Recipient: Limit{Rate: 0.001, Burst: 1},
+	})
+
+	for _, chat := range []ChatID{"c1", "c2", "c3"} {
+		req := NewRequest(http.MethodPost, "/bot/v1/messages/sendText")
+		req.ChatID = chat
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		err := limiter.Wait(ctx, req)
+		cancel()
+		if err != nil {
+			t.Fatalf("first request to %s must not wait: %v", chat, err)
+		}
+	}
+
+	req := NewRequest(http.MethodPost, "/bot/v1/messages/sendText")
+	req.ChatID = "c1"
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx, req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected second request to c1 to wait, got %v", err)
+	}
+}
+
+func TestRateLimiterLearnsRetryAfter(t *testing.T) {
+	limiter := NewRateLimiter(RateLimiterConfig{LearnRetryAfter: true})
+	req := NewRequest(http.MethodGet, "/bot/v1/messages/getUpdates")
+
+	limiter.Observe(req, &ymerrors.APIError{Kind: ymerrors.KindRateLimited, RetryAfter: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx, req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected endpoint to be paused after 429, got %v", err)
+	}
+	if got := limiter.Stats().Throttled; got != 1 {
+		t.Fatalf("expected one throttled response, got %d", got)
+	}
+
+	other := NewRequest(http.MethodGet, "/bot/v1/chats/create/")
+	if err := limiter.Wait(context.Background(), other); err != nil {
+		t.Fatalf("other endpoints must not be paused: %v", err)
+	}
+}
+
+func TestRateLimiterEvictsLeastRecentlyUsedIdleBuckets(t *testing.T) {
+	limiter := NewRateLimiter(RateLimiterConfig{})
+	now := time.Now()
+	for i := range maxIdleBuckets {
+		limiter.bucket(fmt.Sprintf("chat:%d", i), now)
+	}
+	limiter.bucket("chat:0", now)
+	limiter.bucket("chat:new", now)
+
+	if len(limiter.buckets) != maxIdleBuckets {
+		t.Fatalf("expected %d buckets, got %d", maxIdleBuckets, len(limiter.buckets))
+	}
+	if _, ok := limiter.buckets["chat:1"]; ok {
+		t.Fatal("expected the least recently used bucket to be dropped")
+	}
+	if _, ok := limiter.buckets["chat:0"]; !ok {
+		t.Fatal("expected a recently used bucket to be kept")
+	}
+}
@@ -0,0 +1,87 @@
+package ym
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Request describes a single logical API call routed through the client pipeline.
+// The same Request value is passed to every interceptor and to every retry attempt.
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	// GetBody returns a fresh body for every attempt. Nil means the request has no body.
+	GetBody func() (io.ReadCloser, error)
+	// ContentLength is the body size in bytes, or 0 when it is unknown.
+	ContentLength int64
+	// ChatID and Login identify the recipient of the call, if any. They are set by
+	// services so that interceptors can apply per-recipient policies.
+	ChatID ChatID
+	Login  UserLogin
+	// Attempt is the 1-based number of the current attempt, maintained by the retry interceptor.
+	Attempt int
+	// Progress, if set, receives upload progress of the request body and download
+	// progress of a successful response body, restarting from zero on every attempt.
+	Progress ProgressFunc
+	// StallTimeout, if positive, aborts an attempt whose body transfer makes no
+	// progress for this long with ymerrors.ErrTransferStalled.
+	StallTimeout time.Duration
+}
+
+// NewRequest creates a request without a body.
+func NewRequest(method, path string) *Request {
+	return &Request{
+		Method: method,
+		Path:   path,
+		Header: http.Header{},
+	}
+}
+
+// NewJSONRequest creates a request whose body is body encoded as JSON.
+func NewJSONRequest(method, path string, body any) (*Request, error) {
+	req := NewRequest(method, path)
+	req.Header.Set("Content-Type", "application/json")
+	if body == nil {
+		return req, nil
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("yandex-messenger/client: marshal request body: %w", err)
+	}
+	req.SetBytes(payload)
+
+	return req, nil
+}
+
+// NewBytesRequest creates a request with an in-memory body of the given content type.
+func NewBytesRequest(method, path, contentType string, body []byte) *Request {
+	req := NewRequest(method, path)
+	req.Header.Set("Content-Type", contentType)
+	req.SetBytes(body)
+
+	return req
+}
+
+// SetBytes makes payload the request body. The payload is replayed on every attempt.
+func (r *Request) SetBytes(payload []byte) {
+	r.ContentLength = int64(len(payload))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(payload)), nil
+	}
+}
+
+// Endpoint returns the request path without the query string.
+func (r *Request) Endpoint() string {
+	if i := strings.IndexByte(r.Path, '?'); i >= 0 {
+		return r.Path[:i]
+	}
+
+	return r.Path
+}
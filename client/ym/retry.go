@@ -0,0 +1,324 @@
+package ym
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+)
+
+type retryCtxKey struct{}
+
+// RetryPolicy decides whether and when failed attempts are retried.
+// A policy is safe for concurrent use; its retry budget is shared by all calls.
+type RetryPolicy struct {
+	strategy  ymerrors.RetryStrategy
+	rateLimit ymerrors.RateLimitHandling
+	budget    *retryBudget
+	logger    Logger
+}
+
+// NewRetryPolicy creates a policy from the retry strategy and rate limit handling config.
+func NewRetryPolicy(retryCfg ymerrors.RetryStrategy, rateCfg ymerrors.RateLimitHandling) *RetryPolicy {
+	return &RetryPolicy{
+		strategy:  retryCfg,
+		rateLimit: rateCfg,
+		budget:    newRetryBudget(retryCfg.Budget),
+	}
+}
+
+// RetryInterceptor retries failed attempts according to the retry strategy and
+// rate limit handling. It must be placed outside of per-attempt interceptors.
+func RetryInterceptor(retryCfg ymerrors.RetryStrategy, rateCfg ymerrors.RateLimitHandling) Interceptor {
+	return NewRetryPolicy(retryCfg, rateCfg).Interceptor()
+}
+
+// WithRetryStrategy overrides the client retry strategy for calls made with the returned context.
+// Zero fields of strategy inherit the client strategy, so that e.g. only MaxAttempts can be
+// changed: zero durations, an empty Jitter and a nil RetryHTTP take the client values, and
+// RetryNetwork can only be enabled. The client retry budget still applies.
+func WithRetryStrategy(ctx context.Context, strategy ymerrors.RetryStrategy) context.Context {
+	return context.WithValue(ctx, retryCtxKey{}, strategy)
+}
+
+// WithoutRetries disables retries for calls made with the returned context,
+// e.g. for non-idempotent sends.
+func WithoutRetries(ctx context.Context) context.Context {
+	return WithRetryStrategy(ctx, ymerrors.RetryStrategy{MaxAttempts: 1})
+}
+
+// Interceptor returns the interceptor executing the policy.
+func (p *RetryPolicy) Interceptor() Interceptor {
+	return func(ctx context.Context, req *Request, next Invoker) (*http.Response, error) {
+		strategy := p.strategy
+		if override, ok := ctx.Value(retryCtxKey{}).(ymerrors.RetryStrategy); ok {
+			strategy = mergeStrategy(override, strategy)
+		}
+
+		attempts := strategy.MaxAttempts
+		if attempts < 1 {
+			attempts = 1
+		}
+		backoff := newBackoff(strategy)
+		started := time.Now()
+
+		for attempt := 1; attempt <= attempts; attempt++ {
+			req.Attempt = attempt
+
+			resp, err := next(ctx, req)
+			if err == nil {
+				return resp, nil
+			}
+			if ctx.Err() != nil || attempt == attempts {
+				return nil, err
+			}
+
+			wait, retry := p.delay(strategy, backoff, err)
+			if !retry {
+				return nil, err
+			}
+			if strategy.MaxElapsed > 0 && time.Since(started)+wait > strategy.MaxElapsed {
+				p.log(ctx, LevelWarn, "retry skipped: max elapsed time reached", req, err, "elapsed", time.Since(started))
+
+				return nil, err
+			}
+			if !p.budget.take() {
+				p.log(ctx, LevelWarn, "retry skipped: retry budget exhausted", req, err)
+
+				return nil, err
+			}
+			p.log(ctx, LevelInfo, "retrying request", req, err, "wait", wait)
+			if waitErr := Sleep(ctx, wait); waitErr != nil {
+				return nil, fmt.Errorf(
+					"yandex-messenger/client: %w while waiting to retry %s %s: %w", waitErr, req.Method, req.Path, err,
+				)
+			}
+		}
+
+		return nil, fmt.Errorf("yandex-messenger/client: retries exhausted for %s %s", req.Method, req.Path)
+	}
+}
+
+// mergeStrategy fills the zero fields of override from base.
+func mergeStrategy(override, base ymerrors.RetryStrategy) ymerrors.RetryStrategy {
+	if override.MaxAttempts == 0 {
+		override.MaxAttempts = base.MaxAttempts
+	}
+	if override.InitialBackoff == 0 {
+		override.InitialBackoff = base.InitialBackoff
+	}
+	if override.MaxBackoff == 0 {
+		override.MaxBackoff = base.MaxBackoff
+	}
+	if override.RetryHTTP == nil {
+		override.RetryHTTP = base.RetryHTTP
+	}
+	if override.Jitter == "" {
+		override.Jitter = base.Jitter
+	}
+	if override.MaxElapsed == 0 {
+		override.MaxElapsed = base.MaxElapsed
+	}
+	override.RetryNetwork = override.RetryNetwork || base.RetryNetwork
+
+	return override
+}
+
+func (p *RetryPolicy) log(ctx context.Context, level LogLevel, msg string, req *Request, err error, keyvals ...any) {
+	if p.logger == nil {
+		return
+	}
+	keyvals = append([]any{
+		"method", req.Method, "endpoint", req.Endpoint(), "attempt", req.Attempt, "error_kind", ymerrors.KindOf(err).String(), "error", err,
+	}, keyvals...)
+	p.logger.Log(ctx, level, msg, keyvals...)
+}
+
+func (p *RetryPolicy) delay(strategy ymerrors.RetryStrategy, backoff *backoff, err error) (time.Duration, bool) {
+	var apiErr *ymerrors.APIError
+	if !errors.As(err, &apiErr) {
+		var netErr net.Error
+		if errors.As(err, &netErr) && strategy.RetryNetwork {
+			return backoff.next(), true
+		}
+
+		return 0, false
+	}
+
+	if apiErr.Kind == ymerrors.KindRateLimited {
+		sleep := p.rateLimit.DefaultBackoff
+		if p.rateLimit.UseRetryAfter && apiErr.RetryAfter > 0 {
+			sleep = apiErr.RetryAfter
+		}
+		if sleep <= 0 {
+			sleep = time.Second
+		}
+
+		return sleep, true
+	}
+
+	if shouldRetryHTTP(apiErr.HTTPStatus, strategy.RetryHTTP) {
+		return backoff.next(), true
+	}
+
+	return 0, false
+}
+
+type backoff struct {
+	mode     ymerrors.JitterMode
+	initial  time.Duration
+	maximum  time.Duration
+	current  time.Duration
+	previous time.Duration
+}
+
+func newBackoff(strategy ymerrors.RetryStrategy) *backoff {
+	initial := strategy.InitialBackoff
+	if initial <= 0 {
+		initial = 500 * time.Millisecond
+	}
+
+	return &backoff{
+		mode:     strategy.Jitter,
+		initial:  initial,
+		maximum:  strategy.MaxBackoff,
+		current:  initial,
+		previous: initial,
+	}
+}
+
+// next returns the delay before the upcoming retry and advances the backoff state.
+func (b *backoff) next() time.Duration {
+	switch b.mode {
+	case ymerrors.JitterFull:
+		wait := randomBetween(0, b.current)
+		b.current = nextBackoff(b.current, b.maximum)
+
+		return wait
+	case ymerrors.JitterDecorrelated:
+		wait := randomBetween(b.initial, 3*b.previous)
+		if b.maximum > 0 && wait > b.maximum {
+			wait = b.maximum
+		}
+		b.previous = wait
+
+		return wait
+	default:
+		wait := b.current
+		b.current = nextBackoff(b.current, b.maximum)
+
+		return wait
+	}
+}
+
+// RetryDelay returns the delay before retrying a failed attempt: the Retry-After
+// hint of err if any, otherwise base doubled for every attempt after the first,
+// capped at maximum.
+func RetryDelay(attempt int, base, maximum time.Duration, err error) time.Duration {
+	var apiErr *ymerrors.APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter
+	}
+	wait := base << max(attempt-1, 0)
+	if wait <= 0 || wait > maximum {
+		wait = maximum
+	}
+
+	return wait
+}
+
+func randomBetween(low, high time.Duration) time.Duration {
+	if high <= low {
+		return low
+	}
+
+	return low + rand.N(high-low+1)
+}
+
+func nextBackoff(current, maximum time.Duration) time.Duration {
+	if current <= 0 {
+		current = 500 * time.Millisecond
+	}
+	next := current * 2
+	if maximum > 0 && next > maximum {
+		return maximum
+	}
+
+	return next
+}
+
+func shouldRetryHTTP(status int, list []int) bool {
+	for _, s := range list {
+		if status == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sleep waits for d or until ctx is done, whichever happens first, and returns ctx.Err() in the latter case.
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type retryBudget struct {
+	mu       sync.Mutex
+	capacity float64
+	refill   float64
+	tokens   float64
+	lastFill time.Time
+}
+
+func newRetryBudget(cfg ymerrors.RetryBudget) *retryBudget {
+	if cfg.MaxTokens <= 0 {
+		return nil
+	}
+
+	return &retryBudget{
+		capacity: cfg.MaxTokens,
+		refill:   cfg.RefillPerSecond,
+		tokens:   cfg.MaxTokens,
+		lastFill: time.Now(),
+	}
+}
+
+// take spends one token and reports whether a retry is allowed. A nil budget always allows.
+func (b *retryBudget) take() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.lastFill).Seconds() * b.refill
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.lastFill = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+
+	return true
+}
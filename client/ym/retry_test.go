@@ -0,0 +1,209 @@
+package ym
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+	"github.com/rekurt/ymsdk/internal/testutil"
+)
+
+func TestRetryWaitHonoursContextCancel(t *testing.T) {
+	client := NewClientWithHTTP(Config{
+		BaseURL: "http://example.com",
+		ErrorHandling: ymerrors.ErrorHandlingConfig{
+			RetryStrategy: ymerrors.RetryStrategy{MaxAttempts: 2},
+			RateLimitHandling: ymerrors.RateLimitHandling{
+				UseRetryAfter:  true,
+				DefaultBackoff: time.Hour,
+			},
+		},
+	}, &testutil.FakeDoer{
+		Responses: []*http.Response{
+			newResponse(http.StatusTooManyRequests, `{"ok":false}`, map[string]string{"Retry-After": "3600"}),
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	_, err := client.DoRequest(ctx, http.MethodGet, "/path", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if !errors.Is(err, ymerrors.ErrRateLimited) {
+		t.Fatalf("expected last attempt error to be wrapped, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Fatalf("wait was not interrupted, took %v", elapsed)
+	}
+}
+
+func TestWithoutRetriesOverridesClientStrategy(t *testing.T) {
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			newResponse(http.StatusBadGateway, `{"ok":false}`, nil),
+			newResponse(http.StatusOK, `{"ok":true}`, nil),
+		},
+	}
+	client := NewClientWithHTTP(Config{
+		BaseURL: "http://example.com",
+		ErrorHandling: ymerrors.ErrorHandlingConfig{
+			RetryStrategy: ymerrors.RetryStrategy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+		},
+	}, doer)
+
+	_, err := client.DoRequest(WithoutRetries(context.Background()), http.MethodPost, "/path", nil)
+	if !errors.Is(err, ymerrors.ErrNetworkError) {
+		t.Fatalf("expected 502 error, got %v", err)
+	}
+	if len(doer.Requests) != 1 {
+		t.Fatalf("expected a single attempt, got %d", len(doer.Requests))
+	}
+}
+
+func TestWithRetryStrategyInheritsClientFields(t *testing.T) {
+	base := ymerrors.RetryStrategy{
+		MaxAttempts:    2,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		RetryHTTP:      []int{http.StatusBadGateway},
+		RetryNetwork:   true,
+		Jitter:         ymerrors.JitterFull,
+		MaxElapsed:     time.Second,
+	}
+	got := mergeStrategy(ymerrors.RetryStrategy{MaxAttempts: 5}, base)
+	want := base
+	want.MaxAttempts = 5
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected merged strategy %+v", got)
+	}
+
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			newResponse(http.StatusBadGateway, `{"ok":false}`, nil),
+			newResponse(http.StatusBadGateway, `{"ok":false}`, nil),
+			newResponse(http.StatusOK, `{"ok":true}`, nil),
+		},
+	}
+	client := NewClientWithHTTP(Config{
+		BaseURL: "http://example.com",
+		ErrorHandling: ymerrors.ErrorHandlingConfig{
+			RetryStrategy: ymerrors.RetryStrategy{MaxAttempts: 1, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+		},
+	}, doer)
+
+	started := time.Now()
+	ctx := WithRetryStrategy(context.Background(), ymerrors.RetryStrategy{MaxAttempts: 3})
+	if _, err := client.DoRequest(ctx, http.MethodPost, "/path", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 200*time.Millisecond {
+		t.Fatalf("expected the client backoff to apply, took %v", elapsed)
+	}
+}
+
+func TestRetryBudgetStopsRetries(t *testing.T) {
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			newResponse(http.StatusBadGateway, `{"ok":false}`, nil),
+			newResponse(http.StatusBadGateway, `{"ok":false}`, nil),
+			newResponse(http.StatusBadGateway, `{"ok":false}`, nil),
+			newResponse(http.StatusOK, `{"ok":true}`, nil),
+		},
+	}
+	client := NewClientWithHTTP(Config{
+		BaseURL: "http://example.com",
+		ErrorHandling: ymerrors.ErrorHandlingConfig{
+			RetryStrategy: ymerrors.RetryStrategy{
+				MaxAttempts:    4,
+				InitialBackoff: time.Millisecond,
+				Budget:         ymerrors.RetryBudget{MaxTokens: 1},
+			},
+		},
+	}, doer)
+
+	_, err := client.DoRequest(context.Background(), http.MethodGet, "/path", nil)
+	if err == nil {
+		t.Fatalf("expected error once budget is exhausted")
+	}
+	if len(doer.Requests) != 2 {
+		t.Fatalf("expected one retry allowed by budget, got %d attempts", len(doer.Requests))
+	}
+}
+
+func TestRetryMaxElapsed(t *testing.T) {
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			newResponse(http.StatusBadGateway, `{"ok":false}`, nil),
+			newResponse(http.StatusOK, `{"ok":true}`, nil),
+		},
+	}
+	client := NewClientWithHTTP(Config{
+		BaseURL: "http://example.com",
+		ErrorHandling: ymerrors.ErrorHandlingConfig{
+			RetryStrategy: ymerrors.RetryStrategy{
+				MaxAttempts:    2,
+				InitialBackoff: time.Second,
+				MaxElapsed:     100 * time.Millisecond,
+			},
+		},
+	}, doer)
+
+	_, err := client.DoRequest(context.Background(), http.MethodGet, "/path", nil)
+	if err == nil {
+		t.Fatalf("expected error when backoff exceeds max elapsed time")
+	}
+	if len(doer.Requests) != 1 {
+		t.Fatalf("expected no retry, got %d attempts", len(doer.Requests))
+	}
+}
+
+func TestBackoffJitterBounds(t *testing.T) {
+	full := newBackoff(ymerrors.RetryStrategy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Jitter:         ymerrors.JitterFull,
+	})
+	ceiling := 100 * time.Millisecond
+	for range 10 {
+		wait := full.next()
+		if wait < 0 || wait > ceiling {
+			t.Fatalf("full jitter delay %v out of [0, %v]", wait, ceiling)
+		}
+		ceiling = nextBackoff(ceiling, time.Second)
+	}
+
+	decorrelated := newBackoff(ymerrors.RetryStrategy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Jitter:         ymerrors.JitterDecorrelated,
+	})
+	for range 10 {
+		wait := decorrelated.next()
+		if wait < 100*time.Millisecond || wait > time.Second {
+			t.Fatalf("decorrelated delay %v out of [100ms, 1s]", wait)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	if got := RetryDelay(1, time.Second, 10*time.Second, errors.New("boom")); got != time.Second {
+		t.Fatalf("expected base delay on the first attempt, got %v", got)
+	}
+	if got := RetryDelay(3, time.Second, 10*time.Second, nil); got != 4*time.Second {
+		t.Fatalf("expected doubled delay, got %v", got)
+	}
+	if got := RetryDelay(100, time.Second, 10*time.Second, nil); got != 10*time.Second {
+		t.Fatalf("expected delay capped at the maximum, got %v", got)
+	}
+	limited := &ymerrors.APIError{Kind: ymerrors.KindRateLimited, RetryAfter: 30 * time.Second}
+	if got := RetryDelay(1, time.Second, 10*time.Second, limited); got != 30*time.Second {
+		t.Fatalf("expected Retry-After to win, got %v", got)
+	}
+}
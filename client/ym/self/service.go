@@ -2,8 +2,6 @@ package self
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"net/http"
 
 	"github.com/rekurt/ymsdk/client/ym"
@@ -38,8 +36,8 @@ func (s *Service) Update(ctx context.Context, req *SelfUpdateRequest) (*ym.BotSe
 		Login         string  `json:"login"`
 		Description   string  `json:"description"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-		return nil, fmt.Errorf("%w: decode self.update response: %w", ymerrors.ErrInvalidResponse, err)
+	if err := s.client.DecodeJSON(ctx, resp, &parsed, "self.update"); err != nil {
+		return nil, err
 	}
 	if !parsed.OK {
 		return nil, &ymerrors.APIError{
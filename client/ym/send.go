@@ -0,0 +1,59 @@
+package ym
+
+import "strconv"
+
+// MaxTextLength is the maximum length of a text message, in runes.
+const MaxTextLength = 6000
+
+// SendOptions are the delivery options shared by every sending method. The
+// messages and files services embed it into their request types; the
+// recipient (chat, login and thread) is set on the request itself.
+type SendOptions struct {
+	// PayloadID lets the server deduplicate repeated sends of the same message.
+	PayloadID string `json:"payload_id,omitempty"`
+	// ReplyMessageID makes the message a reply to another message of the chat.
+	ReplyMessageID MessageID `json:"reply_message_id,omitempty"`
+	// DisableNotification delivers the message silently.
+	DisableNotification bool `json:"disable_notification,omitempty"`
+	// Important marks the message as important for the recipients.
+	Important bool `json:"important,omitempty"`
+	// DisableWebPagePreview turns off link previews.
+	DisableWebPagePreview bool            `json:"disable_web_page_preview,omitempty"`
+	InlineKeyboard        InlineKeyboard  `json:"inline_keyboard,omitempty"`
+	SuggestButtons        *SuggestButtons `json:"suggest_buttons,omitempty"`
+}
+
+// Validate checks the keyboards of the options.
+func (o SendOptions) Validate() error {
+	return ValidateKeyboards(o.InlineKeyboard, o.SuggestButtons)
+}
+
+// Fields encodes the options as multipart form fields, using the same names
+// and values as the JSON encoding. Unset options are omitted.
+func (o SendOptions) Fields() ([]MultipartField, error) {
+	var fields []MultipartField
+	if o.PayloadID != "" {
+		fields = append(fields, MultipartField{Name: "payload_id", Value: o.PayloadID})
+	}
+	if o.ReplyMessageID != 0 {
+		fields = append(fields, MultipartField{Name: "reply_message_id", Value: strconv.FormatInt(int64(o.ReplyMessageID), 10)})
+	}
+	for _, flag := range []struct {
+		name string
+		set  bool
+	}{
+		{"disable_notification", o.DisableNotification},
+		{"important", o.Important},
+		{"disable_web_page_preview", o.DisableWebPagePreview},
+	} {
+		if flag.set {
+			fields = append(fields, MultipartField{Name: flag.name, Value: "true"})
+		}
+	}
+	keyboards, err := KeyboardFields(o.InlineKeyboard, o.SuggestButtons)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(fields, keyboards...), nil
+}
@@ -0,0 +1,172 @@
+package ym
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+)
+
+// TokenProvider returns the OAuth token for a request. It is consulted before every attempt.
+type TokenProvider interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenRefresher is implemented by providers that can fetch a fresh token
+// after the server rejected the current one.
+type TokenRefresher interface {
+	Refresh(ctx context.Context) (string, error)
+}
+
+// TokenProviderFunc adapts a function to TokenProvider.
+type TokenProviderFunc func(ctx context.Context) (string, error)
+
+func (f TokenProviderFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+type staticToken string
+
+func (t staticToken) Token(context.Context) (string, error) {
+	return string(t), nil
+}
+
+// String redacts the token so that a logged Config does not leak it.
+func (t staticToken) String() string {
+	return "[REDACTED]"
+}
+
+// GoString redacts the token for %#v as well.
+func (t staticToken) GoString() string {
+	return "[REDACTED]"
+}
+
+// StaticToken returns a provider that always returns token.
+func StaticToken(token string) TokenProvider {
+	return staticToken(token)
+}
+
+// EnvToken returns a provider that reads the token from the environment variable on every request.
+func EnvToken(name string) TokenProvider {
+	return TokenProviderFunc(func(context.Context) (string, error) {
+		token := strings.TrimSpace(os.Getenv(name))
+		if token == "" {
+			return "", fmt.Errorf("yandex-messenger/client: environment variable %s is empty", name)
+		}
+
+		return token, nil
+	})
+}
+
+// FileTokenProvider reads the token from a file and re-reads it when the file
+// modification time changes. The file is checked at most once per interval,
+// and immediately on Refresh.
+type FileTokenProvider struct {
+	path     string
+	interval time.Duration
+
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+	checked time.Time
+}
+
+// NewFileTokenProvider creates a provider reading path. It fails if the file cannot be read.
+func NewFileTokenProvider(path string, interval time.Duration) (*FileTokenProvider, error) {
+	p := &FileTokenProvider{path: path, interval: interval}
+	if _, err := p.Refresh(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *FileTokenProvider) Token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	fresh := time.Since(p.checked) < p.interval
+	token := p.token
+	p.mu.Unlock()
+
+	if fresh {
+		return token, nil
+	}
+
+	return p.Refresh(ctx)
+}
+
+// Refresh re-reads the token file if it changed since the last read.
+func (p *FileTokenProvider) Refresh(context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return "", fmt.Errorf("yandex-messenger/client: token file: %w", err)
+	}
+	p.checked = time.Now()
+	if p.token != "" && info.ModTime().Equal(p.modTime) {
+		return p.token, nil
+	}
+
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return "", fmt.Errorf("yandex-messenger/client: token file: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("yandex-messenger/client: token file %s is empty", p.path)
+	}
+	p.token = token
+	p.modTime = info.ModTime()
+
+	return token, nil
+}
+
+// TokenAuthInterceptor sets the Authorization header from provider on every attempt.
+// When the server rejects the token with ErrUnauthorized or ErrInvalidToken, the token
+// is re-fetched once and the attempt is repeated if the token changed.
+func TokenAuthInterceptor(provider TokenProvider) Interceptor {
+	return func(ctx context.Context, req *Request, next Invoker) (*http.Response, error) {
+		token, err := provider.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("yandex-messenger/client: token provider: %w", err)
+		}
+		setAuthorization(req, token)
+
+		resp, err := next(ctx, req)
+		if !errors.Is(err, ymerrors.ErrUnauthorized) && !errors.Is(err, ymerrors.ErrInvalidToken) {
+			return resp, err
+		}
+
+		fresh, refreshErr := refreshToken(ctx, provider)
+		if refreshErr != nil || fresh == token {
+			return nil, err
+		}
+		setAuthorization(req, fresh)
+
+		return next(ctx, req)
+	}
+}
+
+func refreshToken(ctx context.Context, provider TokenProvider) (string, error) {
+	if refresher, ok := provider.(TokenRefresher); ok {
+		return refresher.Refresh(ctx)
+	}
+
+	return provider.Token(ctx)
+}
+
+func setAuthorization(req *Request, token string) {
+	if token == "" {
+		req.Header.Del("Authorization")
+
+		return
+	}
+	req.Header.Set("Authorization", "OAuth "+token)
+}
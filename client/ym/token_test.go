@@ -0,0 +1,102 @@
+package ym
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+	"github.com/rekurt/ymsdk/internal/testutil"
+)
+
+func TestTokenProviderRefetchesOnUnauthorized(t *testing.T) {
+	tokens := []string{"old", "new"}
+	calls := 0
+	provider := TokenProviderFunc(func(context.Context) (string, error) {
+		token := tokens[min(calls, len(tokens)-1)]
+		calls++
+
+		return token, nil
+	})
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			newResponse(http.StatusUnauthorized, `{"ok":false}`, nil),
+			newResponse(http.StatusOK, `{"ok":true}`, nil),
+		},
+	}
+	client := NewClientWithHTTP(Config{BaseURL: "http://example.com", TokenProvider: provider}, doer)
+
+	resp, err := client.DoRequest(context.Background(), http.MethodGet, "/path", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if len(doer.Requests) != 2 {
+		t.Fatalf("expected one repeat after refresh, got %d requests", len(doer.Requests))
+	}
+	if got := doer.Requests[0].Header.Get("Authorization"); got != "OAuth old" {
+		t.Fatalf("unexpected first token: %s", got)
+	}
+	if got := doer.Requests[1].Header.Get("Authorization"); got != "OAuth new" {
+		t.Fatalf("unexpected refreshed token: %s", got)
+	}
+}
+
+func TestTokenProviderDoesNotRepeatWithSameToken(t *testing.T) {
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			newResponse(http.StatusForbidden, `{"ok":false}`, nil),
+			newResponse(http.StatusOK, `{"ok":true}`, nil),
+		},
+	}
+	client := NewClientWithHTTP(Config{BaseURL: "http://example.com", TokenProvider: StaticToken("t")}, doer)
+
+	_, err := client.DoRequest(context.Background(), http.MethodGet, "/path", nil)
+	if !errors.Is(err, ymerrors.ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken, got %v", err)
+	}
+	if len(doer.Requests) != 1 {
+		t.Fatalf("expected no repeat with an unchanged token, got %d requests", len(doer.Requests))
+	}
+}
+
+func TestFileTokenProviderReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("first\n"), 0o600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+	provider, err := NewFileTokenProvider(path, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token, _ := provider.Token(context.Background()); token != "first" {
+		t.Fatalf("unexpected token: %q", token)
+	}
+
+	if err := os.WriteFile(path, []byte("second"), 0o600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("touch token: %v", err)
+	}
+
+	if token, _ := provider.Token(context.Background()); token != "first" {
+		t.Fatalf("expected cached token within interval, got %q", token)
+	}
+	if token, _ := provider.Refresh(context.Background()); token != "second" {
+		t.Fatalf("expected reloaded token, got %q", token)
+	}
+}
+
+func TestEnvTokenEmpty(t *testing.T) {
+	t.Setenv("YM_TEST_TOKEN", "")
+	if _, err := EnvToken("YM_TEST_TOKEN").Token(context.Background()); err == nil {
+		t.Fatalf("expected error for empty variable")
+	}
+}
@@ -1,6 +1,9 @@
 package ym
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type ChatType string
 
@@ -89,6 +92,19 @@ type Update struct {
 	Image     *Image       `json:"image,omitempty"`
 	Gallery   []Image      `json:"gallery,omitempty"`
 	Document  *File        `json:"document,omitempty"`
+	// CallbackData is the payload of a pressed inline button.
+	CallbackData json.RawMessage `json:"callback_data,omitempty"`
+	// BotRequest carries non-message requests addressed to the bot, such as server actions.
+	BotRequest *BotRequest `json:"bot_request,omitempty"`
+}
+
+type BotRequest struct {
+	ServerAction *ServerAction `json:"server_action,omitempty"`
+}
+
+type ServerAction struct {
+	Name    string          `json:"name"`
+	Payload json.RawMessage `json:"payload,omitempty"`
 }
 
 // ToMessage converts an Update to a Message by promoting its fields.
@@ -98,10 +114,8 @@ func (u *Update) ToMessage() *Message {
 		return nil
 	}
 
-	return &Message{
+	msg := &Message{
 		ID:        u.MessageID,
-		Chat:      *u.Chat,
-		From:      *u.From,
 		Text:      u.Text,
 		Timestamp: u.Timestamp,
 		ThreadID:  u.ThreadID,
@@ -111,6 +125,15 @@ func (u *Update) ToMessage() *Message {
 		Gallery:   u.Gallery,
 		Document:  u.Document,
 	}
+	// Callback updates may arrive without chat or sender data.
+	if u.Chat != nil {
+		msg.Chat = *u.Chat
+	}
+	if u.From != nil {
+		msg.From = *u.From
+	}
+
+	return msg
 }
 
 type UserRef struct {
@@ -0,0 +1,110 @@
+package ym
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+)
+
+// UpdateKind classifies an update by its payload.
+type UpdateKind string
+
+const (
+	UpdateKindText     UpdateKind = "text"
+	UpdateKindSticker  UpdateKind = "sticker"
+	UpdateKindImage    UpdateKind = "image"
+	UpdateKindGallery  UpdateKind = "gallery"
+	UpdateKindDocument UpdateKind = "document"
+	UpdateKindForward  UpdateKind = "forward"
+	UpdateKindCallback UpdateKind = "callback"
+	UpdateKindUnknown  UpdateKind = "unknown"
+)
+
+// Callback is a button press or server action. MessageID and Chat identify the
+// message carrying the pressed button, From is the user who pressed it.
+type Callback struct {
+	// Data is the raw callback payload.
+	Data json.RawMessage
+	// Action is the server action name for bot requests, empty for inline buttons.
+	Action    string
+	MessageID MessageID
+	ThreadID  *ThreadID
+	Chat      *Chat
+	From      *Sender
+}
+
+// Kind classifies the update. Callbacks take precedence over message content,
+// and forwards over the forwarded content.
+func (u *Update) Kind() UpdateKind {
+	switch {
+	case u == nil:
+		return UpdateKindUnknown
+	case u.hasCallback():
+		return UpdateKindCallback
+	case u.Forward != nil:
+		return UpdateKindForward
+	case u.Sticker != nil:
+		return UpdateKindSticker
+	case len(u.Gallery) > 0:
+		return UpdateKindGallery
+	case u.Image != nil:
+		return UpdateKindImage
+	case u.Document != nil:
+		return UpdateKindDocument
+	case u.Text != "":
+		return UpdateKindText
+	default:
+		return UpdateKindUnknown
+	}
+}
+
+// Callback returns the button press carried by the update, if any.
+func (u *Update) Callback() (*Callback, bool) {
+	if u == nil || !u.hasCallback() {
+		return nil, false
+	}
+	cb := &Callback{
+		Data:      u.CallbackData,
+		MessageID: u.MessageID,
+		ThreadID:  u.ThreadID,
+		Chat:      u.Chat,
+		From:      u.From,
+	}
+	if !hasPayload(cb.Data) && u.BotRequest != nil && u.BotRequest.ServerAction != nil {
+		cb.Action = u.BotRequest.ServerAction.Name
+		cb.Data = u.BotRequest.ServerAction.Payload
+	}
+
+	return cb, true
+}
+
+// DecodeCallback unmarshals the callback payload of u into a T:
+//
+//	type vote struct{ Answer string `json:"answer"` }
+//	v, err := ym.DecodeCallback[vote](&update)
+//
+// It returns ymerrors.ErrNoCallback when the update carries no payload.
+func DecodeCallback[T any](u *Update) (T, error) {
+	var v T
+	cb, ok := u.Callback()
+	if !ok || !hasPayload(cb.Data) {
+		return v, ymerrors.ErrNoCallback
+	}
+	if err := json.Unmarshal(cb.Data, &v); err != nil {
+		return v, fmt.Errorf("%w: decode callback data: %w", ymerrors.ErrInvalidResponse, err)
+	}
+
+	return v, nil
+}
+
+func (u *Update) hasCallback() bool {
+	return hasPayload(u.CallbackData) || (u.BotRequest != nil && u.BotRequest.ServerAction != nil)
+}
+
+func hasPayload(data json.RawMessage) bool {
+	trimmed := bytes.TrimSpace(data)
+
+	return len(trimmed) > 0 && !bytes.Equal(trimmed, []byte("null"))
+}
@@ -0,0 +1,99 @@
+package ym
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+)
+
+func TestUpdateDecodesCallback(t *testing.T) {
+	raw := `{
+		"update_id": 7,
+		"message_id": 42,
+		"chat": {"id": "chat-1", "type": "group"},
+		"from": {"login": "alice@org"},
+		"callback_data": {"action": "vote", "option": 2}
+	}`
+	var u Update
+	if err := json.Unmarshal([]byte(raw), &u); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if u.Kind() != UpdateKindCallback {
+		t.Fatalf("expected callback kind, got %s", u.Kind())
+	}
+
+	cb, ok := u.Callback()
+	if !ok {
+		t.Fatalf("expected callback")
+	}
+	if cb.MessageID != 42 || cb.Chat.ID != "chat-1" || cb.From.Login != "alice@org" {
+		t.Fatalf("unexpected callback origin: %+v", cb)
+	}
+
+	type vote struct {
+		Action string `json:"action"`
+		Option int    `json:"option"`
+	}
+	v, err := DecodeCallback[vote](&u)
+	if err != nil {
+		t.Fatalf("decode callback: %v", err)
+	}
+	if v.Action != "vote" || v.Option != 2 {
+		t.Fatalf("unexpected payload: %+v", v)
+	}
+}
+
+func TestUpdateDecodesServerAction(t *testing.T) {
+	raw := `{"update_id": 1, "bot_request": {"server_action": {"name": "open", "payload": {"id": "x"}}}}`
+	var u Update
+	if err := json.Unmarshal([]byte(raw), &u); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	cb, ok := u.Callback()
+	if !ok || cb.Action != "open" {
+		t.Fatalf("unexpected callback: %+v", cb)
+	}
+	v, err := DecodeCallback[map[string]string](&u)
+	if err != nil || v["id"] != "x" {
+		t.Fatalf("unexpected payload %v: %v", v, err)
+	}
+	if msg := u.ToMessage(); msg.Chat.ID != "" {
+		t.Fatalf("expected empty chat, got %+v", msg.Chat)
+	}
+}
+
+func TestDecodeCallbackErrors(t *testing.T) {
+	u := Update{Text: "hi"}
+	if _, err := DecodeCallback[map[string]any](&u); !errors.Is(err, ymerrors.ErrNoCallback) {
+		t.Fatalf("expected ErrNoCallback, got %v", err)
+	}
+	u.CallbackData = json.RawMessage(`"not an object"`)
+	if _, err := DecodeCallback[struct{ A int }](&u); !errors.Is(err, ymerrors.ErrInvalidResponse) {
+		t.Fatalf("expected ErrInvalidResponse, got %v", err)
+	}
+}
+
+func TestUpdateKind(t *testing.T) {
+	cases := []struct {
+		name   string
+		update *Update
+		want   UpdateKind
+	}{
+		{"nil", nil, UpdateKindUnknown},
+		{"empty", &Update{}, UpdateKindUnknown},
+		{"text", &Update{Text: "hi"}, UpdateKindText},
+		{"sticker", &Update{Sticker: &Sticker{}}, UpdateKindSticker},
+		{"image", &Update{Image: &Image{}}, UpdateKindImage},
+		{"gallery", &Update{Gallery: []Image{{}, {}}}, UpdateKindGallery},
+		{"document", &Update{Document: &File{}, Text: "caption"}, UpdateKindDocument},
+		{"forward", &Update{Forward: &ForwardInfo{}, Text: "fwd"}, UpdateKindForward},
+		{"null callback", &Update{Text: "hi", CallbackData: json.RawMessage("null")}, UpdateKindText},
+	}
+	for _, tc := range cases {
+		if got := tc.update.Kind(); got != tc.want {
+			t.Fatalf("%s: expected %s, got %s", tc.name, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package updates
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym"
+)
+
+// ErrExecutorClosed is returned by Executor.Submit after Close.
+var ErrExecutorClosed = errors.New("yandex-messenger/updates: executor closed")
+
+// KeyFunc returns the ordering key of an update. Updates with the same key are
+// handled one at a time in submission order; different keys run in parallel.
+type KeyFunc func(u ym.Update) string
+
+// ExecutorConfig configures an Executor. Zero values select the defaults.
+type ExecutorConfig struct {
+	// Workers bounds the number of handlers running at once. Defaults to 8.
+	Workers int
+	// QueueSize bounds the updates queued for a single key. Defaults to 64.
+	QueueSize int
+	// MaxPending bounds the queued and running updates of all keys. Defaults to 256.
+	MaxPending int
+	// Key selects the ordering key. Defaults to ByChat.
+	Key KeyFunc
+	// Observer, if set, receives ObserveHandler events.
+	Observer Observer
+}
+
+// Executor runs an update handler on a worker pool. Updates of different chats
+// are handled in parallel, while updates sharing a key keep their order. Submit
+// blocks while the queues are full, which applies backpressure to polling.
+//
+// The executor tracks a watermark offset: Committed only moves past an update
+// once it and every update submitted before it were handled successfully.
+// After the first handler error no further updates are started and the
+// watermark stops before the failed update.
+type Executor struct {
+	handler func(context.Context, ym.Update) error
+	cfg     ExecutorConfig
+	workers chan struct{}
+	// onError, if set, may turn a handler error into nil so that the executor keeps going.
+	onError func(ctx context.Context, u ym.Update, err error) error
+	wg      sync.WaitGroup
+
+	mu        sync.Mutex
+	lanes     map[string]*lane
+	pending   int
+	changed   chan struct{}
+	marks     []*mark
+	committed int64
+	err       error
+	failed    chan struct{}
+	closed    bool
+}
+
+type lane struct {
+	queue []task
+}
+
+type task struct {
+	ctx  context.Context
+	u    ym.Update
+	mark *mark
+}
+
+// mark is a watermark checkpoint: offset may be committed once it and all earlier marks are done.
+type mark struct {
+	offset int64
+	done   bool
+}
+
+// ByChat orders updates per chat, falling back to the sender login for
+// updates without a chat. It is the default key.
+func ByChat(u ym.Update) string {
+	switch {
+	case u.Chat != nil:
+		return "chat:" + string(u.Chat.ID)
+	case u.From != nil:
+		return "login:" + string(u.From.Login)
+	default:
+		return ""
+	}
+}
+
+// ByThread orders updates per chat thread, so threads of one chat run in parallel.
+func ByThread(u ym.Update) string {
+	key := ByChat(u)
+	if u.ThreadID != nil {
+		key += ":thread:" + strconv.FormatInt(int64(*u.ThreadID), 10)
+	}
+
+	return key
+}
+
+// NewExecutor creates an executor calling handler for every submitted update.
+func NewExecutor(handler func(context.Context, ym.Update) error, cfg ExecutorConfig) *Executor {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 8
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 64
+	}
+	if cfg.MaxPending <= 0 {
+		cfg.MaxPending = 256
+	}
+	if cfg.Key == nil {
+		cfg.Key = ByChat
+	}
+
+	return &Executor{
+		handler: handler,
+		cfg:     cfg,
+		workers: make(chan struct{}, cfg.Workers),
+		lanes:   make(map[string]*lane),
+		changed: make(chan struct{}),
+		failed:  make(chan struct{}),
+	}
+}
+
+// Submit queues u, blocking while its key queue or the executor is full. The
+// handler is called with ctx. Submit returns the first handler error once a
+// handler failed, and ErrExecutorClosed after Close.
+func (e *Executor) Submit(ctx context.Context, u ym.Update) error {
+	return e.submit(ctx, ctx, u)
+}
+
+// submit waits for queue space with wait and calls the handler with run.
+func (e *Executor) submit(wait, run context.Context, u ym.Update) error {
+	key := e.cfg.Key(u)
+
+	e.mu.Lock()
+	for {
+		if e.err != nil {
+			err := e.err
+			e.mu.Unlock()
+
+			return err
+		}
+		if e.closed {
+			e.mu.Unlock()
+
+			return ErrExecutorClosed
+		}
+		l := e.lanes[key]
+		if e.pending < e.cfg.MaxPending && (l == nil || len(l.queue) < e.cfg.QueueSize) {
+			break
+		}
+		changed := e.changed
+		e.mu.Unlock()
+
+		select {
+		case <-wait.Done():
+			return wait.Err()
+		case <-changed:
+		}
+		e.mu.Lock()
+	}
+
+	m := &mark{offset: u.UpdateID + 1}
+	e.marks = append(e.marks, m)
+	e.pending++
+	l, ok := e.lanes[key]
+	if !ok {
+		l = &lane{}
+		e.lanes[key] = l
+		e.wg.Add(1)
+		go e.run(key, l)
+	}
+	l.queue = append(l.queue, task{ctx: run, u: u, mark: m})
+	e.mu.Unlock()
+
+	return nil
+}
+
+// Checkpoint records that offset may be committed once every update submitted
+// so far is handled, e.g. the next_offset of a getUpdates batch.
+func (e *Executor) Checkpoint(offset int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.marks = append(e.marks, &mark{offset: offset, done: true})
+	e.advance()
+}
+
+// Committed returns the watermark offset: every update below it was handled.
+func (e *Executor) Committed() int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.committed
+}
+
+// Pending returns the number of queued and running updates.
+func (e *Executor) Pending() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.pending
+}
+
+// Err returns the first handler error, if any.
+func (e *Executor) Err() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.err
+}
+
+// Close stops accepting updates, waits for the queued ones and returns the first handler error.
+func (e *Executor) Close() error {
+	e.mu.Lock()
+	e.closed = true
+	e.notify()
+	e.mu.Unlock()
+
+	e.wg.Wait()
+
+	return e.Err()
+}
+
+func (e *Executor) run(key string, l *lane) {
+	defer e.wg.Done()
+
+	for {
+		e.mu.Lock()
+		if len(l.queue) == 0 || e.err != nil {
+			e.pending -= len(l.queue)
+			delete(e.lanes, key)
+			e.notify()
+			e.mu.Unlock()
+
+			return
+		}
+		t := l.queue[0]
+		l.queue = l.queue[1:]
+		e.mu.Unlock()
+
+		e.workers <- struct{}{}
+		started := time.Now()
+		err := e.handler(t.ctx, t.u)
+		<-e.workers
+		if e.cfg.Observer != nil {
+			e.cfg.Observer.ObserveHandler(t.ctx, t.u, time.Since(started), err)
+		}
+		if err != nil && e.onError != nil {
+			err = e.onError(t.ctx, t.u, err)
+		}
+
+		e.mu.Lock()
+		e.pending--
+		if err != nil {
+			if e.err == nil {
+				e.err = err
+				close(e.failed)
+			}
+		} else {
+			t.mark.done = true
+			e.advance()
+		}
+		e.notify()
+		e.mu.Unlock()
+	}
+}
+
+// advance moves the watermark past the leading done marks. Callers must hold e.mu.
+func (e *Executor) advance() {
+	for len(e.marks) > 0 && e.marks[0].done {
+		e.committed = max(e.committed, e.marks[0].offset)
+		e.marks = e.marks[1:]
+	}
+}
+
+// notify wakes up blocked Submit calls. Callers must hold e.mu.
+func (e *Executor) notify() {
+	close(e.changed)
+	e.changed = make(chan struct{})
+}
@@ -0,0 +1,144 @@
+package updates
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym"
+)
+
+func chatUpdate(id int64, chat ym.ChatID) ym.Update {
+	return ym.Update{UpdateID: id, Chat: &ym.Chat{ID: chat}}
+}
+
+func TestExecutorKeepsOrderWithinChat(t *testing.T) {
+	var mu sync.Mutex
+	seen := map[ym.ChatID][]int64{}
+	release := make(chan struct{})
+	exec := NewExecutor(func(_ context.Context, u ym.Update) error {
+		if u.Chat.ID == "slow" {
+			<-release
+		}
+		mu.Lock()
+		seen[u.Chat.ID] = append(seen[u.Chat.ID], u.UpdateID)
+		mu.Unlock()
+
+		return nil
+	}, ExecutorConfig{Workers: 4})
+
+	ctx := context.Background()
+	for id := int64(1); id <= 6; id++ {
+		chat := ym.ChatID("fast")
+		if id%2 == 1 {
+			chat = "slow"
+		}
+		if err := exec.Submit(ctx, chatUpdate(id, chat)); err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+	}
+	exec.Checkpoint(7)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		fast := len(seen["fast"])
+		mu.Unlock()
+		if fast == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("fast chat blocked by slow chat")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := exec.Committed(); got != 0 {
+		t.Fatalf("watermark must wait for update 1, got %d", got)
+	}
+
+	close(release)
+	if err := exec.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if !reflect.DeepEqual(seen["slow"], []int64{1, 3, 5}) || !reflect.DeepEqual(seen["fast"], []int64{2, 4, 6}) {
+		t.Fatalf("unexpected order %v", seen)
+	}
+	if got := exec.Committed(); got != 7 {
+		t.Fatalf("expected watermark 7, got %d", got)
+	}
+}
+
+func TestExecutorBackpressure(t *testing.T) {
+	release := make(chan struct{})
+	exec := NewExecutor(func(context.Context, ym.Update) error {
+		<-release
+
+		return nil
+	}, ExecutorConfig{Workers: 1, QueueSize: 2, MaxPending: 3})
+
+	ctx := context.Background()
+	for id := int64(1); id <= 2; id++ {
+		if err := exec.Submit(ctx, chatUpdate(id, "c1")); err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+	}
+	if err := exec.Submit(ctx, chatUpdate(3, "c2")); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := exec.Submit(short, chatUpdate(4, "c3")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected submit to block, got %v", err)
+	}
+
+	close(release)
+	if err := exec.Submit(ctx, chatUpdate(5, "c3")); err != nil {
+		t.Fatalf("submit after release: %v", err)
+	}
+	if err := exec.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := exec.Submit(ctx, chatUpdate(6, "c3")); !errors.Is(err, ErrExecutorClosed) {
+		t.Fatalf("expected ErrExecutorClosed, got %v", err)
+	}
+}
+
+func TestExecutorStopsOnHandlerError(t *testing.T) {
+	boom := errors.New("boom")
+	exec := NewExecutor(func(_ context.Context, u ym.Update) error {
+		if u.UpdateID == 2 {
+			return boom
+		}
+
+		return nil
+	}, ExecutorConfig{Workers: 1})
+
+	ctx := context.Background()
+	for id := int64(1); id <= 3; id++ {
+		_ = exec.Submit(ctx, chatUpdate(id, "c1"))
+	}
+	if err := exec.Close(); !errors.Is(err, boom) {
+		t.Fatalf("expected handler error, got %v", err)
+	}
+	if got := exec.Committed(); got != 2 {
+		t.Fatalf("expected watermark before the failed update, got %d", got)
+	}
+	if err := exec.Submit(ctx, chatUpdate(4, "c1")); !errors.Is(err, boom) {
+		t.Fatalf("expected handler error from submit, got %v", err)
+	}
+}
+
+func TestByThread(t *testing.T) {
+	thread := ym.ThreadID(5)
+	u := ym.Update{Chat: &ym.Chat{ID: "c1"}, ThreadID: &thread}
+	if got := ByThread(u); got != "chat:c1:thread:5" {
+		t.Fatalf("unexpected key %q", got)
+	}
+	if got := ByChat(ym.Update{From: &ym.Sender{Login: "u1"}}); got != "login:u1" {
+		t.Fatalf("unexpected key %q", got)
+	}
+}
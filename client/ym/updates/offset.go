@@ -0,0 +1,237 @@
+package updates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OffsetStore persists the polling offset between restarts. Load reports
+// ok=false when no offset was saved yet.
+type OffsetStore interface {
+	Load(ctx context.Context) (offset int64, ok bool, err error)
+	Save(ctx context.Context, offset int64) error
+}
+
+// KV is a minimal key-value store, e.g. a Redis or etcd client wrapper,
+// adapted to OffsetStore by NewKVOffsetStore. Get reports ok=false for a missing key.
+type KV interface {
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	Set(ctx context.Context, key string, value []byte) error
+}
+
+// MemoryOffsetStore keeps the offset in process memory.
+type MemoryOffsetStore struct {
+	mu     sync.Mutex
+	offset int64
+	ok     bool
+}
+
+func NewMemoryOffsetStore() *MemoryOffsetStore {
+	return &MemoryOffsetStore{}
+}
+
+func (s *MemoryOffsetStore) Load(context.Context) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.offset, s.ok, nil
+}
+
+func (s *MemoryOffsetStore) Save(_ context.Context, offset int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.offset, s.ok = offset, true
+
+	return nil
+}
+
+// FileOffsetStore keeps the offset in a file, replaced atomically on every save.
+type FileOffsetStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileOffsetStore(path string) *FileOffsetStore {
+	return &FileOffsetStore{path: path}
+}
+
+func (s *FileOffsetStore) Load(context.Context) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return 0, false, nil
+	case err != nil:
+		return 0, false, fmt.Errorf("yandex-messenger/updates: read offset: %w", err)
+	}
+
+	return parseOffset(data)
+}
+
+// Save writes the offset to a temp file and renames it over the store.
+func (s *FileOffsetStore) Save(_ context.Context, offset int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("yandex-messenger/updates: write offset: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(strconv.FormatInt(offset, 10) + "\n"); err != nil {
+		tmp.Close()
+
+		return fmt.Errorf("yandex-messenger/updates: write offset: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+
+		return fmt.Errorf("yandex-messenger/updates: write offset: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("yandex-messenger/updates: write offset: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("yandex-messenger/updates: write offset: %w", err)
+	}
+
+	return nil
+}
+
+// KVOffsetStore keeps the offset under a key of a KV store.
+type KVOffsetStore struct {
+	kv  KV
+	key string
+}
+
+func NewKVOffsetStore(kv KV, key string) *KVOffsetStore {
+	return &KVOffsetStore{kv: kv, key: key}
+}
+
+func (s *KVOffsetStore) Load(ctx context.Context) (int64, bool, error) {
+	data, ok, err := s.kv.Get(ctx, s.key)
+	if err != nil {
+		return 0, false, fmt.Errorf("yandex-messenger/updates: read offset: %w", err)
+	}
+	if !ok {
+		return 0, false, nil
+	}
+
+	return parseOffset(data)
+}
+
+func (s *KVOffsetStore) Save(ctx context.Context, offset int64) error {
+	if err := s.kv.Set(ctx, s.key, []byte(strconv.FormatInt(offset, 10))); err != nil {
+		return fmt.Errorf("yandex-messenger/updates: write offset: %w", err)
+	}
+
+	return nil
+}
+
+func parseOffset(data []byte) (int64, bool, error) {
+	text := strings.TrimSpace(string(data))
+	if text == "" {
+		return 0, false, nil
+	}
+	offset, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("yandex-messenger/updates: decode offset: %w", err)
+	}
+
+	return offset, true, nil
+}
+
+// committer saves handled offsets to the store, at most once per interval
+// unless flushed. A nil committer does nothing.
+type committer struct {
+	store    OffsetStore
+	interval time.Duration
+	pending  int64
+	saved    int64
+	hasSaved bool
+	last     time.Time
+	onCommit func(ctx context.Context, offset int64)
+}
+
+func newCommitter(store OffsetStore, interval time.Duration) *committer {
+	if store == nil {
+		return nil
+	}
+
+	return &committer{store: store, interval: interval}
+}
+
+// load returns the stored offset, or start if it is set.
+func (c *committer) load(ctx context.Context, start *int64) (*int64, error) {
+	if c == nil || start != nil {
+		return start, nil
+	}
+	offset, ok, err := c.store.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	c.saved, c.hasSaved = offset, true
+
+	return &offset, nil
+}
+
+// handled records that every update below offset is handled.
+func (c *committer) handled(offset int64) {
+	if c != nil {
+		c.pending = max(c.pending, offset)
+	}
+}
+
+// advance records that every update below offset is handled and saves it
+// if the commit interval has passed.
+func (c *committer) advance(ctx context.Context, offset int64) error {
+	if c == nil {
+		return nil
+	}
+	c.handled(offset)
+	if c.interval > 0 && time.Since(c.last) < c.interval {
+		return nil
+	}
+
+	return c.flush(ctx)
+}
+
+// stop flushes the handled offset before returning err, the error that stopped polling.
+func (c *committer) stop(ctx context.Context, err error) error {
+	if flushErr := c.flush(ctx); flushErr != nil {
+		return errors.Join(err, flushErr)
+	}
+
+	return err
+}
+
+// flush saves the last advanced offset. It runs even if ctx is canceled, so
+// that the progress made before shutdown is kept.
+func (c *committer) flush(ctx context.Context) error {
+	if c == nil || c.pending == 0 || c.hasSaved && c.pending <= c.saved {
+		return nil
+	}
+	if err := c.store.Save(context.WithoutCancel(ctx), c.pending); err != nil {
+		return fmt.Errorf("yandex-messenger/updates: commit offset: %w", err)
+	}
+	c.saved, c.hasSaved, c.last = c.pending, true, time.Now()
+	if c.onCommit != nil {
+		c.onCommit(ctx, c.saved)
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package updates
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym"
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+	"github.com/rekurt/ymsdk/internal/testutil"
+)
+
+type mapKV map[string][]byte
+
+func (m mapKV) Get(_ context.Context, key string) ([]byte, bool, error) {
+	v, ok := m[key]
+
+	return v, ok, nil
+}
+
+func (m mapKV) Set(_ context.Context, key string, value []byte) error {
+	m[key] = value
+
+	return nil
+}
+
+func TestOffsetStores(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "offset")
+	stores := map[string]OffsetStore{
+		"memory": NewMemoryOffsetStore(),
+		"file":   NewFileOffsetStore(path),
+		"kv":     NewKVOffsetStore(mapKV{}, "bot:offset"),
+	}
+	ctx := context.Background()
+	for name, store := range stores {
+		if _, ok, err := store.Load(ctx); err != nil || ok {
+			t.Fatalf("%s: expected empty store, got ok=%v err=%v", name, ok, err)
+		}
+		if err := store.Save(ctx, 41); err != nil {
+			t.Fatalf("%s: save: %v", name, err)
+		}
+		if err := store.Save(ctx, 42); err != nil {
+			t.Fatalf("%s: save: %v", name, err)
+		}
+		if offset, ok, err := store.Load(ctx); err != nil || !ok || offset != 42 {
+			t.Fatalf("%s: unexpected load %d %v %v", name, offset, ok, err)
+		}
+	}
+
+	if offset, ok, err := NewFileOffsetStore(path).Load(ctx); err != nil || !ok || offset != 42 {
+		t.Fatalf("reopened file store: %d %v %v", offset, ok, err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Fatalf("expected no temp files left, got %d entries", len(entries))
+	}
+
+	if err := os.WriteFile(path, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := NewFileOffsetStore(path).Load(ctx); err == nil {
+		t.Fatalf("expected decode error")
+	}
+}
+
+func TestPollLoopResumesAndCommitsHandledUpdates(t *testing.T) {
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			testutil.NewResponse(http.StatusOK, `{"ok":true,"updates":[{"update_id":7},{"update_id":8},{"update_id":9}],"next_offset":10}`),
+		},
+	}
+	client := ym.NewClientWithHTTP(ym.Config{
+		BaseURL: "http://example.com",
+		ErrorHandling: ymerrors.ErrorHandlingConfig{
+			RetryStrategy: ymerrors.RetryStrategy{MaxAttempts: 1},
+		},
+	}, doer)
+	store := NewMemoryOffsetStore()
+	_ = store.Save(context.Background(), 7)
+	svc := NewService(client, WithOffsetStore(store), WithCommitInterval(time.Hour))
+
+	boom := errors.New("boom")
+	err := svc.PollLoop(context.Background(), GetUpdatesParams{}, func(_ context.Context, u ym.Update) error {
+		if u.UpdateID == 9 {
+			return boom
+		}
+
+		return nil
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected the handler error, got %v", err)
+	}
+	if got := doer.Requests[0].URL.Query().Get("offset"); got != "7" {
+		t.Fatalf("expected polling to resume from the stored offset, got %q", got)
+	}
+	if offset, _, _ := store.Load(context.Background()); offset != 9 {
+		t.Fatalf("expected offset 9 committed on stop, got %d", offset)
+	}
+}
+
+func TestPollerCommitsWatermark(t *testing.T) {
+	client := ym.NewClientWithHTTP(ym.Config{
+		BaseURL: "http://example.com",
+		ErrorHandling: ymerrors.ErrorHandlingConfig{
+			RetryStrategy: ymerrors.RetryStrategy{MaxAttempts: 1},
+		},
+	}, &testutil.FakeDoer{
+		Responses: []*http.Response{
+			testutil.NewResponse(http.StatusOK, `{"ok":true,"updates":[{"update_id":1,"chat":{"id":"c1"}},{"update_id":2,"chat":{"id":"c2"}}],"next_offset":3}`),
+		},
+	})
+	store := NewKVOffsetStore(mapKV{}, "offset")
+	svc := NewService(client, WithOffsetStore(store))
+	poller := svc.NewPoller(PollerConfig{Interval: time.Millisecond}, func(context.Context, ym.Update) error { return nil })
+
+	if err := poller.Run(context.Background()); !errors.Is(err, ymerrors.ErrInvalidResponse) {
+		t.Fatalf("expected the poll error, got %v", err)
+	}
+	if offset, ok, _ := store.Load(context.Background()); !ok || offset != 3 {
+		t.Fatalf("expected offset 3, got %d %v", offset, ok)
+	}
+}
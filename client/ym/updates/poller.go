@@ -0,0 +1,301 @@
+package updates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym"
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+)
+
+const (
+	// HandlerStop stops the poller on the first handler error. It is the default.
+	HandlerStop HandlerErrorPolicy = iota
+	// HandlerSkip reports the error to Hooks.OnHandlerError and moves on.
+	HandlerSkip
+	// HandlerDeadLetter passes the failed update to PollerConfig.DeadLetter and moves on.
+	HandlerDeadLetter
+)
+
+// HandlerErrorPolicy selects what a Poller does when a handler returns an error.
+type HandlerErrorPolicy int
+
+// PollerConfig configures a Poller. Zero values select the defaults.
+type PollerConfig struct {
+	// Limit is the getUpdates batch size.
+	Limit int
+	// Offset is the offset to start polling from.
+	Offset *int64
+	// Interval is the pause between polls. Defaults to one second.
+	Interval time.Duration
+	// MaxIdleInterval enables adaptive idle backoff: the pause doubles after
+	// every empty poll up to MaxIdleInterval and resets once updates arrive.
+	MaxIdleInterval time.Duration
+	// ErrorBackoff is the pause after a temporary poll error (see
+	// ymerrors.IsTemporary), doubling on every consecutive error up to
+	// MaxErrorBackoff. A Retry-After hint takes precedence.
+	// They default to one and thirty seconds.
+	ErrorBackoff    time.Duration
+	MaxErrorBackoff time.Duration
+	// MaxPollErrors stops polling after this many consecutive transient errors.
+	// Zero retries until ctx is done.
+	MaxPollErrors int
+	// HandlerErrors selects the handler error policy. Defaults to HandlerStop.
+	HandlerErrors HandlerErrorPolicy
+	// DeadLetter receives updates failed under HandlerDeadLetter. An error
+	// returned by it stops the poller.
+	DeadLetter func(ctx context.Context, u ym.Update, err error) error
+	// ShutdownTimeout bounds draining after ctx is done: handlers keep running
+	// with a context that is canceled only once the timeout passes. Zero waits
+	// for them without a limit.
+	ShutdownTimeout time.Duration
+	// Hooks receive lifecycle events.
+	Hooks Hooks
+	// Executor configures the worker pool and per-chat ordering.
+	Executor ExecutorConfig
+}
+
+// Hooks receive poller lifecycle events. Every hook is optional.
+type Hooks struct {
+	// OnStart is called before the first poll with the starting offset, or 0.
+	OnStart func(ctx context.Context, offset int64)
+	// OnPoll is called after every successful poll.
+	OnPoll func(ctx context.Context, received int, nextOffset int64)
+	// OnPollError is called for a transient poll error before waiting retryIn.
+	OnPollError func(ctx context.Context, err error, retryIn time.Duration)
+	// OnHandlerError is called for every handler error, whatever the policy.
+	OnHandlerError func(ctx context.Context, u ym.Update, err error)
+	// OnCommit is called after the offset is saved to the offset store.
+	OnCommit func(ctx context.Context, offset int64)
+	// OnShutdown is called when polling stops with the number of updates left to drain.
+	OnShutdown func(ctx context.Context, pending int)
+	// OnStop is called with the error Run returns.
+	OnStop func(ctx context.Context, err error)
+}
+
+// Poller polls updates and handles them on an Executor: chats are processed in
+// parallel with ordering kept within every chat, and polling waits while the
+// executor queues are full. Temporary poll errors are retried with backoff.
+type Poller struct {
+	svc     *Service
+	cfg     PollerConfig
+	handler func(context.Context, ym.Update) error
+	exec    atomic.Pointer[Executor]
+}
+
+// NewPoller creates a poller calling handler for every update.
+func (s *Service) NewPoller(cfg PollerConfig, handler func(context.Context, ym.Update) error) *Poller {
+	if cfg.Executor.Observer == nil {
+		cfg.Executor.Observer = s.observer
+	}
+
+	return &Poller{svc: s, cfg: withPollerDefaults(cfg), handler: handler}
+}
+
+// Run polls until ctx is done, polling fails permanently or a handler error
+// stops it. Queued and running updates are drained before Run returns; the
+// first handler error takes precedence over the error that stopped polling.
+// With an offset store the committed offset is saved while polling and once
+// more on return.
+func (p *Poller) Run(ctx context.Context) (err error) {
+	if p.cfg.HandlerErrors == HandlerDeadLetter && p.cfg.DeadLetter == nil {
+		return errors.New("yandex-messenger/updates: HandlerDeadLetter requires PollerConfig.DeadLetter")
+	}
+	if hook := p.cfg.Hooks.OnStop; hook != nil {
+		defer func() { hook(ctx, err) }()
+	}
+
+	commits := newCommitter(p.svc.offsets, p.svc.commitInterval)
+	if commits != nil {
+		commits.onCommit = p.cfg.Hooks.OnCommit
+	}
+	offset, err := commits.load(ctx, p.cfg.Offset)
+	if err != nil {
+		return err
+	}
+	exec := NewExecutor(p.handler, p.cfg.Executor)
+	exec.onError = p.handlerError
+	p.exec.Store(exec)
+
+	run, cancel := p.handlerContext(ctx)
+	defer cancel()
+
+	if hook := p.cfg.Hooks.OnStart; hook != nil {
+		var start int64
+		if offset != nil {
+			start = *offset
+		}
+		hook(ctx, start)
+	}
+	err = p.poll(ctx, run, exec, commits, offset)
+	if hook := p.cfg.Hooks.OnShutdown; hook != nil {
+		hook(ctx, exec.Pending())
+	}
+	if closeErr := exec.Close(); closeErr != nil {
+		err = closeErr
+	}
+	commits.handled(exec.Committed())
+
+	return commits.stop(ctx, err)
+}
+
+// Offset returns the committed offset: every update below it was handled.
+// Polling may be ahead of it while updates are in flight.
+func (p *Poller) Offset() int64 {
+	if exec := p.exec.Load(); exec != nil {
+		return exec.Committed()
+	}
+	if p.cfg.Offset != nil {
+		return *p.cfg.Offset
+	}
+
+	return 0
+}
+
+// handlerContext returns the context of handlers. It outlives ctx by
+// ShutdownTimeout, or until Run returns, so that in-flight updates can finish.
+func (p *Poller) handlerContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	run, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	if p.cfg.ShutdownTimeout <= 0 {
+		return run, cancel
+	}
+	var timer atomic.Pointer[time.Timer]
+	stop := context.AfterFunc(ctx, func() {
+		timer.Store(time.AfterFunc(p.cfg.ShutdownTimeout, cancel))
+	})
+
+	return run, func() {
+		stop()
+		if t := timer.Load(); t != nil {
+			t.Stop()
+		}
+		cancel()
+	}
+}
+
+func (p *Poller) handlerError(ctx context.Context, u ym.Update, err error) error {
+	if hook := p.cfg.Hooks.OnHandlerError; hook != nil {
+		hook(ctx, u, err)
+	}
+	switch p.cfg.HandlerErrors {
+	case HandlerSkip:
+		return nil
+	case HandlerDeadLetter:
+		if dlErr := p.cfg.DeadLetter(ctx, u, err); dlErr != nil {
+			return fmt.Errorf("yandex-messenger/updates: dead letter update %d: %w (handler error: %w)", u.UpdateID, dlErr, err)
+		}
+
+		return nil
+	default:
+		return err
+	}
+}
+
+func (p *Poller) poll(ctx, run context.Context, exec *Executor, commits *committer, offset *int64) error {
+	params := GetUpdatesParams{Offset: offset}
+	if p.cfg.Limit > 0 {
+		params.Limit = &p.cfg.Limit
+	}
+	if params.Offset != nil {
+		exec.Checkpoint(*params.Offset)
+	}
+	pace := newPacer(p.cfg)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-exec.failed:
+			return exec.Err()
+		case <-timer.C:
+		}
+
+		upds, nextOffset, err := p.svc.GetUpdates(ctx, params)
+		if p.svc.observer != nil {
+			p.svc.observer.ObservePoll(ctx, len(upds), nextOffset, err)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			wait, retry := pace.failed(err)
+			if !retry {
+				return err
+			}
+			if hook := p.cfg.Hooks.OnPollError; hook != nil {
+				hook(ctx, err, wait)
+			}
+			timer.Reset(wait)
+
+			continue
+		}
+		if hook := p.cfg.Hooks.OnPoll; hook != nil {
+			hook(ctx, len(upds), nextOffset)
+		}
+		for _, u := range upds {
+			if err := exec.submit(ctx, run, u); err != nil {
+				return err
+			}
+		}
+		exec.Checkpoint(nextOffset)
+		if err := commits.advance(ctx, exec.Committed()); err != nil {
+			return err
+		}
+		params.Offset = &nextOffset
+		timer.Reset(pace.polled(len(upds)))
+	}
+}
+
+func withPollerDefaults(cfg PollerConfig) PollerConfig {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.ErrorBackoff <= 0 {
+		cfg.ErrorBackoff = time.Second
+	}
+	if cfg.MaxErrorBackoff <= 0 {
+		cfg.MaxErrorBackoff = 30 * time.Second
+	}
+
+	return cfg
+}
+
+// pacer computes the pauses between polls from the poll outcomes.
+type pacer struct {
+	cfg      PollerConfig
+	idle     time.Duration
+	failures int
+}
+
+func newPacer(cfg PollerConfig) *pacer {
+	return &pacer{cfg: cfg, idle: cfg.Interval}
+}
+
+// polled returns the pause after a successful poll of received updates.
+func (p *pacer) polled(received int) time.Duration {
+	p.failures = 0
+	if received > 0 || p.cfg.MaxIdleInterval <= p.cfg.Interval {
+		p.idle = p.cfg.Interval
+
+		return p.cfg.Interval
+	}
+	wait := p.idle
+	p.idle = min(p.idle*2, p.cfg.MaxIdleInterval)
+
+	return wait
+}
+
+// failed returns the pause after a failed poll and whether to poll again.
+func (p *pacer) failed(err error) (time.Duration, bool) {
+	p.failures++
+	if !ymerrors.IsTemporary(err) || p.cfg.MaxPollErrors > 0 && p.failures >= p.cfg.MaxPollErrors {
+		return 0, false
+	}
+
+	return ym.RetryDelay(p.failures, p.cfg.ErrorBackoff, p.cfg.MaxErrorBackoff, err), true
+}
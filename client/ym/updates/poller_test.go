@@ -0,0 +1,253 @@
+package updates
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym"
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+	"github.com/rekurt/ymsdk/internal/testutil"
+)
+
+func newPollClient(doer *testutil.FakeDoer) *ym.Client {
+	return ym.NewClientWithHTTP(ym.Config{
+		BaseURL: "http://example.com",
+		ErrorHandling: ymerrors.ErrorHandlingConfig{
+			RetryStrategy: ymerrors.RetryStrategy{MaxAttempts: 1},
+		},
+	}, doer)
+}
+
+func TestPollerHandlesBatchesAndCommits(t *testing.T) {
+	client := newPollClient(&testutil.FakeDoer{
+		Responses: []*http.Response{
+			testutil.NewResponse(http.StatusOK, `{"ok":true,"updates":[{"update_id":1,"chat":{"id":"c1"}},{"update_id":2,"chat":{"id":"c2"}}],"next_offset":3}`),
+			testutil.NewResponse(http.StatusOK, `{"ok":true,"updates":[{"update_id":3,"chat":{"id":"c1"}}],"next_offset":4}`),
+		},
+	})
+
+	var mu sync.Mutex
+	var handled []int64
+	poller := NewService(client).NewPoller(PollerConfig{Limit: 10, Interval: time.Millisecond}, func(_ context.Context, u ym.Update) error {
+		mu.Lock()
+		handled = append(handled, u.UpdateID)
+		mu.Unlock()
+
+		return nil
+	})
+
+	// The fake transport runs out of responses after two batches, which stops polling.
+	if err := poller.Run(context.Background()); !errors.Is(err, ymerrors.ErrInvalidResponse) {
+		t.Fatalf("expected the poll error, got %v", err)
+	}
+	if len(handled) != 3 {
+		t.Fatalf("expected 3 handled updates, got %v", handled)
+	}
+	if got := poller.Offset(); got != 4 {
+		t.Fatalf("expected committed offset 4, got %d", got)
+	}
+}
+
+func TestPollerRetriesTransientErrors(t *testing.T) {
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			testutil.NewResponse(http.StatusTooManyRequests, `{"ok":false,"description":"slow down"}`),
+			testutil.NewResponse(http.StatusBadGateway, ``),
+			testutil.NewResponse(http.StatusOK, `{"ok":true,"updates":[{"update_id":1,"chat":{"id":"c1"}}],"next_offset":2}`),
+			testutil.NewResponse(http.StatusForbidden, `{"ok":false,"description":"invalid token"}`),
+		},
+	}
+	var retries []time.Duration
+	handled := 0
+	poller := NewService(newPollClient(doer)).NewPoller(PollerConfig{
+		Interval:     time.Millisecond,
+		ErrorBackoff: time.Millisecond,
+		Hooks: Hooks{
+			OnPollError: func(_ context.Context, _ error, retryIn time.Duration) {
+				retries = append(retries, retryIn)
+			},
+		},
+	}, func(context.Context, ym.Update) error {
+		handled++
+
+		return nil
+	})
+
+	err := poller.Run(context.Background())
+	if ymerrors.KindOf(err) != ymerrors.KindInvalidToken {
+		t.Fatalf("expected to stop on the invalid token, got %v", err)
+	}
+	if len(doer.Requests) != 4 || handled != 1 {
+		t.Fatalf("unexpected polls=%d handled=%d", len(doer.Requests), handled)
+	}
+	if len(retries) != 2 || retries[0] != time.Millisecond || retries[1] != 2*time.Millisecond {
+		t.Fatalf("unexpected retry delays %v", retries)
+	}
+}
+
+func TestPollerMaxPollErrors(t *testing.T) {
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			testutil.NewResponse(http.StatusServiceUnavailable, ``),
+			testutil.NewResponse(http.StatusServiceUnavailable, ``),
+		},
+	}
+	poller := NewService(newPollClient(doer)).NewPoller(PollerConfig{ErrorBackoff: time.Millisecond, MaxPollErrors: 2},
+		func(context.Context, ym.Update) error { return nil })
+
+	if err := poller.Run(context.Background()); ymerrors.KindOf(err) != ymerrors.KindNetwork || len(doer.Requests) != 2 {
+		t.Fatalf("expected to give up after 2 errors, got %v after %d polls", err, len(doer.Requests))
+	}
+}
+
+func TestPollerHandlerErrorPolicies(t *testing.T) {
+	boom := errors.New("boom")
+	handler := func(_ context.Context, u ym.Update) error {
+		if u.UpdateID == 2 {
+			return boom
+		}
+
+		return nil
+	}
+	batch := `{"ok":true,"updates":[{"update_id":1,"chat":{"id":"c1"}},{"update_id":2,"chat":{"id":"c1"}},{"update_id":3,"chat":{"id":"c1"}}],"next_offset":4}`
+
+	for _, tc := range []struct {
+		name   string
+		policy HandlerErrorPolicy
+		want   int64
+	}{
+		{"stop", HandlerStop, 2},
+		{"skip", HandlerSkip, 4},
+		{"dead letter", HandlerDeadLetter, 4},
+	} {
+		var dead []int64
+		var hooked []error
+		doer := &testutil.FakeDoer{Responses: []*http.Response{testutil.NewResponse(http.StatusOK, batch)}}
+		poller := NewService(newPollClient(doer)).NewPoller(PollerConfig{
+			Interval:      time.Millisecond,
+			HandlerErrors: tc.policy,
+			DeadLetter: func(_ context.Context, u ym.Update, err error) error {
+				dead = append(dead, u.UpdateID)
+
+				return nil
+			},
+			Hooks: Hooks{OnHandlerError: func(_ context.Context, _ ym.Update, err error) { hooked = append(hooked, err) }},
+		}, handler)
+
+		err := poller.Run(context.Background())
+		if tc.policy == HandlerStop && !errors.Is(err, boom) {
+			t.Fatalf("%s: expected the handler error, got %v", tc.name, err)
+		}
+		if tc.policy != HandlerStop && !errors.Is(err, ymerrors.ErrInvalidResponse) {
+			t.Fatalf("%s: expected to keep polling, got %v", tc.name, err)
+		}
+		if got := poller.Offset(); got != tc.want {
+			t.Fatalf("%s: expected offset %d, got %d", tc.name, tc.want, got)
+		}
+		if len(hooked) != 1 {
+			t.Fatalf("%s: expected one handler error hook, got %v", tc.name, hooked)
+		}
+		if tc.policy == HandlerDeadLetter && (len(dead) != 1 || dead[0] != 2) {
+			t.Fatalf("%s: unexpected dead letters %v", tc.name, dead)
+		}
+	}
+
+	poller := NewService(nil).NewPoller(PollerConfig{HandlerErrors: HandlerDeadLetter}, handler)
+	if err := poller.Run(context.Background()); err == nil {
+		t.Fatalf("expected a config error without DeadLetter")
+	}
+}
+
+func TestPollerDrainsOnShutdown(t *testing.T) {
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			testutil.NewResponse(http.StatusOK, `{"ok":true,"updates":[{"update_id":1,"chat":{"id":"c1"}}],"next_offset":2}`),
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	var mu sync.Mutex
+	var handlerErr error
+	var events []string
+	poller := NewService(newPollClient(doer)).NewPoller(PollerConfig{
+		Interval:        time.Hour,
+		ShutdownTimeout: 20 * time.Millisecond,
+		Hooks: Hooks{
+			OnShutdown: func(_ context.Context, pending int) {
+				if pending != 1 {
+					t.Errorf("expected one update to drain, got %d", pending)
+				}
+				events = append(events, "shutdown")
+			},
+			OnStop: func(_ context.Context, err error) { events = append(events, "stop") },
+		},
+	}, func(hctx context.Context, _ ym.Update) error {
+		close(started)
+		<-hctx.Done()
+		mu.Lock()
+		handlerErr = hctx.Err()
+		mu.Unlock()
+
+		return nil
+	})
+
+	go func() {
+		<-started
+		cancel()
+	}()
+	begin := time.Now()
+	if err := poller.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(begin); elapsed < 20*time.Millisecond {
+		t.Fatalf("returned before the shutdown timeout: %v", elapsed)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !errors.Is(handlerErr, context.Canceled) {
+		t.Fatalf("expected the handler to finish before Run returned, got %v", handlerErr)
+	}
+	if poller.Offset() != 2 || len(events) != 2 || events[0] != "shutdown" || events[1] != "stop" {
+		t.Fatalf("unexpected offset %d events %v", poller.Offset(), events)
+	}
+}
+
+func TestPacerIdleBackoff(t *testing.T) {
+	pace := newPacer(withPollerDefaults(PollerConfig{Interval: time.Second, MaxIdleInterval: 3 * time.Second}))
+	var got []time.Duration
+	for _, received := range []int{0, 0, 0, 0, 5, 0} {
+		got = append(got, pace.polled(received))
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second, time.Second, time.Second}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("unexpected pauses %v, want %v", got, want)
+		}
+	}
+
+	wait, retry := pace.failed(&ymerrors.APIError{Kind: ymerrors.KindRateLimited, RetryAfter: 5 * time.Second})
+	if !retry || wait != 5*time.Second {
+		t.Fatalf("expected Retry-After to win, got %v %v", wait, retry)
+	}
+	if _, retry := pace.failed(&ymerrors.APIError{Kind: ymerrors.KindUnauthorized}); retry {
+		t.Fatalf("unauthorized must not be retried")
+	}
+}
+
+func TestPollLoopSleepHonoursContext(t *testing.T) {
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{testutil.NewResponse(http.StatusOK, `{"ok":true,"updates":[],"next_offset":1}`)},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	begin := time.Now()
+	err := NewService(newPollClient(doer)).PollLoop(ctx, GetUpdatesParams{}, func(context.Context, ym.Update) error { return nil })
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(begin) > 500*time.Millisecond {
+		t.Fatalf("expected a prompt deadline error, got %v after %v", err, time.Since(begin))
+	}
+}
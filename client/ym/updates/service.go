@@ -2,7 +2,6 @@ package updates
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -14,11 +13,53 @@ import (
 )
 
 type Service struct {
-	client *ym.Client
+	client         *ym.Client
+	observer       Observer
+	offsets        OffsetStore
+	commitInterval time.Duration
 }
 
-func NewService(client *ym.Client) *Service {
-	return &Service{client: client}
+// Observer receives polling loop events, e.g. to export metrics.
+type Observer interface {
+	// ObservePoll is called after every getUpdates call with the number of
+	// received updates and the next offset.
+	ObservePoll(ctx context.Context, received int, nextOffset int64, err error)
+	// ObserveHandler is called after the handler finished processing an update.
+	ObserveHandler(ctx context.Context, u ym.Update, elapsed time.Duration, err error)
+}
+
+type Option func(*Service)
+
+func WithObserver(observer Observer) Option {
+	return func(s *Service) {
+		s.observer = observer
+	}
+}
+
+// WithOffsetStore makes PollLoop and pollers resume from the offset saved in
+// store and save the offset of handled updates to it. Delivery is at least
+// once: updates handled after the last save are handled again after a restart.
+func WithOffsetStore(store OffsetStore) Option {
+	return func(s *Service) {
+		s.offsets = store
+	}
+}
+
+// WithCommitInterval saves the offset at most once per interval, plus on
+// shutdown. By default it is saved after every handled batch.
+func WithCommitInterval(interval time.Duration) Option {
+	return func(s *Service) {
+		s.commitInterval = interval
+	}
+}
+
+func NewService(client *ym.Client, opts ...Option) *Service {
+	s := &Service{client: client}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
 type getUpdatesResponse struct {
@@ -52,8 +93,8 @@ func (s *Service) Get(ctx context.Context, limit int, offset string) ([]ym.Updat
 	defer resp.Body.Close()
 
 	var parsed getUpdatesResponse
-	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-		return nil, "", fmt.Errorf("%w: decode getUpdates response: %w", ymerrors.ErrInvalidResponse, err)
+	if err := s.client.DecodeJSON(ctx, resp, &parsed, "getUpdates"); err != nil {
+		return nil, "", err
 	}
 	if !parsed.OK {
 		return nil, "", fmt.Errorf("%w: ok=false", ymerrors.ErrInvalidResponse)
@@ -102,27 +143,62 @@ func calculateNextOffset(updates []ym.Update, current *int64) int64 {
 	return maxID
 }
 
+// PollLoop polls updates and calls handler for each of them serially until ctx
+// is done, polling fails permanently or the handler returns an error. Transient
+// poll errors are retried with the default Poller backoff. With an offset store
+// it starts from the stored offset unless params.Offset is set, and commits
+// handled updates. Use NewPoller for concurrency and configurable policies.
 func (s *Service) PollLoop(
 	ctx context.Context, params GetUpdatesParams, handler func(context.Context, ym.Update) error,
 ) error {
-	offset := params.Offset
+	commits := newCommitter(s.offsets, s.commitInterval)
+	offset, err := commits.load(ctx, params.Offset)
+	if err != nil {
+		return err
+	}
+	pace := newPacer(withPollerDefaults(PollerConfig{}))
 	for {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return commits.stop(ctx, ctx.Err())
 		default:
 		}
 
 		upds, nextOffset, err := s.GetUpdates(ctx, GetUpdatesParams{Limit: params.Limit, Offset: offset})
+		if s.observer != nil {
+			s.observer.ObservePoll(ctx, len(upds), nextOffset, err)
+		}
 		if err != nil {
-			return err
+			if ctx.Err() != nil {
+				return commits.stop(ctx, ctx.Err())
+			}
+			wait, retry := pace.failed(err)
+			if !retry {
+				return commits.stop(ctx, err)
+			}
+			if err := ym.Sleep(ctx, wait); err != nil {
+				return commits.stop(ctx, err)
+			}
+
+			continue
 		}
 		for _, u := range upds {
-			if err := handler(ctx, u); err != nil {
-				return err
+			started := time.Now()
+			err := handler(ctx, u)
+			if s.observer != nil {
+				s.observer.ObserveHandler(ctx, u, time.Since(started), err)
+			}
+			if err != nil {
+				return commits.stop(ctx, err)
 			}
+			commits.handled(u.UpdateID + 1)
+		}
+		if err := commits.advance(ctx, nextOffset); err != nil {
+			return err
 		}
 		offset = &nextOffset
-		time.Sleep(time.Second)
+		if err := ym.Sleep(ctx, pace.polled(len(upds))); err != nil {
+			return commits.stop(ctx, err)
+		}
 	}
 }
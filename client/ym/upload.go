@@ -0,0 +1,362 @@
+package ym
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+)
+
+// DefaultReplayBuffer is the replay buffer size used for plain io.Reader uploads
+// by the message senders.
+const DefaultReplayBuffer = 8 << 20
+
+var (
+	errBodySuperseded = errors.New("yandex-messenger/client: request body superseded by a new attempt")
+	quoteEscaper      = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+)
+
+// UploadSource provides the content of an uploaded file. A source is opened once
+// per attempt, so retries resend the content from the beginning when the source
+// can be rewound: a file path, an io.ReadSeeker, an open callback or a byte slice.
+type UploadSource struct {
+	open func() (io.ReadCloser, error)
+	size func() (int64, bool)
+
+	mu     sync.Mutex
+	opened bool
+	once   bool
+}
+
+// MultipartField is a plain form field of a multipart request.
+type MultipartField struct {
+	Name  string
+	Value string
+}
+
+// MultipartFile is a file part of a multipart request.
+type MultipartFile struct {
+	Field       string
+	Filename    string
+	ContentType string
+	Source      *UploadSource
+}
+
+// multipartBody streams form data through a pipe, one writer goroutine per attempt.
+type multipartBody struct {
+	boundary string
+	fields   []MultipartField
+	files    []MultipartFile
+
+	mu      sync.Mutex
+	current *io.PipeReader
+	done    chan struct{}
+}
+
+// FileSource reads the file at path, re-opening it for every attempt.
+func FileSource(path string) *UploadSource {
+	return &UploadSource{
+		open: func() (io.ReadCloser, error) {
+			return os.Open(path)
+		},
+		size: func() (int64, bool) {
+			info, err := os.Stat(path)
+			if err != nil || !info.Mode().IsRegular() {
+				return 0, false
+			}
+
+			return info.Size(), true
+		},
+	}
+}
+
+// OpenerSource calls open for every attempt. The returned reader is closed after use.
+func OpenerSource(open func() (io.ReadCloser, error)) *UploadSource {
+	return &UploadSource{open: open}
+}
+
+// BytesSource serves data from memory.
+func BytesSource(data []byte) *UploadSource {
+	return ReaderSource(bytes.NewReader(data))
+}
+
+// ReaderSource wraps r. A seekable io.ReadSeeker is rewound to its initial position
+// for every attempt; any other reader can be sent only once, and a retry that needs
+// it again fails with ymerrors.ErrNotRewindable. The reader is never closed.
+func ReaderSource(r io.Reader) *UploadSource {
+	if rs, ok := r.(io.ReadSeeker); ok {
+		// Pipes and terminals implement Seek but fail on use.
+		if start, err := rs.Seek(0, io.SeekCurrent); err == nil {
+			return seekerSource(rs, start)
+		}
+	}
+
+	return &UploadSource{
+		open: func() (io.ReadCloser, error) {
+			return io.NopCloser(r), nil
+		},
+		once: true,
+	}
+}
+
+// BufferedReaderSource is ReaderSource that replays a plain io.Reader from memory:
+// the first limit bytes read are kept, so a retry resends them and continues
+// with the rest of r. Once more than limit bytes were read, the current attempt
+// still streams the rest of r, but a retry fails with ymerrors.ErrNotRewindable.
+// A seekable io.ReadSeeker is rewound instead.
+func BufferedReaderSource(r io.Reader, limit int) *UploadSource {
+	if rs, ok := r.(io.ReadSeeker); ok {
+		if start, err := rs.Seek(0, io.SeekCurrent); err == nil {
+			return seekerSource(rs, start)
+		}
+	}
+	rb := &replayBuffer{r: r, limit: limit}
+
+	return &UploadSource{open: rb.open}
+}
+
+// replayBuffer records what is read from r for later attempts. Recording stops
+// once more than limit bytes were read.
+type replayBuffer struct {
+	mu       sync.Mutex
+	r        io.Reader
+	buf      []byte
+	read     int64
+	limit    int
+	overflow bool
+}
+
+func (b *replayBuffer) open() (io.ReadCloser, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.overflow {
+		return nil, b.errOverflow()
+	}
+
+	return io.NopCloser(&replayReader{b: b}), nil
+}
+
+func (b *replayBuffer) errOverflow() error {
+	return fmt.Errorf("%w: reader exceeded the %d byte replay buffer", ymerrors.ErrNotRewindable, b.limit)
+}
+
+// replayReader serves the recorded prefix, then reads and records the rest of the reader.
+type replayReader struct {
+	b   *replayBuffer
+	pos int64
+}
+
+func (r *replayReader) Read(p []byte) (int, error) {
+	b := r.b
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if r.pos < int64(len(b.buf)) {
+		n := copy(p, b.buf[r.pos:])
+		r.pos += int64(n)
+
+		return n, nil
+	}
+	// Only the reader that consumed everything read so far may continue: the
+	// bytes it missed were not recorded.
+	if r.pos != b.read {
+		return 0, b.errOverflow()
+	}
+	n, err := b.r.Read(p)
+	b.read += int64(n)
+	r.pos += int64(n)
+	switch {
+	case b.overflow:
+	case len(b.buf)+n > b.limit:
+		b.overflow, b.buf = true, nil
+	default:
+		b.buf = append(b.buf, p[:n]...)
+	}
+
+	return n, err
+}
+
+func seekerSource(rs io.ReadSeeker, start int64) *UploadSource {
+	return &UploadSource{
+		open: func() (io.ReadCloser, error) {
+			if _, err := rs.Seek(start, io.SeekStart); err != nil {
+				return nil, err
+			}
+
+			return io.NopCloser(rs), nil
+		},
+		size: func() (int64, bool) {
+			end, err := rs.Seek(0, io.SeekEnd)
+			if err != nil {
+				return 0, false
+			}
+			if _, err := rs.Seek(start, io.SeekStart); err != nil {
+				return 0, false
+			}
+
+			return end - start, true
+		},
+	}
+}
+
+// Open returns a reader positioned at the start of the content.
+func (s *UploadSource) Open() (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.once && s.opened {
+		return nil, fmt.Errorf("%w: use an io.ReadSeeker, FileSource or OpenerSource to allow retries", ymerrors.ErrNotRewindable)
+	}
+	s.opened = true
+
+	return s.open()
+}
+
+// Size reports the content size in bytes, if it is known without reading the content.
+func (s *UploadSource) Size() (int64, bool) {
+	if s.size == nil {
+		return 0, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.size()
+}
+
+// NewMultipartRequest creates a multipart/form-data request whose body is streamed
+// from the file sources on every attempt instead of being buffered in memory.
+func NewMultipartRequest(method, path string, fields []MultipartField, files []MultipartFile) (*Request, error) {
+	for i, f := range files {
+		if f.Source == nil {
+			return nil, fmt.Errorf("yandex-messenger/client: multipart file %d (%s) has no source", i, f.Field)
+		}
+	}
+
+	body := &multipartBody{
+		boundary: multipart.NewWriter(io.Discard).Boundary(),
+		fields:   fields,
+		files:    files,
+	}
+	req := NewRequest(method, path)
+	req.Header.Set("Content-Type", "multipart/form-data; boundary="+body.boundary)
+	req.ContentLength = body.length()
+	req.GetBody = body.open
+
+	return req, nil
+}
+
+// open starts streaming a fresh copy of the body. Sources are opened up front so
+// that a source that cannot be replayed fails the attempt before anything is sent.
+func (b *multipartBody) open() (io.ReadCloser, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// A previous attempt may still be reading the sources; stop it first so that
+	// rewinding a shared reader does not race with it.
+	if b.current != nil {
+		b.current.CloseWithError(errBodySuperseded)
+		<-b.done
+	}
+
+	readers := make([]io.ReadCloser, 0, len(b.files))
+	for _, f := range b.files {
+		r, err := f.Source.Open()
+		if err != nil {
+			for _, opened := range readers {
+				opened.Close()
+			}
+
+			return nil, fmt.Errorf("open %s: %w", f.Filename, err)
+		}
+		readers = append(readers, r)
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	b.current, b.done = pr, done
+
+	go func() {
+		defer close(done)
+		err := b.write(pw, readers)
+		for _, r := range readers {
+			r.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	return pr, nil
+}
+
+func (b *multipartBody) write(w io.Writer, readers []io.ReadCloser) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(b.boundary); err != nil {
+		return err
+	}
+	for _, f := range b.fields {
+		if err := mw.WriteField(f.Name, f.Value); err != nil {
+			return err
+		}
+	}
+	for i, f := range b.files {
+		part, err := mw.CreatePart(fileHeader(f))
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(part, readers[i]); err != nil {
+			return fmt.Errorf("read %s: %w", f.Filename, err)
+		}
+	}
+
+	return mw.Close()
+}
+
+// length returns the exact body size when every source size is known, and 0 otherwise.
+func (b *multipartBody) length() int64 {
+	var total int64
+	empty := make([]io.ReadCloser, len(b.files))
+	for i, f := range b.files {
+		size, ok := f.Source.Size()
+		if !ok {
+			return 0
+		}
+		total += size
+		empty[i] = io.NopCloser(strings.NewReader(""))
+	}
+
+	counter := &countingWriter{}
+	if err := b.write(counter, empty); err != nil {
+		return 0
+	}
+
+	return total + counter.n
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+
+	return len(p), nil
+}
+
+func fileHeader(f MultipartFile) textproto.MIMEHeader {
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Disposition", fmt.Sprintf(
+		`form-data; name="%s"; filename="%s"`, quoteEscaper.Replace(f.Field), quoteEscaper.Replace(f.Filename),
+	))
+	if f.ContentType != "" {
+		h.Set("Content-Type", f.ContentType)
+	}
+
+	return h
+}
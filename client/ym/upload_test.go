@@ -0,0 +1,199 @@
+package ym
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+)
+
+// readingDoer consumes every request body like a real transport and records it.
+type readingDoer struct {
+	responses []*http.Response
+	bodies    []string
+	lengths   []int64
+}
+
+func (d *readingDoer) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	d.bodies = append(d.bodies, string(body))
+	d.lengths = append(d.lengths, req.ContentLength)
+	resp := d.responses[0]
+	d.responses = d.responses[1:]
+
+	return resp, nil
+}
+
+func uploadClient(doer HttpDoer) *Client {
+	return NewClientWithHTTP(Config{
+		BaseURL: "http://example.com",
+		ErrorHandling: ymerrors.ErrorHandlingConfig{
+			RetryStrategy: ymerrors.RetryStrategy{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+		},
+	}, doer)
+}
+
+func parseMultipart(t *testing.T, contentType, body string) map[string]string {
+	t.Helper()
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatalf("parse content type: %v", err)
+	}
+	reader := multipart.NewReader(strings.NewReader(body), params["boundary"])
+	parts := map[string]string{}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return parts
+		}
+		if err != nil {
+			t.Fatalf("read part: %v", err)
+		}
+		data, _ := io.ReadAll(part)
+		parts[part.FormName()+":"+part.FileName()] = string(data)
+	}
+}
+
+func TestMultipartRequestReplaysSeekerOnRetry(t *testing.T) {
+	doer := &readingDoer{responses: []*http.Response{
+		newResponse(http.StatusServiceUnavailable, `{"ok":false}`, nil),
+		newResponse(http.StatusOK, `{"ok":true}`, nil),
+	}}
+	req, err := NewMultipartRequest(http.MethodPost, "/upload",
+		[]MultipartField{{Name: "chat_id", Value: "c1"}},
+		[]MultipartFile{{Field: "document", Filename: "a.txt", Source: ReaderSource(bytes.NewReader([]byte("content")))}},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp, err := uploadClient(doer).Do(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if len(doer.bodies) != 2 || doer.bodies[0] != doer.bodies[1] {
+		t.Fatalf("expected identical bodies on both attempts, got %q", doer.bodies)
+	}
+	if doer.lengths[1] != int64(len(doer.bodies[1])) {
+		t.Fatalf("expected exact content length %d, got %d", len(doer.bodies[1]), doer.lengths[1])
+	}
+	parts := parseMultipart(t, req.Header.Get("Content-Type"), doer.bodies[1])
+	if parts["chat_id:"] != "c1" || parts["document:a.txt"] != "content" {
+		t.Fatalf("unexpected parts: %v", parts)
+	}
+}
+
+func TestMultipartRequestFailsRetryWithOneShotReader(t *testing.T) {
+	doer := &readingDoer{responses: []*http.Response{
+		newResponse(http.StatusServiceUnavailable, `{"ok":false}`, nil),
+		newResponse(http.StatusOK, `{"ok":true}`, nil),
+	}}
+	req, err := NewMultipartRequest(http.MethodPost, "/upload", nil,
+		[]MultipartFile{{Field: "document", Filename: "a.txt", Source: ReaderSource(io.MultiReader(strings.NewReader("x")))}},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.ContentLength != 0 {
+		t.Fatalf("expected unknown content length, got %d", req.ContentLength)
+	}
+
+	_, err = uploadClient(doer).Do(context.Background(), req)
+	if !errors.Is(err, ymerrors.ErrNotRewindable) {
+		t.Fatalf("expected ErrNotRewindable, got %v", err)
+	}
+	if len(doer.bodies) != 1 {
+		t.Fatalf("expected the retry to fail before sending, got %d attempts", len(doer.bodies))
+	}
+}
+
+func TestBufferedReaderSourceReplaysSmallReaders(t *testing.T) {
+	source := BufferedReaderSource(io.MultiReader(strings.NewReader("hello "), strings.NewReader("world")), 32)
+
+	first, err := source.Open()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	prefix := make([]byte, 3)
+	if _, err := io.ReadFull(first, prefix); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := source.Open()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, _ := io.ReadAll(second); string(got) != "hello world" {
+		t.Fatalf("expected the full content on retry, got %q", got)
+	}
+
+	big := BufferedReaderSource(io.MultiReader(strings.NewReader("0123456789")), 4)
+	body, _ := big.Open()
+	_, _ = io.ReadAll(body)
+	if _, err := big.Open(); !errors.Is(err, ymerrors.ErrNotRewindable) {
+		t.Fatalf("expected ErrNotRewindable past the buffer, got %v", err)
+	}
+}
+
+func TestBufferedReaderSourceStreamsPastTheBuffer(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	source := BufferedReaderSource(iotest.OneByteReader(strings.NewReader(content)), 10)
+
+	body, err := source.Open()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got strings.Builder
+	if _, err := io.Copy(&got, body); err != nil {
+		t.Fatalf("expected the first attempt to stream the whole reader, got %v", err)
+	}
+	if got.String() != content {
+		t.Fatalf("unexpected content %q", got.String())
+	}
+	if _, err := source.Open(); !errors.Is(err, ymerrors.ErrNotRewindable) {
+		t.Fatalf("expected ErrNotRewindable on retry, got %v", err)
+	}
+}
+
+func TestFileSourceReopensFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc.bin")
+	if err := os.WriteFile(path, []byte("file content"), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	source := FileSource(path)
+
+	if size, ok := source.Size(); !ok || size != int64(len("file content")) {
+		t.Fatalf("unexpected size %d %v", size, ok)
+	}
+	for range 2 {
+		r, err := source.Open()
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		data, _ := io.ReadAll(r)
+		r.Close()
+		if string(data) != "file content" {
+			t.Fatalf("unexpected content %q", data)
+		}
+	}
+}
@@ -2,9 +2,7 @@ package users
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
 	"net/url"
 
@@ -46,8 +44,8 @@ func (s *Service) GetUserLink(ctx context.Context, login ym.UserLogin) (*ym.User
 	defer resp.Body.Close()
 
 	var parsed userLinkResponse
-	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-		return nil, fmt.Errorf("%w: decode getUserLink response: %w", ymerrors.ErrInvalidResponse, err)
+	if err := s.client.DecodeJSON(ctx, resp, &parsed, "getUserLink"); err != nil {
+		return nil, err
 	}
 	if !parsed.OK {
 		return nil, &ymerrors.APIError{
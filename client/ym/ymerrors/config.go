@@ -2,12 +2,36 @@ package ymerrors
 
 import "time"
 
+// JitterMode selects how retry backoff delays are randomized.
+type JitterMode string
+
+const (
+	// JitterNone doubles the delay on every retry without randomization.
+	JitterNone JitterMode = "none"
+	// JitterFull picks a random delay between zero and the exponential backoff.
+	JitterFull JitterMode = "full"
+	// JitterDecorrelated picks a random delay between InitialBackoff and three times the previous delay.
+	JitterDecorrelated JitterMode = "decorrelated"
+)
+
 type RetryStrategy struct {
 	MaxAttempts    int           `json:"max_attempts"    yaml:"max_attempts"`
 	InitialBackoff time.Duration `json:"initial_backoff" yaml:"initial_backoff"`
 	MaxBackoff     time.Duration `json:"max_backoff"     yaml:"max_backoff"`
 	RetryHTTP      []int         `json:"retry_http"      yaml:"retry_http"`
 	RetryNetwork   bool          `json:"retry_network"   yaml:"retry_network"`
+	Jitter         JitterMode    `json:"jitter"          yaml:"jitter"`
+	// MaxElapsed limits the total time spent on a call including waits; zero means no limit.
+	MaxElapsed time.Duration `json:"max_elapsed" yaml:"max_elapsed"`
+	Budget     RetryBudget   `json:"budget"      yaml:"budget"`
+}
+
+// RetryBudget is a token bucket shared by all calls of a client. Every retry
+// spends one token; when the bucket is empty failed calls are not retried.
+// A zero MaxTokens disables the budget.
+type RetryBudget struct {
+	MaxTokens       float64 `json:"max_tokens"        yaml:"max_tokens"`
+	RefillPerSecond float64 `json:"refill_per_second" yaml:"refill_per_second"`
 }
 
 type RateLimitHandling struct {
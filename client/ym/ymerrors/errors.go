@@ -1,7 +1,9 @@
 package ymerrors
 
 import (
+	"context"
 	"errors"
+	"net"
 	"strconv"
 	"strings"
 	"time"
@@ -25,8 +27,58 @@ var (
 	ErrRequestTimeout  = errors.New("yandex-messenger: request timeout")
 	ErrNetworkError    = errors.New("yandex-messenger: network error")
 	ErrInvalidResponse = errors.New("yandex-messenger: invalid response")
+	ErrCircuitOpen     = errors.New("yandex-messenger: circuit open")
+	ErrNotRewindable   = errors.New("yandex-messenger: upload source cannot be replayed")
+	ErrTransferStalled = errors.New("yandex-messenger: transfer stalled")
+	ErrNoCallback      = errors.New("yandex-messenger: update has no callback payload")
+	ErrSendInProgress  = errors.New("yandex-messenger: send with this idempotency key is in progress")
 )
 
+func (k ErrorKind) String() string {
+	switch k {
+	case KindRateLimited:
+		return "rate_limited"
+	case KindInvalidToken:
+		return "invalid_token"
+	case KindUnauthorized:
+		return "unauthorized"
+	case KindBadRequest:
+		return "bad_request"
+	case KindNetwork:
+		return "network"
+	default:
+		return "unknown"
+	}
+}
+
+// KindOf classifies any client error: API errors report their kind, transport
+// failures are KindNetwork and everything else is KindUnknown.
+func KindOf(err error) ErrorKind {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Kind
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, ErrNetworkError) {
+		return KindNetwork
+	}
+
+	return KindUnknown
+}
+
+// IsTemporary reports whether a later attempt of a failed call may succeed:
+// rate limits, network and server errors, timeouts, stalled transfers and an
+// open circuit. Rejected tokens and bad requests are permanent.
+func IsTemporary(err error) bool {
+	switch KindOf(err) {
+	case KindRateLimited, KindNetwork:
+		return true
+	default:
+		return errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrRequestTimeout) ||
+			errors.Is(err, ErrTransferStalled) || errors.Is(err, context.DeadlineExceeded)
+	}
+}
+
 type APIError struct {
 	Kind        ErrorKind
 	Code        int
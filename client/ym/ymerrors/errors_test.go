@@ -1,7 +1,10 @@
 package ymerrors
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net"
 	"testing"
 	"time"
 )
@@ -46,3 +49,45 @@ func TestAPIErrorAs(t *testing.T) {
 		t.Fatalf("unexpected kind: %v", target.Kind)
 	}
 }
+
+func TestKindOf(t *testing.T) {
+	wrapped := fmt.Errorf("send: %w", &APIError{Kind: KindRateLimited})
+	if got := KindOf(wrapped); got != KindRateLimited {
+		t.Fatalf("expected KindRateLimited, got %v", got)
+	}
+	if got := KindOf(&net.OpError{Op: "dial", Err: errors.New("refused")}); got != KindNetwork {
+		t.Fatalf("expected KindNetwork, got %v", got)
+	}
+	if got := KindOf(errors.New("boom")); got != KindUnknown {
+		t.Fatalf("expected KindUnknown, got %v", got)
+	}
+	if KindRateLimited.String() != "rate_limited" {
+		t.Fatalf("unexpected kind name: %s", KindRateLimited)
+	}
+}
+
+func TestIsTemporary(t *testing.T) {
+	temporary := []error{
+		&APIError{Kind: KindRateLimited},
+		fmt.Errorf("poll: %w", &APIError{Kind: KindNetwork, HTTPStatus: 503}),
+		fmt.Errorf("send: %w", ErrCircuitOpen),
+		ErrRequestTimeout,
+		context.DeadlineExceeded,
+	}
+	for _, err := range temporary {
+		if !IsTemporary(err) {
+			t.Fatalf("expected %v to be temporary", err)
+		}
+	}
+	permanent := []error{
+		&APIError{Kind: KindInvalidToken},
+		&APIError{Kind: KindBadRequest, HTTPStatus: 400},
+		context.Canceled,
+		errors.New("boom"),
+	}
+	for _, err := range permanent {
+		if IsTemporary(err) {
+			t.Fatalf("expected %v to be permanent", err)
+		}
+	}
+}
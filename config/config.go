@@ -0,0 +1,292 @@
+// Package config loads ym.Config from YAML or JSON files and YM_* environment
+// variables.
+//
+// Sources are layered: every file in order, then the environment. A field set
+// by a later source overrides the same field from an earlier one; fields a source
+// does not mention are left untouched.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rekurt/ymsdk/client/ym"
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+)
+
+const (
+	DefaultEnvPrefix = "YM_"
+	// TokenFileInterval is how often a token file configured by token_file is checked for changes.
+	TokenFileInterval = 30 * time.Second
+)
+
+// Aliases of the ymerrors config types, so that configs can be built with a single import.
+type (
+	ErrorHandlingConfig = ymerrors.ErrorHandlingConfig
+	RetryStrategy       = ymerrors.RetryStrategy
+	RetryBudget         = ymerrors.RetryBudget
+	RateLimitHandling   = ymerrors.RateLimitHandling
+)
+
+type Options struct {
+	// Files are applied in order; later files override earlier ones.
+	Files []string
+	// EnvPrefix is the environment variable prefix. Defaults to "YM_".
+	EnvPrefix string
+	// DisableEnv skips environment variables.
+	DisableEnv bool
+	// LookupEnv defaults to os.LookupEnv.
+	LookupEnv func(key string) (string, bool)
+}
+
+// fileLayer is one configuration source. Nil fields are not set by the source.
+type fileLayer struct {
+	BaseURL       *string             `json:"base_url"       yaml:"base_url"`
+	Token         *string             `json:"token"          yaml:"token"`
+	TokenFile     *string             `json:"token_file"     yaml:"token_file"`
+	UpdatesMode   *string             `json:"updates_mode"   yaml:"updates_mode"`
+	ErrorHandling *errorHandlingLayer `json:"error_handling" yaml:"error_handling"`
+}
+
+type errorHandlingLayer struct {
+	RetryStrategy     *retryStrategyLayer `json:"retry_strategy"      yaml:"retry_strategy"`
+	RateLimitHandling *rateLimitLayer     `json:"rate_limit_handling" yaml:"rate_limit_handling"`
+	LoggingLevel      *string             `json:"logging_level"       yaml:"logging_level"`
+}
+
+type retryStrategyLayer struct {
+	MaxAttempts    *int              `json:"max_attempts"    yaml:"max_attempts"`
+	InitialBackoff *Duration         `json:"initial_backoff" yaml:"initial_backoff"`
+	MaxBackoff     *Duration         `json:"max_backoff"     yaml:"max_backoff"`
+	RetryHTTP      []int             `json:"retry_http"      yaml:"retry_http"`
+	RetryNetwork   *bool             `json:"retry_network"   yaml:"retry_network"`
+	Jitter         *string           `json:"jitter"          yaml:"jitter"`
+	MaxElapsed     *Duration         `json:"max_elapsed"     yaml:"max_elapsed"`
+	Budget         *retryBudgetLayer `json:"budget"          yaml:"budget"`
+}
+
+type retryBudgetLayer struct {
+	MaxTokens       *float64 `json:"max_tokens"        yaml:"max_tokens"`
+	RefillPerSecond *float64 `json:"refill_per_second" yaml:"refill_per_second"`
+}
+
+type rateLimitLayer struct {
+	UseRetryAfter  *bool     `json:"use_retry_after" yaml:"use_retry_after"`
+	DefaultBackoff *Duration `json:"default_backoff" yaml:"default_backoff"`
+}
+
+// Load reads files in order and then YM_* environment variables.
+func Load(files ...string) (ym.Config, error) {
+	return LoadOptions(Options{Files: files})
+}
+
+// LoadOptions builds a config from the configured sources and validates it.
+// All decoding and validation problems are reported together.
+func LoadOptions(opts Options) (ym.Config, error) {
+	if opts.EnvPrefix == "" {
+		opts.EnvPrefix = DefaultEnvPrefix
+	}
+	if opts.LookupEnv == nil {
+		opts.LookupEnv = os.LookupEnv
+	}
+
+	var cfg ym.Config
+	var tokenFile string
+	var errs []error
+
+	for _, path := range opts.Files {
+		layer, err := readFile(path)
+		if err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+		layer.apply(&cfg, &tokenFile)
+	}
+
+	if !opts.DisableEnv {
+		layer, err := fromEnv(opts.EnvPrefix, opts.LookupEnv)
+		errs = append(errs, err)
+		layer.apply(&cfg, &tokenFile)
+	}
+
+	if tokenFile != "" {
+		provider, err := ym.NewFileTokenProvider(tokenFile, TokenFileInterval)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			cfg.TokenProvider = provider
+		}
+	}
+
+	errs = append(errs, validate(cfg, tokenFile != ""))
+	if err := errors.Join(errs...); err != nil {
+		return cfg, fmt.Errorf("yandex-messenger/config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// Validate checks cfg and reports every problem found.
+func Validate(cfg ym.Config) error {
+	return validate(cfg, false)
+}
+
+// validate checks cfg. With hasTokenFile a missing token is not reported, since
+// the token file was either loaded or failed with its own error.
+func validate(cfg ym.Config, hasTokenFile bool) error {
+	var errs []error
+
+	if cfg.BaseURL != "" {
+		u, err := url.Parse(cfg.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("base_url %q must be an absolute http(s) URL", cfg.BaseURL))
+		}
+	}
+	if cfg.Token == "" && cfg.TokenProvider == nil && !hasTokenFile {
+		errs = append(errs, errors.New("token or token_file is required"))
+	}
+	switch cfg.UpdatesMode {
+	case "", ymerrors.UpdatesModePolling, ymerrors.UpdatesModeWebhook:
+	default:
+		errs = append(errs, fmt.Errorf("updates_mode %q must be polling or webhook", cfg.UpdatesMode))
+	}
+
+	rs := cfg.ErrorHandling.RetryStrategy
+	if rs.MaxAttempts < 0 {
+		errs = append(errs, fmt.Errorf("retry_strategy.max_attempts must not be negative, got %d", rs.MaxAttempts))
+	}
+	for name, d := range map[string]time.Duration{
+		"retry_strategy.initial_backoff":      rs.InitialBackoff,
+		"retry_strategy.max_backoff":          rs.MaxBackoff,
+		"retry_strategy.max_elapsed":          rs.MaxElapsed,
+		"rate_limit_handling.default_backoff": cfg.ErrorHandling.RateLimitHandling.DefaultBackoff,
+	} {
+		if d < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %s", name, d))
+		}
+	}
+	if rs.MaxBackoff > 0 && rs.InitialBackoff > rs.MaxBackoff {
+		errs = append(errs, fmt.Errorf(
+			"retry_strategy.initial_backoff %s exceeds max_backoff %s", rs.InitialBackoff, rs.MaxBackoff,
+		))
+	}
+	switch rs.Jitter {
+	case "", ymerrors.JitterNone, ymerrors.JitterFull, ymerrors.JitterDecorrelated:
+	default:
+		errs = append(errs, fmt.Errorf("retry_strategy.jitter %q must be none, full or decorrelated", rs.Jitter))
+	}
+	for _, code := range rs.RetryHTTP {
+		if code < 100 || code > 599 {
+			errs = append(errs, fmt.Errorf("retry_strategy.retry_http contains invalid status %d", code))
+		}
+	}
+	if rs.Budget.MaxTokens < 0 || rs.Budget.RefillPerSecond < 0 {
+		errs = append(errs, errors.New("retry_strategy.budget values must not be negative"))
+	}
+	if _, err := ym.ParseLogLevel(cfg.ErrorHandling.LoggingLevel); err != nil {
+		errs = append(errs, fmt.Errorf(
+			"logging_level %q must be debug, info, warn, error or silent", cfg.ErrorHandling.LoggingLevel,
+		))
+	}
+
+	return errors.Join(errs...)
+}
+
+func readFile(path string) (*fileLayer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	var layer fileLayer
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&layer)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&layer)
+	default:
+		return nil, fmt.Errorf("read %s: unsupported config format, use .json, .yaml or .yml", path)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+
+	return &layer, nil
+}
+
+// apply copies every field set in f to cfg. A token and a token file replace
+// each other, so the credential of the last layer that sets one wins; within
+// a layer token_file takes precedence.
+func (f *fileLayer) apply(cfg *ym.Config, tokenFile *string) {
+	if f == nil {
+		return
+	}
+	set(&cfg.BaseURL, f.BaseURL)
+	if f.Token != nil {
+		cfg.Token, *tokenFile = *f.Token, ""
+	}
+	if f.TokenFile != nil {
+		cfg.Token, *tokenFile = "", *f.TokenFile
+	}
+	if f.UpdatesMode != nil {
+		cfg.UpdatesMode = ymerrors.UpdatesMode(*f.UpdatesMode)
+	}
+
+	eh := f.ErrorHandling
+	if eh == nil {
+		return
+	}
+	set(&cfg.ErrorHandling.LoggingLevel, eh.LoggingLevel)
+
+	if rs := eh.RetryStrategy; rs != nil {
+		dst := &cfg.ErrorHandling.RetryStrategy
+		set(&dst.MaxAttempts, rs.MaxAttempts)
+		setDuration(&dst.InitialBackoff, rs.InitialBackoff)
+		setDuration(&dst.MaxBackoff, rs.MaxBackoff)
+		if rs.RetryHTTP != nil {
+			dst.RetryHTTP = rs.RetryHTTP
+		}
+		set(&dst.RetryNetwork, rs.RetryNetwork)
+		if rs.Jitter != nil {
+			dst.Jitter = ymerrors.JitterMode(*rs.Jitter)
+		}
+		setDuration(&dst.MaxElapsed, rs.MaxElapsed)
+		if rs.Budget != nil {
+			set(&dst.Budget.MaxTokens, rs.Budget.MaxTokens)
+			set(&dst.Budget.RefillPerSecond, rs.Budget.RefillPerSecond)
+		}
+	}
+
+	if rl := eh.RateLimitHandling; rl != nil {
+		dst := &cfg.ErrorHandling.RateLimitHandling
+		set(&dst.UseRetryAfter, rl.UseRetryAfter)
+		setDuration(&dst.DefaultBackoff, rl.DefaultBackoff)
+	}
+}
+
+func set[T any](dst *T, src *T) {
+	if src != nil {
+		*dst = *src
+	}
+}
+
+func setDuration(dst *time.Duration, src *Duration) {
+	if src != nil {
+		*dst = time.Duration(*src)
+	}
+}
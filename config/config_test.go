@@ -0,0 +1,189 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym"
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+
+	return path
+}
+
+func env(values map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := values[key]
+
+		return v, ok
+	}
+}
+
+func TestLoadLayersFilesAndEnv(t *testing.T) {
+	base := writeFile(t, "base.yaml", `
+base_url: https://example.com
+token: from-file
+error_handling:
+  retry_strategy:
+    max_attempts: 3
+    initial_backoff: 500ms
+    max_backoff: 5s
+    retry_http: [502, 503]
+  rate_limit_handling:
+    use_retry_after: true
+    default_backoff: 2s
+`)
+	override := writeFile(t, "override.json", `{"error_handling":{"retry_strategy":{"max_attempts":5,"jitter":"full"}}}`)
+
+	cfg, err := LoadOptions(Options{
+		Files: []string{base, override},
+		LookupEnv: env(map[string]string{
+			"YM_TOKEN":             "from-env",
+			"YM_RETRY_MAX_BACKOFF": "10s",
+		}),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rs := cfg.ErrorHandling.RetryStrategy
+	if cfg.BaseURL != "https://example.com" || cfg.Token != "from-env" {
+		t.Fatalf("unexpected base url or token: %q %q", cfg.BaseURL, cfg.Token)
+	}
+	if rs.MaxAttempts != 5 || rs.InitialBackoff != 500*time.Millisecond || rs.MaxBackoff != 10*time.Second {
+		t.Fatalf("unexpected retry strategy: %+v", rs)
+	}
+	if rs.Jitter != ymerrors.JitterFull || len(rs.RetryHTTP) != 2 {
+		t.Fatalf("unexpected retry strategy: %+v", rs)
+	}
+	rl := cfg.ErrorHandling.RateLimitHandling
+	if !rl.UseRetryAfter || rl.DefaultBackoff != 2*time.Second {
+		t.Fatalf("unexpected rate limit handling: %+v", rl)
+	}
+}
+
+func TestLoadReadsTokenFile(t *testing.T) {
+	tokenPath := writeFile(t, "token", "file-token\n")
+
+	cfg, err := LoadOptions(Options{LookupEnv: env(map[string]string{"YM_TOKEN_FILE": tokenPath})})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.TokenProvider == nil {
+		t.Fatal("expected token provider")
+	}
+	token, err := cfg.TokenProvider.Token(t.Context())
+	if err != nil || token != "file-token" {
+		t.Fatalf("unexpected token %q: %v", token, err)
+	}
+}
+
+func TestLoadLaterLayerReplacesCredential(t *testing.T) {
+	tokenPath := writeFile(t, "token", "file-token\n")
+	path := writeFile(t, "config.yaml", "token_file: "+tokenPath+"\n")
+
+	cfg, err := LoadOptions(Options{Files: []string{path}, LookupEnv: env(map[string]string{"YM_TOKEN": "env-token"})})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Token != "env-token" || cfg.TokenProvider != nil {
+		t.Fatalf("expected YM_TOKEN to replace token_file, got token %q provider %v", cfg.Token, cfg.TokenProvider)
+	}
+
+	path = writeFile(t, "config.yaml", "token: file-token\n")
+	cfg, err = LoadOptions(Options{Files: []string{path}, LookupEnv: env(map[string]string{"YM_TOKEN_FILE": tokenPath})})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Token != "" || cfg.TokenProvider == nil {
+		t.Fatalf("expected YM_TOKEN_FILE to replace token, got token %q provider %v", cfg.Token, cfg.TokenProvider)
+	}
+}
+
+func TestLoadAggregatesErrors(t *testing.T) {
+	path := writeFile(t, "bad.yaml", `
+base_url: example.com
+error_handling:
+  retry_strategy:
+    initial_backoff: 10s
+    max_backoff: 1s
+    jitter: sometimes
+`)
+
+	_, err := LoadOptions(Options{
+		Files:     []string{path},
+		LookupEnv: env(map[string]string{"YM_RETRY_MAX_ATTEMPTS": "many"}),
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, want := range []string{"base_url", "token or token_file", "exceeds max_backoff", "jitter", "YM_RETRY_MAX_ATTEMPTS"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in error, got: %v", want, err)
+		}
+	}
+}
+
+func TestLoadRejectsBareNumberDurations(t *testing.T) {
+	path := writeFile(t, "bare.yaml", "token: x\nerror_handling:\n  retry_strategy:\n    max_backoff: 30\n")
+
+	_, err := LoadOptions(Options{Files: []string{path}, DisableEnv: true})
+	if err == nil || !strings.Contains(err.Error(), "a unit is required") {
+		t.Fatalf("expected bare number error, got %v", err)
+	}
+}
+
+func TestLoadReportsMissingTokenFileOnce(t *testing.T) {
+	path := writeFile(t, "token.yaml", "token_file: "+filepath.Join(t.TempDir(), "missing")+"\n")
+
+	_, err := LoadOptions(Options{Files: []string{path}, DisableEnv: true})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if strings.Contains(err.Error(), "token or token_file is required") {
+		t.Fatalf("expected only the token file error, got: %v", err)
+	}
+}
+
+func TestLoadRejectsUnknownFields(t *testing.T) {
+	path := writeFile(t, "typo.yaml", "token: x\nretry_strategy:\n  max_attempts: 3\n")
+
+	_, err := LoadOptions(Options{Files: []string{path}, DisableEnv: true})
+	if err == nil || !strings.Contains(err.Error(), "retry_strategy") {
+		t.Fatalf("expected unknown field error, got %v", err)
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	_, err := LoadOptions(Options{Files: []string{filepath.Join(t.TempDir(), "missing.yaml")}, DisableEnv: true})
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected not exist error, got %v", err)
+	}
+}
+
+func TestConfigRedactsToken(t *testing.T) {
+	cfg, err := LoadOptions(Options{LookupEnv: env(map[string]string{"YM_TOKEN": "super-secret"})})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg.TokenProvider = ym.StaticToken("provider-secret")
+
+	for _, verb := range []string{"%v", "%+v", "%#v", "%s"} {
+		if out := fmt.Sprintf(verb, cfg); strings.Contains(out, "super-secret") || strings.Contains(out, "provider-secret") {
+			t.Fatalf("token leaked with %s: %s", verb, out)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration that decodes from human-readable strings such as
+// "500ms" or "1m30s". Bare numbers other than 0 are rejected: without a unit,
+// "timeout: 30" could mean seconds or, as in the JSON encoding of
+// time.Duration, nanoseconds.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	return d.set(raw)
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var raw any
+	if err := node.Decode(&raw); err != nil {
+		return err
+	}
+
+	return d.set(raw)
+}
+
+func (d *Duration) set(raw any) error {
+	switch v := raw.(type) {
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", v, err)
+		}
+		*d = Duration(parsed)
+	case float64:
+		if v != 0 {
+			return fmt.Errorf("invalid duration %v: a unit is required, e.g. \"%vs\"", v, v)
+		}
+		*d = 0
+	case int:
+		if v != 0 {
+			return fmt.Errorf("invalid duration %d: a unit is required, e.g. \"%ds\"", v, v)
+		}
+		*d = 0
+	default:
+		return fmt.Errorf("invalid duration %v", raw)
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fromEnv builds a layer from prefixed environment variables, e.g. YM_TOKEN or
+// YM_RETRY_INITIAL_BACKOFF=500ms.
+func fromEnv(prefix string, lookup func(string) (string, bool)) (*fileLayer, error) {
+	var errs []error
+	get := func(name string) (string, bool) {
+		v, ok := lookup(prefix + name)
+
+		return strings.TrimSpace(v), ok && strings.TrimSpace(v) != ""
+	}
+	str := func(name string) *string {
+		if v, ok := get(name); ok {
+			return &v
+		}
+
+		return nil
+	}
+	integer := func(name string) *int {
+		v, ok := get(name)
+		if !ok {
+			return nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s%s: %w", prefix, name, err))
+
+			return nil
+		}
+
+		return &n
+	}
+	float := func(name string) *float64 {
+		v, ok := get(name)
+		if !ok {
+			return nil
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s%s: %w", prefix, name, err))
+
+			return nil
+		}
+
+		return &n
+	}
+	boolean := func(name string) *bool {
+		v, ok := get(name)
+		if !ok {
+			return nil
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s%s: %w", prefix, name, err))
+
+			return nil
+		}
+
+		return &b
+	}
+	duration := func(name string) *Duration {
+		v, ok := get(name)
+		if !ok {
+			return nil
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s%s: %w", prefix, name, err))
+
+			return nil
+		}
+		cd := Duration(d)
+
+		return &cd
+	}
+
+	var retryHTTP []int
+	if v, ok := get("RETRY_HTTP"); ok {
+		for _, part := range strings.Split(v, ",") {
+			code, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%sRETRY_HTTP: %w", prefix, err))
+
+				continue
+			}
+			retryHTTP = append(retryHTTP, code)
+		}
+	}
+
+	layer := &fileLayer{
+		BaseURL:     str("BASE_URL"),
+		Token:       str("TOKEN"),
+		TokenFile:   str("TOKEN_FILE"),
+		UpdatesMode: str("UPDATES_MODE"),
+		ErrorHandling: &errorHandlingLayer{
+			LoggingLevel: str("LOGGING_LEVEL"),
+			RetryStrategy: &retryStrategyLayer{
+				MaxAttempts:    integer("RETRY_MAX_ATTEMPTS"),
+				InitialBackoff: duration("RETRY_INITIAL_BACKOFF"),
+				MaxBackoff:     duration("RETRY_MAX_BACKOFF"),
+				RetryHTTP:      retryHTTP,
+				RetryNetwork:   boolean("RETRY_NETWORK"),
+				Jitter:         str("RETRY_JITTER"),
+				MaxElapsed:     duration("RETRY_MAX_ELAPSED"),
+				Budget: &retryBudgetLayer{
+					MaxTokens:       float("RETRY_BUDGET_MAX_TOKENS"),
+					RefillPerSecond: float("RETRY_BUDGET_REFILL_PER_SECOND"),
+				},
+			},
+			RateLimitHandling: &rateLimitLayer{
+				UseRetryAfter:  boolean("RATE_LIMIT_USE_RETRY_AFTER"),
+				DefaultBackoff: duration("RATE_LIMIT_DEFAULT_BACKOFF"),
+			},
+		},
+	}
+
+	return layer, errors.Join(errs...)
+}
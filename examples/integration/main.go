@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"io"
 	"log"
@@ -203,27 +202,17 @@ func main() {
 }
 
 func sendFile(ctx context.Context, svc *messages.Service, chatID ym.ChatID, path string) (*ym.Message, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-
 	return svc.SendFile(ctx, &messages.SendFileRequest{
 		ChatID:   &chatID,
-		Document: bytes.NewReader(data),
+		Source:   ym.FileSource(path),
 		Filename: filepath.Base(path),
 	})
 }
 
 func sendImage(ctx context.Context, svc *messages.Service, chatID ym.ChatID, path string) (*ym.Message, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-
 	return svc.SendImage(ctx, &messages.SendImageRequest{
 		ChatID:   &chatID,
-		Image:    bytes.NewReader(data),
+		Source:   ym.FileSource(path),
 		Filename: filepath.Base(path),
 	})
 }
@@ -235,12 +224,8 @@ func sendGallery(ctx context.Context, svc *messages.Service, chatID ym.ChatID, p
 		if p == "" {
 			continue
 		}
-		data, err := os.ReadFile(p)
-		if err != nil {
-			return nil, err
-		}
 		parts = append(parts, messages.FilePart{
-			Reader:   bytes.NewReader(data),
+			Source:   ym.FileSource(p),
 			Filename: filepath.Base(p),
 		})
 	}
@@ -13,29 +13,16 @@ import (
 	"github.com/rekurt/ymsdk/client/ym"
 	"github.com/rekurt/ymsdk/client/ym/updates"
 	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+	"github.com/rekurt/ymsdk/config"
 	"github.com/rekurt/ymsdk/middleware"
 )
 
 func main() {
-	token := os.Getenv("YM_TOKEN")
-	if token == "" {
-		log.Fatal("YM_TOKEN is required")
-	}
-
-	cfg := ym.Config{
-		Token: token,
-		ErrorHandling: ymerrors.ErrorHandlingConfig{
-			RetryStrategy: ymerrors.RetryStrategy{
-				MaxAttempts:    3,
-				InitialBackoff: 500 * time.Millisecond,
-				MaxBackoff:     5 * time.Second,
-				RetryNetwork:   true,
-			},
-			RateLimitHandling: ymerrors.RateLimitHandling{
-				UseRetryAfter:  true,
-				DefaultBackoff: time.Second,
-			},
-		},
+	// Config files are passed as arguments, e.g. examples/poller/config.yaml;
+	// YM_* environment variables such as YM_TOKEN override them.
+	cfg, err := config.Load(os.Args[1:]...)
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	client := ym.NewClient(cfg)
@@ -1,20 +1,19 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
-	"io"
+	"context"
 	"log"
 	"net/http"
 	"os"
 
+	"github.com/rekurt/ymsdk/bot"
 	"github.com/rekurt/ymsdk/client"
 	"github.com/rekurt/ymsdk/client/ym"
 	"github.com/rekurt/ymsdk/client/ym/messages"
 	"github.com/rekurt/ymsdk/client/ym/ymerrors"
 )
 
-// Minimal webhook receiver: starts HTTP server, parses updates from YM and replies via SendToChat.
+// Minimal webhook receiver: starts HTTP server, routes updates from YM with bot.Router and replies via SendToChat.
 // Env:
 // YM_TOKEN (required), YM_REPLY_CHAT (optional default from incoming update),
 // YM_PORT (default 8080).
@@ -33,41 +32,30 @@ func main() {
 		UpdatesMode: ymerrors.UpdatesModeWebhook,
 	})
 
-	http.HandleFunc("/webhook", func(w http.ResponseWriter, r *http.Request) {
-		defer r.Body.Close()
-		body, _ := io.ReadAll(r.Body)
-
-		var upd ym.Update
-		if err := json.Unmarshal(body, &upd); err != nil {
-			http.Error(w, "bad request", http.StatusBadRequest)
-
-			return
-		}
-
+	router := bot.NewRouter()
+	router.Handle(bot.Kind(ym.UpdateKindText), func(ctx context.Context, upd *bot.Update) error {
 		log.Printf("got update %d", upd.UpdateID)
-		if upd.MessageID > 0 && upd.Chat != nil && upd.From != nil {
-			replyChat := os.Getenv("YM_REPLY_CHAT")
-			target := upd.Chat.ID
-			if replyChat != "" {
-				target = ym.ChatID(replyChat)
-			}
-
-			_, err := s.Messages.SendToChat(r.Context(), target, "echo: "+upd.Text, &messages.SendMessageOptions{
-				ReplyToMessageID: fmt.Sprintf("%d", upd.MessageID),
-			})
-			if err != nil {
-				log.Printf("send reply failed: %v", err)
-			}
+		if upd.Chat == nil {
+			return nil
+		}
+		target := upd.Chat.ID
+		if replyChat := os.Getenv("YM_REPLY_CHAT"); replyChat != "" {
+			target = ym.ChatID(replyChat)
 		}
 
-		w.WriteHeader(http.StatusOK)
-		_, err := w.Write([]byte(`{"ok":true}`))
+		_, err := s.Messages.SendToChat(ctx, target, "echo: "+upd.Text, &messages.SendMessageOptions{
+			SendOptions: ym.SendOptions{ReplyMessageID: upd.MessageID},
+		})
 		if err != nil {
-			log.Printf("write failed: %v", err)
-
-			return
+			log.Printf("send reply failed: %v", err)
 		}
+
+		return nil
+	})
+	router.NotHandled(func(_ context.Context, upd ym.Update) {
+		log.Printf("skipped %s update %d", upd.Kind(), upd.UpdateID)
 	})
+	http.Handle("/webhook", router)
 
 	log.Printf("listening on :%s", port)
 	log.Fatal(http.ListenAndServe(":"+port, nil))
@@ -0,0 +1,161 @@
+// Package metrics exposes SDK and bot runtime metrics in the Prometheus text
+// exposition format without depending on the Prometheus client library.
+//
+// Wire the collector into the client with Instrument and into the polling loop
+// with updates.WithObserver, then serve it on the scrape endpoint:
+//
+//	collector := metrics.NewCollector()
+//	client := ym.NewClient(collector.Instrument(cfg))
+//	upd := updates.NewService(client, updates.WithObserver(collector))
+//	http.Handle("/metrics", collector)
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym"
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+)
+
+var (
+	durationBuckets   = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	retryAfterBuckets = []float64{0.1, 0.5, 1, 2, 5, 10, 30, 60, 120, 300}
+	batchBuckets      = []float64{0, 1, 2, 5, 10, 20, 50, 100}
+)
+
+// Collector accumulates client and polling metrics and serves them over HTTP.
+type Collector struct {
+	requests        *vec
+	requestDuration *vec
+	retries         *vec
+	rateLimited     *vec
+	retryAfter      *vec
+	rateLimitWait   *vec
+	polls           *vec
+	updates         *vec
+	updatesPerPoll  *vec
+	offset          *vec
+	handlerDuration *vec
+	families        []family
+}
+
+func NewCollector() *Collector {
+	c := &Collector{
+		requests: newVec("counter", "ym_requests_total",
+			"Logical Bot API calls by endpoint and error kind.", nil, "method", "endpoint", "error_kind"),
+		requestDuration: newVec("histogram", "ym_request_duration_seconds",
+			"Duration of logical Bot API calls including retries.", durationBuckets, "endpoint"),
+		retries: newVec("counter", "ym_retries_total",
+			"Retried HTTP attempts.", nil, "endpoint"),
+		rateLimited: newVec("counter", "ym_rate_limited_total",
+			"HTTP 429 responses.", nil, "endpoint"),
+		retryAfter: newVec("histogram", "ym_retry_after_seconds",
+			"Retry-After values reported by rate limited responses.", retryAfterBuckets),
+		rateLimitWait: newVec("histogram", "ym_rate_limit_wait_seconds",
+			"Time spent waiting for the client rate limiter.", retryAfterBuckets, "endpoint"),
+		polls: newVec("counter", "ym_updates_polls_total",
+			"getUpdates calls by result.", nil, "result"),
+		updates: newVec("counter", "ym_updates_received_total",
+			"Updates received by the polling loop.", nil),
+		updatesPerPoll: newVec("histogram", "ym_updates_per_poll",
+			"Number of updates returned by a single getUpdates call.", batchBuckets),
+		offset: newVec("gauge", "ym_updates_offset",
+			"Next update offset of the polling loop.", nil),
+		handlerDuration: newVec("histogram", "ym_handler_duration_seconds",
+			"Update handler duration by result.", durationBuckets, "result"),
+	}
+	c.families = []family{
+		c.requests, c.requestDuration, c.retries, c.rateLimited, c.retryAfter, c.rateLimitWait,
+		c.polls, c.updates, c.updatesPerPoll, c.offset, c.handlerDuration,
+	}
+
+	return c
+}
+
+// Instrument returns cfg with the collector interceptors appended.
+func (c *Collector) Instrument(cfg ym.Config) ym.Config {
+	cfg.Interceptors = append(append([]ym.Interceptor(nil), cfg.Interceptors...), c.Interceptor())
+	cfg.AttemptInterceptors = append(append([]ym.Interceptor(nil), cfg.AttemptInterceptors...), c.AttemptInterceptor())
+
+	return cfg
+}
+
+// Interceptor counts logical calls and measures their duration.
+func (c *Collector) Interceptor() ym.Interceptor {
+	return func(ctx context.Context, req *ym.Request, next ym.Invoker) (*http.Response, error) {
+		started := time.Now()
+		resp, err := next(ctx, req)
+
+		kind := "none"
+		if err != nil {
+			kind = ymerrors.KindOf(err).String()
+		}
+		c.requests.add(1, req.Method, req.Endpoint(), kind)
+		c.requestDuration.observe(time.Since(started).Seconds(), req.Endpoint())
+
+		return resp, err
+	}
+}
+
+// AttemptInterceptor counts retries, 429 responses and Retry-After values.
+func (c *Collector) AttemptInterceptor() ym.Interceptor {
+	return func(ctx context.Context, req *ym.Request, next ym.Invoker) (*http.Response, error) {
+		if req.Attempt > 1 {
+			c.retries.add(1, req.Endpoint())
+		}
+
+		resp, err := next(ctx, req)
+
+		var apiErr *ymerrors.APIError
+		if errors.As(err, &apiErr) && apiErr.Kind == ymerrors.KindRateLimited {
+			c.rateLimited.add(1, req.Endpoint())
+			if apiErr.RetryAfter > 0 {
+				c.retryAfter.observe(apiErr.RetryAfter.Seconds())
+			}
+		}
+
+		return resp, err
+	}
+}
+
+// ObserveRateLimitWait records a client rate limiter wait. It matches ym.RateLimiterConfig.OnWait.
+func (c *Collector) ObserveRateLimitWait(req *ym.Request, wait time.Duration) {
+	c.rateLimitWait.observe(wait.Seconds(), req.Endpoint())
+}
+
+// ObservePoll implements updates.Observer.
+func (c *Collector) ObservePoll(_ context.Context, received int, nextOffset int64, err error) {
+	if err != nil {
+		c.polls.add(1, "error")
+
+		return
+	}
+	c.polls.add(1, "ok")
+	c.updates.add(float64(received))
+	c.updatesPerPoll.observe(float64(received))
+	c.offset.set(float64(nextOffset))
+}
+
+// ObserveHandler implements updates.Observer.
+func (c *Collector) ObserveHandler(_ context.Context, _ ym.Update, elapsed time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	c.handlerDuration.observe(elapsed.Seconds(), result)
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	return writeFamilies(w, c.families)
+}
+
+// ServeHTTP serves the metrics for scraping.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = c.WriteTo(w)
+}
@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym"
+	"github.com/rekurt/ymsdk/client/ym/updates"
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+	"github.com/rekurt/ymsdk/internal/testutil"
+)
+
+func TestCollectorExposesClientMetrics(t *testing.T) {
+	collector := NewCollector()
+	limited := testutil.NewResponse(http.StatusTooManyRequests, `{"ok":false}`)
+	limited.Header.Set("Retry-After", "1")
+	client := ym.NewClientWithHTTP(collector.Instrument(ym.Config{
+		BaseURL: "http://example.com",
+		ErrorHandling: ymerrors.ErrorHandlingConfig{
+			RetryStrategy:     ymerrors.RetryStrategy{MaxAttempts: 2},
+			RateLimitHandling: ymerrors.RateLimitHandling{DefaultBackoff: time.Millisecond},
+		},
+	}), &testutil.FakeDoer{
+		Responses: []*http.Response{
+			limited,
+			testutil.NewResponse(http.StatusOK, `{"ok":true}`),
+			testutil.NewResponse(http.StatusBadRequest, `{"ok":false}`),
+		},
+	})
+
+	resp, err := client.DoRequest(context.Background(), http.MethodGet, "/bot/v1/messages/getUpdates?limit=1", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	_, _ = client.DoRequest(ym.WithoutRetries(context.Background()), http.MethodPost, "/bot/v1/chats/create/", nil)
+
+	rec := httptest.NewRecorder()
+	collector.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		"# TYPE ym_requests_total counter",
+		`ym_requests_total{method="GET",endpoint="/bot/v1/messages/getUpdates",error_kind="none"} 1`,
+		`ym_requests_total{method="POST",endpoint="/bot/v1/chats/create/",error_kind="bad_request"} 1`,
+		`ym_retries_total{endpoint="/bot/v1/messages/getUpdates"} 1`,
+		`ym_rate_limited_total{endpoint="/bot/v1/messages/getUpdates"} 1`,
+		`ym_retry_after_seconds_bucket{le="1"} 1`,
+		`ym_retry_after_seconds_count 1`,
+		`ym_request_duration_seconds_count{endpoint="/bot/v1/messages/getUpdates"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in exposition:\n%s", want, body)
+		}
+	}
+}
+
+func TestCollectorObservesPollLoop(t *testing.T) {
+	collector := NewCollector()
+	client := ym.NewClientWithHTTP(ym.Config{BaseURL: "http://example.com"}, &testutil.FakeDoer{
+		Responses: []*http.Response{
+			testutil.NewResponse(http.StatusOK,
+				`{"ok":true,"updates":[{"update_id":1,"text":"a"},{"update_id":2,"text":"b"}],"next_offset":3}`),
+		},
+	})
+	svc := updates.NewService(client, updates.WithObserver(collector))
+
+	stop := errors.New("stop")
+	handled := 0
+	err := svc.PollLoop(context.Background(), updates.GetUpdatesParams{}, func(context.Context, ym.Update) error {
+		handled++
+		if handled == 2 {
+			return stop
+		}
+
+		return nil
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("expected handler error, got %v", err)
+	}
+
+	var b strings.Builder
+	if _, err := collector.WriteTo(&b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body := b.String()
+	for _, want := range []string{
+		`ym_updates_polls_total{result="ok"} 1`,
+		"ym_updates_received_total 2",
+		"ym_updates_offset 3",
+		`ym_updates_per_poll_bucket{le="2"} 1`,
+		`ym_handler_duration_seconds_count{result="ok"} 1`,
+		`ym_handler_duration_seconds_count{result="error"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in exposition:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type family interface {
+	write(w *bufio.Writer)
+}
+
+type series struct {
+	labels []string
+	value  float64
+	// histogram state
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+type vec struct {
+	mu      sync.Mutex
+	kind    string
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	series  map[string]*series
+}
+
+func newVec(kind, name, help string, buckets []float64, labels ...string) *vec {
+	return &vec{
+		kind:    kind,
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+}
+
+func (v *vec) get(values []string) *series {
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labels: append([]string(nil), values...)}
+		if v.kind == "histogram" {
+			s.counts = make([]uint64, len(v.buckets))
+		}
+		v.series[key] = s
+	}
+
+	return s
+}
+
+func (v *vec) add(delta float64, values ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.get(values).value += delta
+}
+
+func (v *vec) set(value float64, values ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.get(values).value = value
+}
+
+func (v *vec) observe(value float64, values ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	s := v.get(values)
+	for i, upper := range v.buckets {
+		if value <= upper {
+			s.counts[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+func (v *vec) write(w *bufio.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, v.help, v.name, v.kind)
+
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := v.series[k]
+		if v.kind != "histogram" {
+			fmt.Fprintf(w, "%s%s %s\n", v.name, formatLabels(v.labels, s.labels, "", ""), formatFloat(s.value))
+
+			continue
+		}
+		for i, upper := range v.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n",
+				v.name, formatLabels(v.labels, s.labels, "le", formatFloat(upper)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", v.name, formatLabels(v.labels, s.labels, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", v.name, formatLabels(v.labels, s.labels, "", ""), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", v.name, formatLabels(v.labels, s.labels, "", ""), s.count)
+	}
+}
+
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extraName)
+		b.WriteString(`="`)
+		b.WriteString(extraValue)
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// writeFamilies writes all families in the Prometheus text exposition format.
+func writeFamilies(out io.Writer, families []family) (int64, error) {
+	cw := &countingWriter{w: out}
+	w := bufio.NewWriter(cw)
+	for _, f := range families {
+		f.write(w)
+	}
+	err := w.Flush()
+
+	return cw.n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+
+	return n, err
+}
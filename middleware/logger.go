@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/rekurt/ymsdk/client/ym"
+)
+
+type zapLogger struct {
+	logger *zap.Logger
+}
+
+// NewZapLogger adapts a zap logger for ym.Config.Logger. A nil logger discards everything.
+func NewZapLogger(logger *zap.Logger) ym.Logger {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return zapLogger{logger: logger.WithOptions(zap.AddCallerSkip(1))}
+}
+
+func (l zapLogger) Log(ctx context.Context, level ym.LogLevel, msg string, keyvals ...any) {
+	var lvl zapcore.Level
+	switch level {
+	case ym.LevelDebug:
+		lvl = zapcore.DebugLevel
+	case ym.LevelInfo:
+		lvl = zapcore.InfoLevel
+	case ym.LevelWarn:
+		lvl = zapcore.WarnLevel
+	default:
+		lvl = zapcore.ErrorLevel
+	}
+
+	ce := l.logger.Check(lvl, msg)
+	if ce == nil {
+		return
+	}
+	fields := make([]zap.Field, 0, len(keyvals)/2+1)
+	if requestID, ok := ctx.Value(requestIDKey).(string); ok && requestID != "" {
+		fields = append(fields, zap.String("request_id", requestID))
+	}
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		key, ok := keyvals[i].(string)
+		if !ok {
+			key = "!BADKEY"
+		}
+		if err, isErr := keyvals[i+1].(error); isErr {
+			fields = append(fields, zap.NamedError(key, err))
+
+			continue
+		}
+		fields = append(fields, zap.Any(key, keyvals[i+1]))
+	}
+	ce.Write(fields...)
+}
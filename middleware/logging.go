@@ -19,6 +19,13 @@ func WithRequestID(ctx context.Context, requestID string) context.Context {
 	return context.WithValue(ctx, requestIDKey, requestID)
 }
 
+// RequestID returns the request id set by WithRequestID, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+
+	return id
+}
+
 func LogError(logger *zap.Logger, ctx context.Context, err error, method, endpoint string, params map[string]any) {
 	if logger == nil || err == nil {
 		return
@@ -65,6 +72,7 @@ func LogUpdateWithRawData(logger *zap.Logger, ctx context.Context, update ym.Upd
 
 	fields := []zap.Field{
 		zap.Int64("update_id", update.UpdateID),
+		zap.String("kind", string(update.Kind())),
 		zap.Any("raw_data", rawData),
 	}
 
@@ -0,0 +1,208 @@
+// Package otel instruments the ym.Client request pipeline with OpenTelemetry
+// spans and metrics.
+//
+// Every logical API call produces a span; every HTTP attempt produces a child
+// span, and retries are recorded as events on the call span.
+package otel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	otelglobal "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/rekurt/ymsdk/client/ym"
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+)
+
+const instrumentationName = "github.com/rekurt/ymsdk/otel"
+
+const (
+	AttrMethod     = attribute.Key("http.request.method")
+	AttrStatusCode = attribute.Key("http.response.status_code")
+	AttrEndpoint   = attribute.Key("ym.endpoint")
+	AttrChatID     = attribute.Key("ym.chat_id")
+	AttrLogin      = attribute.Key("ym.login")
+	AttrErrorKind  = attribute.Key("ym.error_kind")
+	AttrRequestID  = attribute.Key("ym.request_id")
+	AttrAttempt    = attribute.Key("ym.attempt")
+)
+
+type Config struct {
+	// TracerProvider defaults to the global provider.
+	TracerProvider trace.TracerProvider
+	// MeterProvider defaults to the global provider.
+	MeterProvider metric.MeterProvider
+}
+
+// Instrumentation holds the tracer and instruments shared by the interceptors.
+type Instrumentation struct {
+	tracer        trace.Tracer
+	requests      metric.Int64Counter
+	retries       metric.Int64Counter
+	duration      metric.Float64Histogram
+	rateLimitWait metric.Float64Histogram
+	retryAfter    metric.Float64Histogram
+}
+
+func New(cfg Config) (*Instrumentation, error) {
+	if cfg.TracerProvider == nil {
+		cfg.TracerProvider = otelglobal.GetTracerProvider()
+	}
+	if cfg.MeterProvider == nil {
+		cfg.MeterProvider = otelglobal.GetMeterProvider()
+	}
+	meter := cfg.MeterProvider.Meter(instrumentationName)
+
+	inst := &Instrumentation{tracer: cfg.TracerProvider.Tracer(instrumentationName)}
+	var err, e error
+	inst.requests, e = meter.Int64Counter("ym.client.requests",
+		metric.WithDescription("Logical Bot API calls."))
+	err = errors.Join(err, e)
+	inst.retries, e = meter.Int64Counter("ym.client.retries",
+		metric.WithDescription("Retried HTTP attempts."))
+	err = errors.Join(err, e)
+	inst.duration, e = meter.Float64Histogram("ym.client.duration",
+		metric.WithDescription("Duration of logical Bot API calls including retries."), metric.WithUnit("s"))
+	err = errors.Join(err, e)
+	inst.rateLimitWait, e = meter.Float64Histogram("ym.client.rate_limit.wait",
+		metric.WithDescription("Time spent waiting for the client rate limiter."), metric.WithUnit("s"))
+	err = errors.Join(err, e)
+	inst.retryAfter, e = meter.Float64Histogram("ym.client.retry_after",
+		metric.WithDescription("Retry-After delays requested by the server."), metric.WithUnit("s"))
+	err = errors.Join(err, e)
+	if err != nil {
+		return nil, err
+	}
+
+	return inst, nil
+}
+
+// Instrument returns cfg with the call and attempt interceptors appended.
+func (i *Instrumentation) Instrument(cfg ym.Config) ym.Config {
+	cfg.Interceptors = append(append([]ym.Interceptor(nil), cfg.Interceptors...), i.Interceptor())
+	cfg.AttemptInterceptors = append(append([]ym.Interceptor(nil), cfg.AttemptInterceptors...), i.AttemptInterceptor())
+
+	return cfg
+}
+
+// Interceptor creates a span per logical API call and records call metrics.
+func (i *Instrumentation) Interceptor() ym.Interceptor {
+	return func(ctx context.Context, req *ym.Request, next ym.Invoker) (*http.Response, error) {
+		attrs := requestAttributes(req)
+		ctx, span := i.tracer.Start(ctx, "ym "+req.Endpoint(),
+			trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+		defer span.End()
+
+		started := time.Now()
+		resp, err := next(ctx, req)
+		elapsed := time.Since(started).Seconds()
+
+		outcome := outcomeAttributes(resp, err)
+		span.SetAttributes(outcome...)
+		span.SetAttributes(AttrAttempt.Int(req.Attempt))
+		if id := requestID(resp, err); id != "" {
+			span.SetAttributes(AttrRequestID.String(id))
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		metricAttrs := metric.WithAttributes(append(metricAttributes(req), outcome...)...)
+		i.requests.Add(ctx, 1, metricAttrs)
+		i.duration.Record(ctx, elapsed, metricAttrs)
+
+		return resp, err
+	}
+}
+
+// AttemptInterceptor creates a child span per HTTP attempt and counts retries.
+func (i *Instrumentation) AttemptInterceptor() ym.Interceptor {
+	return func(ctx context.Context, req *ym.Request, next ym.Invoker) (*http.Response, error) {
+		if req.Attempt > 1 {
+			trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(AttrAttempt.Int(req.Attempt)))
+			i.retries.Add(ctx, 1, metric.WithAttributes(metricAttributes(req)...))
+		}
+
+		ctx, span := i.tracer.Start(ctx, "ym.attempt "+req.Endpoint(),
+			trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(AttrAttempt.Int(req.Attempt)))
+		defer span.End()
+
+		resp, err := next(ctx, req)
+		span.SetAttributes(outcomeAttributes(resp, err)...)
+		if id := requestID(resp, err); id != "" {
+			span.SetAttributes(AttrRequestID.String(id))
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			var apiErr *ymerrors.APIError
+			if errors.As(err, &apiErr) && apiErr.Kind == ymerrors.KindRateLimited && apiErr.RetryAfter > 0 {
+				i.retryAfter.Record(ctx, apiErr.RetryAfter.Seconds(), metric.WithAttributes(metricAttributes(req)...))
+			}
+		}
+
+		return resp, err
+	}
+}
+
+// RecordRateLimitWait records time spent waiting for the client rate limiter.
+// It matches ym.RateLimiterConfig.OnWait.
+func (i *Instrumentation) RecordRateLimitWait(req *ym.Request, wait time.Duration) {
+	i.rateLimitWait.Record(context.Background(), wait.Seconds(), metric.WithAttributes(metricAttributes(req)...))
+}
+
+func requestAttributes(req *ym.Request) []attribute.KeyValue {
+	attrs := metricAttributes(req)
+	if req.ChatID != "" {
+		attrs = append(attrs, AttrChatID.String(string(req.ChatID)))
+	}
+	if req.Login != "" {
+		attrs = append(attrs, AttrLogin.String(string(req.Login)))
+	}
+
+	return attrs
+}
+
+// metricAttributes are the low-cardinality request attributes safe for metrics.
+func metricAttributes(req *ym.Request) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		AttrMethod.String(req.Method),
+		AttrEndpoint.String(req.Endpoint()),
+	}
+}
+
+// outcomeAttributes describe the result of a call: HTTP status and error kind.
+func outcomeAttributes(resp *http.Response, err error) []attribute.KeyValue {
+	if err == nil {
+		return []attribute.KeyValue{AttrStatusCode.Int(resp.StatusCode)}
+	}
+
+	attrs := []attribute.KeyValue{AttrErrorKind.String(ymerrors.KindOf(err).String())}
+	var apiErr *ymerrors.APIError
+	if errors.As(err, &apiErr) && apiErr.HTTPStatus > 0 {
+		attrs = append(attrs, AttrStatusCode.Int(apiErr.HTTPStatus))
+	}
+
+	return attrs
+}
+
+func requestID(resp *http.Response, err error) string {
+	if err == nil {
+		return resp.Header.Get("X-Request-Id")
+	}
+	var apiErr *ymerrors.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RequestID
+	}
+
+	return ""
+}
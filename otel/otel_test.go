@@ -0,0 +1,149 @@
+package otel
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/rekurt/ymsdk/client/ym"
+	"github.com/rekurt/ymsdk/client/ym/messages"
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+	"github.com/rekurt/ymsdk/internal/testutil"
+)
+
+func TestInstrumentationRecordsCallAndRetries(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	inst, err := New(Config{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)),
+		MeterProvider:  sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ok := testutil.NewResponse(http.StatusOK,
+		`{"ok":true,"message":{"message_id":1,"chat":{"id":"c1","type":"private"},"from":{"login":"bot"}}}`)
+	ok.Header.Set("X-Request-Id", "req-2")
+	doer := &testutil.FakeDoer{
+		Responses: []*http.Response{
+			{
+				StatusCode: http.StatusBadGateway,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"ok":false}`)),
+				Header:     http.Header{"X-Request-Id": []string{"req-1"}},
+			},
+			ok,
+		},
+	}
+	client := ym.NewClientWithHTTP(inst.Instrument(ym.Config{
+		BaseURL: "http://example.com",
+		ErrorHandling: ymerrors.ErrorHandlingConfig{
+			RetryStrategy: ymerrors.RetryStrategy{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+		},
+	}), doer)
+
+	if _, err := messages.NewService(client).SendToChat(context.Background(), "c1", "hi", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected call span and two attempt spans, got %d", len(spans))
+	}
+	call := spans[2]
+	if call.Name() != "ym /bot/v1/messages/sendText" {
+		t.Fatalf("unexpected call span name: %s", call.Name())
+	}
+	for _, attempt := range spans[:2] {
+		if attempt.Parent().SpanID() != call.SpanContext().SpanID() {
+			t.Fatalf("attempt span %s is not a child of the call span", attempt.Name())
+		}
+	}
+	assertAttr(t, call.Attributes(), AttrChatID, "c1")
+	assertAttr(t, call.Attributes(), AttrRequestID, "req-2")
+	assertAttr(t, spans[0].Attributes(), AttrErrorKind, "network")
+	assertAttr(t, spans[0].Attributes(), AttrRequestID, "req-1")
+	if events := call.Events(); len(events) != 1 || events[0].Name != "retry" {
+		t.Fatalf("expected one retry event, got %+v", events)
+	}
+
+	var data metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &data); err != nil {
+		t.Fatalf("collect metrics: %v", err)
+	}
+	sums := map[string]int64{}
+	for _, scope := range data.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			if sum, ok := m.Data.(metricdata.Sum[int64]); ok {
+				for _, dp := range sum.DataPoints {
+					sums[m.Name] += dp.Value
+				}
+			}
+		}
+	}
+	if sums["ym.client.requests"] != 1 || sums["ym.client.retries"] != 1 {
+		t.Fatalf("unexpected counters: %v", sums)
+	}
+}
+
+func TestInstrumentationSeparatesRetryAfterFromLimiterWaits(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	inst, err := New(Config{MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	limited := testutil.NewResponse(http.StatusTooManyRequests, `{"ok":false}`)
+	limited.Header.Set("Retry-After", "2")
+	client := ym.NewClientWithHTTP(inst.Instrument(ym.Config{
+		BaseURL: "http://example.com",
+		ErrorHandling: ymerrors.ErrorHandlingConfig{
+			RetryStrategy: ymerrors.RetryStrategy{MaxAttempts: 1},
+		},
+	}), &testutil.FakeDoer{Responses: []*http.Response{limited}})
+	if _, err := messages.NewService(client).SendToChat(context.Background(), "c1", "hi", nil); err == nil {
+		t.Fatal("expected rate limit error")
+	}
+	inst.RecordRateLimitWait(ym.NewRequest(http.MethodPost, "/bot/v1/messages/sendText"), 500*time.Millisecond)
+
+	var data metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &data); err != nil {
+		t.Fatalf("collect metrics: %v", err)
+	}
+	sums := map[string]float64{}
+	for _, scope := range data.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			if hist, ok := m.Data.(metricdata.Histogram[float64]); ok {
+				for _, dp := range hist.DataPoints {
+					sums[m.Name] += dp.Sum
+				}
+			}
+		}
+	}
+	if sums["ym.client.retry_after"] != 2 || sums["ym.client.rate_limit.wait"] != 0.5 {
+		t.Fatalf("unexpected histograms: %v", sums)
+	}
+}
+
+func assertAttr(t *testing.T, attrs []attribute.KeyValue, key attribute.Key, want string) {
+	t.Helper()
+	for _, kv := range attrs {
+		if kv.Key == key {
+			if got := kv.Value.Emit(); got != want {
+				t.Fatalf("attribute %s: expected %q, got %q", key, want, got)
+			}
+
+			return
+		}
+	}
+	t.Fatalf("attribute %s not found in %v", key, attrs)
+}
@@ -0,0 +1,115 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// DeadLetter is an entry that could not be delivered.
+type DeadLetter struct {
+	Entry
+	// Reason is the error of the last attempt.
+	Reason   string    `json:"reason"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// DeadLetterStore receives poison entries removed from the outbox.
+type DeadLetterStore interface {
+	Put(ctx context.Context, dl DeadLetter) error
+}
+
+// MemoryDeadLetters keeps dead letters in process memory.
+type MemoryDeadLetters struct {
+	mu      sync.Mutex
+	letters []DeadLetter
+}
+
+// FileDeadLetters appends dead letters to a JSON lines file.
+type FileDeadLetters struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (m *MemoryDeadLetters) Put(_ context.Context, dl DeadLetter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.letters = append(m.letters, dl)
+
+	return nil
+}
+
+// List returns the dead letters in the order they were put.
+func (m *MemoryDeadLetters) List() []DeadLetter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]DeadLetter(nil), m.letters...)
+}
+
+func NewFileDeadLetters(path string) *FileDeadLetters {
+	return &FileDeadLetters{path: path}
+}
+
+func (s *FileDeadLetters) Put(_ context.Context, dl DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(dl)
+	if err != nil {
+		return fmt.Errorf("yandex-messenger/outbox: encode dead letter: %w", err)
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("yandex-messenger/outbox: open dead letters: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+
+		return fmt.Errorf("yandex-messenger/outbox: write dead letter: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+
+		return fmt.Errorf("yandex-messenger/outbox: write dead letter: %w", err)
+	}
+
+	return f.Close()
+}
+
+// List reads the dead letters back, skipping a torn last line.
+func (s *FileDeadLetters) List() ([]DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("yandex-messenger/outbox: read dead letters: %w", err)
+	}
+	defer f.Close()
+
+	var letters []DeadLetter
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var dl DeadLetter
+			if jsonErr := json.Unmarshal(line, &dl); jsonErr != nil {
+				return nil, fmt.Errorf("yandex-messenger/outbox: decode dead letter: %w", jsonErr)
+			}
+			letters = append(letters, dl)
+		}
+		if err != nil {
+			return letters, nil
+		}
+	}
+}
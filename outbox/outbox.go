@@ -0,0 +1,297 @@
+// Package outbox delivers messages durably through messages.Service.
+//
+// Enqueue writes a message to a Store, normally the FileStore write-ahead log,
+// before it returns, so that a crash between deciding to send and the send
+// completing does not lose the message. Run delivers pending entries in order,
+// retries transient failures with backoff, acknowledges delivered entries and
+// moves poison entries to a DeadLetterStore. Entries left pending by a previous
+// process are replayed when Run starts.
+//
+// Delivery is at least once: an entry sent right before a crash is sent again
+// on restart. Every entry is sent with its id as payload_id, so the server can
+// deduplicate the resend.
+package outbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym"
+	"github.com/rekurt/ymsdk/client/ym/messages"
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+)
+
+const (
+	// DefaultMaxAttempts is the number of attempts before an entry is dead-lettered when Config.MaxAttempts is zero.
+	DefaultMaxAttempts = 10
+	// DefaultBackoff is the first retry delay when Config.Backoff is zero.
+	DefaultBackoff = time.Second
+	// DefaultMaxBackoff caps the retry delay when Config.MaxBackoff is zero.
+	DefaultMaxBackoff = 5 * time.Minute
+)
+
+// Sender sends text messages. *messages.Service implements it.
+type Sender interface {
+	SendToChat(ctx context.Context, chatID ym.ChatID, text string, opts *messages.SendMessageOptions) (*ym.Message, error)
+	SendToLogin(ctx context.Context, login ym.UserLogin, text string, opts *messages.SendMessageOptions) (*ym.Message, error)
+}
+
+// Message is a text message to deliver to a chat or a login.
+type Message struct {
+	ChatID   ym.ChatID      `json:"chat_id,omitempty"`
+	Login    ym.UserLogin   `json:"login,omitempty"`
+	ThreadID *ym.ThreadID   `json:"thread_id,omitempty"`
+	Text     string         `json:"text"`
+	Options  ym.SendOptions `json:"options"`
+}
+
+// Entry is a queued message with its delivery state.
+type Entry struct {
+	ID string `json:"id"`
+	Message
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// NextAttempt is the earliest time of the next attempt after a failure.
+	NextAttempt time.Time `json:"next_attempt,omitempty"`
+}
+
+type Config struct {
+	// Store persists the queue. It is required.
+	Store Store
+	// DeadLetters receives poison entries. If nil, they are only logged and dropped.
+	DeadLetters DeadLetterStore
+	// MaxAttempts bounds the attempts per entry, DefaultMaxAttempts if zero.
+	// Every attempt also goes through the client retry policy.
+	MaxAttempts int
+	// Backoff is the delay before the second attempt, doubled for every next one up to MaxBackoff.
+	// Rate limited attempts wait for Retry-After instead.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Interval, if positive, is the minimum time between sends, on top of the client rate limiter.
+	Interval time.Duration
+	// Logger, if set, receives delivery failures and dead letters.
+	Logger ym.Logger
+	// OnDelivered, if set, is called after an entry is delivered and acknowledged.
+	OnDelivered func(Entry, *ym.Message)
+}
+
+// Outbox is a durable message queue. It is safe for concurrent use; Run must
+// be called by a single goroutine.
+type Outbox struct {
+	sender Sender
+	cfg    Config
+	wake   chan struct{}
+}
+
+func New(sender Sender, cfg Config) (*Outbox, error) {
+	if cfg.Store == nil {
+		return nil, errors.New("yandex-messenger/outbox: store is required")
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+
+	return &Outbox{sender: sender, cfg: cfg, wake: make(chan struct{}, 1)}, nil
+}
+
+// Enqueue stores msg for delivery and returns its id. The message is durable
+// once Enqueue returns without error.
+func (o *Outbox) Enqueue(ctx context.Context, msg Message) (string, error) {
+	if (msg.ChatID == "") == (msg.Login == "") {
+		return "", errors.New("yandex-messenger/outbox: exactly one of chat_id or login is required")
+	}
+	if err := msg.Options.Validate(); err != nil {
+		return "", fmt.Errorf("yandex-messenger/outbox: %w", err)
+	}
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+	if err := o.cfg.Store.Append(ctx, Entry{ID: id, Message: msg, CreatedAt: time.Now()}); err != nil {
+		return "", err
+	}
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+
+	return id, nil
+}
+
+// Run delivers pending entries, including those left by a previous process,
+// until ctx is done, and then returns ctx.Err(). Entries to the same recipient
+// are delivered in order: an entry waiting for a retry holds back the later ones.
+// Store errors stop Run.
+func (o *Outbox) Run(ctx context.Context) error {
+	var last time.Time
+	for {
+		entries, err := o.cfg.Store.Pending(ctx)
+		if err != nil {
+			return fmt.Errorf("yandex-messenger/outbox: read pending entries: %w", err)
+		}
+
+		next := time.Time{}
+		blocked := make(map[string]bool)
+		for _, e := range entries {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			key := recipientKey(e.Message)
+			if blocked[key] {
+				continue
+			}
+			if now := time.Now(); e.NextAttempt.After(now) {
+				blocked[key] = true
+				if next.IsZero() || e.NextAttempt.Before(next) {
+					next = e.NextAttempt
+				}
+
+				continue
+			}
+			if o.cfg.Interval > 0 {
+				if err := ym.Sleep(ctx, time.Until(last.Add(o.cfg.Interval))); err != nil {
+					return err
+				}
+				last = time.Now()
+			}
+			delivered, err := o.deliver(ctx, e)
+			if err != nil {
+				return err
+			}
+			if !delivered {
+				blocked[key] = true
+			}
+		}
+
+		if len(entries) > 0 && next.IsZero() {
+			// Entries were delivered or dead-lettered; check for more right away.
+			continue
+		}
+		if err := o.idle(ctx, next); err != nil {
+			return err
+		}
+	}
+}
+
+// idle waits for a new entry, for next if it is set, or for ctx to be done.
+func (o *Outbox) idle(ctx context.Context, next time.Time) error {
+	var due <-chan time.Time
+	if !next.IsZero() {
+		timer := time.NewTimer(time.Until(next))
+		defer timer.Stop()
+		due = timer.C
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-o.wake:
+	case <-due:
+	}
+
+	return nil
+}
+
+// deliver makes one attempt and records its outcome. It reports whether the
+// entry left the queue; the error is a store failure or ctx.Err().
+func (o *Outbox) deliver(ctx context.Context, e Entry) (bool, error) {
+	opts := &messages.SendMessageOptions{SendOptions: e.Options, ThreadID: e.ThreadID}
+	if opts.PayloadID == "" {
+		opts.PayloadID = e.ID
+	}
+
+	var msg *ym.Message
+	var err error
+	if e.ChatID != "" {
+		msg, err = o.sender.SendToChat(ctx, e.ChatID, e.Text, opts)
+	} else {
+		msg, err = o.sender.SendToLogin(ctx, e.Login, e.Text, opts)
+	}
+	if err == nil {
+		if ackErr := o.cfg.Store.Ack(context.WithoutCancel(ctx), e.ID); ackErr != nil {
+			return false, fmt.Errorf("yandex-messenger/outbox: ack entry %s: %w", e.ID, ackErr)
+		}
+		if o.cfg.OnDelivered != nil {
+			o.cfg.OnDelivered(e, msg)
+		}
+
+		return true, nil
+	}
+	if ctx.Err() != nil {
+		// The attempt was interrupted by shutdown; it is retried on the next run.
+		return false, ctx.Err()
+	}
+
+	e.Attempts++
+	e.LastError = err.Error()
+	if !retryable(err) || e.Attempts >= o.cfg.MaxAttempts {
+		return true, o.deadLetter(ctx, e)
+	}
+	e.NextAttempt = time.Now().Add(ym.RetryDelay(e.Attempts, o.cfg.Backoff, o.cfg.MaxBackoff, err))
+	o.log(ctx, ym.LevelWarn, "outbox delivery failed, will retry", e, err, "next_attempt", e.NextAttempt)
+	if err := o.cfg.Store.Update(ctx, e); err != nil {
+		return false, fmt.Errorf("yandex-messenger/outbox: update entry %s: %w", e.ID, err)
+	}
+
+	return false, nil
+}
+
+func (o *Outbox) deadLetter(ctx context.Context, e Entry) error {
+	o.log(ctx, ym.LevelError, "outbox entry dead-lettered", e, errors.New(e.LastError))
+	if o.cfg.DeadLetters != nil {
+		dl := DeadLetter{Entry: e, Reason: e.LastError, FailedAt: time.Now()}
+		if err := o.cfg.DeadLetters.Put(ctx, dl); err != nil {
+			return fmt.Errorf("yandex-messenger/outbox: dead-letter entry %s: %w", e.ID, err)
+		}
+	}
+	if err := o.cfg.Store.Ack(ctx, e.ID); err != nil {
+		return fmt.Errorf("yandex-messenger/outbox: ack entry %s: %w", e.ID, err)
+	}
+
+	return nil
+}
+
+func (o *Outbox) log(ctx context.Context, level ym.LogLevel, msg string, e Entry, err error, keyvals ...any) {
+	if o.cfg.Logger == nil {
+		return
+	}
+	keyvals = append([]any{
+		"entry_id", e.ID, "attempts", e.Attempts, "error_kind", ymerrors.KindOf(err).String(), "error", err,
+	}, keyvals...)
+	o.cfg.Logger.Log(ctx, level, msg, keyvals...)
+}
+
+// retryable reports whether a later attempt may succeed. Authorization errors
+// are retried too: they affect every entry, and a fixed token should deliver
+// the queue instead of dead-lettering all of it.
+func retryable(err error) bool {
+	return ymerrors.IsTemporary(err) || ymerrors.KindOf(err) == ymerrors.KindUnauthorized
+}
+
+func recipientKey(m Message) string {
+	if m.ChatID != "" {
+		return "chat:" + string(m.ChatID)
+	}
+
+	return "login:" + string(m.Login)
+}
+
+func newID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("yandex-messenger/outbox: generate id: %w", err)
+	}
+
+	return hex.EncodeToString(b[:]), nil
+}
@@ -0,0 +1,238 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rekurt/ymsdk/client/ym"
+	"github.com/rekurt/ymsdk/client/ym/messages"
+	"github.com/rekurt/ymsdk/client/ym/ymerrors"
+)
+
+// attempt is one delivery attempt seen by a recorder.
+type attempt struct {
+	recipient string
+	text      string
+	payloadID string
+}
+
+// recorder is a Sender that records every attempt. outcome, if set, decides
+// the result of the n-th attempt (1-based) of an entry; nil delivers it.
+type recorder struct {
+	outcome func(text string, n int) error
+
+	mu       sync.Mutex
+	attempts []attempt
+	perEntry map[string]int
+}
+
+func (r *recorder) SendToChat(_ context.Context, chatID ym.ChatID, text string, opts *messages.SendMessageOptions) (*ym.Message, error) {
+	return r.record("chat:"+string(chatID), text, opts.PayloadID)
+}
+
+func (r *recorder) SendToLogin(_ context.Context, login ym.UserLogin, text string, opts *messages.SendMessageOptions) (*ym.Message, error) {
+	return r.record("login:"+string(login), text, opts.PayloadID)
+}
+
+func (r *recorder) record(recipient, text, payloadID string) (*ym.Message, error) {
+	r.mu.Lock()
+	if r.perEntry == nil {
+		r.perEntry = make(map[string]int)
+	}
+	r.attempts = append(r.attempts, attempt{recipient: recipient, text: text, payloadID: payloadID})
+	r.perEntry[payloadID]++
+	n, id := r.perEntry[payloadID], len(r.attempts)
+	r.mu.Unlock()
+
+	if r.outcome != nil {
+		if err := r.outcome(text, n); err != nil {
+			return nil, err
+		}
+	}
+
+	return &ym.Message{ID: ym.MessageID(id)}, nil
+}
+
+// log returns a copy of the recorded attempts.
+func (r *recorder) log() []attempt {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]attempt(nil), r.attempts...)
+}
+
+// failFirst fails the first attempts of texts with the given errors.
+func failFirst(errs map[string][]error) func(string, int) error {
+	return func(text string, n int) error {
+		if n <= len(errs[text]) {
+			return errs[text][n-1]
+		}
+
+		return nil
+	}
+}
+
+// runUntil runs the outbox until cond holds or the test times out.
+func runUntil(t *testing.T, o *Outbox, cond func() bool) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- o.Run(ctx) }()
+	for !cond() {
+		select {
+		case err := <-done:
+			t.Fatalf("run stopped early: %v", err)
+		case <-time.After(time.Millisecond):
+		}
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestOutboxDeliversRetriesAndDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	sender := &recorder{outcome: failFirst(map[string][]error{
+		"flaky":  {&ymerrors.APIError{Kind: ymerrors.KindNetwork, HTTPStatus: 503}},
+		"poison": {&ymerrors.APIError{Kind: ymerrors.KindBadRequest, HTTPStatus: 400, Description: "bad"}},
+	})}
+	store := NewMemoryStore()
+	dead := &MemoryDeadLetters{}
+	var delivered []string
+	var mu sync.Mutex
+	o, err := New(sender, Config{
+		Store: store, DeadLetters: dead, Backoff: time.Millisecond,
+		OnDelivered: func(e Entry, _ *ym.Message) {
+			mu.Lock()
+			delivered = append(delivered, e.Text)
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatalf("new outbox: %v", err)
+	}
+
+	var flakyID string
+	for _, m := range []Message{{ChatID: "c1", Text: "flaky"}, {ChatID: "c1", Text: "after"}, {Login: "u1", Text: "poison"}} {
+		id, err := o.Enqueue(ctx, m)
+		if err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+		if m.Text == "flaky" {
+			flakyID = id
+		}
+	}
+
+	runUntil(t, o, func() bool {
+		pending, _ := store.Pending(ctx)
+
+		return len(pending) == 0
+	})
+
+	log := sender.log()
+	if len(log) != 4 {
+		t.Fatalf("expected 4 attempts, got %+v", log)
+	}
+	// The retried entry holds back the later entry to the same chat.
+	var chat []string
+	for _, a := range log {
+		if a.recipient == "chat:c1" {
+			chat = append(chat, a.text)
+		}
+		if a.text == "flaky" && a.payloadID != flakyID {
+			t.Fatalf("expected entry id as payload_id, got %q", a.payloadID)
+		}
+	}
+	if strings.Join(chat, ",") != "flaky,flaky,after" {
+		t.Fatalf("expected per-chat order, got %v", chat)
+	}
+	if letters := dead.List(); len(letters) != 1 || letters[0].Text != "poison" || letters[0].Attempts != 1 || letters[0].Reason == "" {
+		t.Fatalf("unexpected dead letters: %+v", letters)
+	}
+	if len(delivered) != 2 {
+		t.Fatalf("expected 2 delivered callbacks, got %v", delivered)
+	}
+}
+
+func TestOutboxDeadLettersAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	limited := &ymerrors.APIError{Kind: ymerrors.KindRateLimited, RetryAfter: time.Millisecond}
+	sender := &recorder{outcome: failFirst(map[string][]error{"busy": {limited, limited, limited}})}
+	dead := NewFileDeadLetters(filepath.Join(t.TempDir(), "dead.jsonl"))
+	store := NewMemoryStore()
+	o, err := New(sender, Config{Store: store, DeadLetters: dead, MaxAttempts: 2})
+	if err != nil {
+		t.Fatalf("new outbox: %v", err)
+	}
+	if _, err := o.Enqueue(ctx, Message{ChatID: "c1", Text: "busy"}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	runUntil(t, o, func() bool {
+		pending, _ := store.Pending(ctx)
+
+		return len(pending) == 0
+	})
+
+	letters, err := dead.List()
+	if err != nil {
+		t.Fatalf("list dead letters: %v", err)
+	}
+	if len(letters) != 1 || letters[0].Attempts != 2 {
+		t.Fatalf("unexpected dead letters: %+v", letters)
+	}
+}
+
+func TestOutboxReplaysPendingOnStartup(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "outbox.wal")
+
+	// The first process enqueues and crashes before delivering.
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	first, _ := New(&recorder{}, Config{Store: store})
+	if _, err := first.Enqueue(ctx, Message{ChatID: "c1", Text: "survivor"}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	store.Close()
+
+	store, err = NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reopen store: %v", err)
+	}
+	defer store.Close()
+	sender := &recorder{}
+	second, _ := New(sender, Config{Store: store})
+	runUntil(t, second, func() bool { return len(sender.log()) == 1 })
+
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reopen store: %v", err)
+	}
+	defer reopened.Close()
+	if pending, _ := reopened.Pending(ctx); len(pending) != 0 {
+		t.Fatalf("expected delivered entry to be acknowledged, got %+v", pending)
+	}
+}
+
+func TestOutboxValidatesMessages(t *testing.T) {
+	if _, err := New(&recorder{}, Config{}); err == nil {
+		t.Fatal("expected missing store error")
+	}
+	o, _ := New(&recorder{}, Config{Store: NewMemoryStore()})
+	if _, err := o.Enqueue(context.Background(), Message{Text: "nobody"}); err == nil {
+		t.Fatal("expected recipient error")
+	}
+	if _, err := o.Enqueue(context.Background(), Message{ChatID: "c", Login: "l", Text: "both"}); err == nil {
+		t.Fatal("expected recipient error")
+	}
+}
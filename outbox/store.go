@@ -0,0 +1,344 @@
+package outbox
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	opAppend = "append"
+	opUpdate = "update"
+	opAck    = "ack"
+)
+
+// compactMin is the number of log records below which the log is never compacted.
+var compactMin = 1024
+
+// Store persists outbox entries until they are acknowledged.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Append adds an entry. The entry must be durable when Append returns.
+	Append(ctx context.Context, e Entry) error
+	// Update replaces a pending entry, e.g. after a failed attempt.
+	Update(ctx context.Context, e Entry) error
+	// Ack removes a delivered or dead-lettered entry. Unknown ids are ignored.
+	Ack(ctx context.Context, id string) error
+	// Pending returns the unacknowledged entries in append order.
+	Pending(ctx context.Context) ([]Entry, error)
+}
+
+// MemoryStore keeps entries in process memory. Entries are lost on restart,
+// so it is meant for tests and for processes that accept the loss.
+type MemoryStore struct {
+	mu sync.Mutex
+	q  queue
+}
+
+// FileStore is a write-ahead log of JSON lines. Every change is appended and
+// synced to disk before it is acknowledged; on open the log is replayed to
+// rebuild the pending entries. A torn last line left by a crash is discarded.
+// The log is compacted once acknowledged records dominate it; a failed
+// compaction does not fail the Ack that triggered it.
+type FileStore struct {
+	mu      sync.Mutex
+	path    string
+	f       *os.File
+	q       queue
+	records int
+}
+
+type walRecord struct {
+	Op    string `json:"op"`
+	Entry *Entry `json:"entry,omitempty"`
+	ID    string `json:"id,omitempty"`
+}
+
+// queue is the in-memory index of pending entries shared by the stores.
+type queue struct {
+	entries map[string]Entry
+	order   []string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (s *MemoryStore) Append(_ context.Context, e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.q.append(e)
+
+	return nil
+}
+
+func (s *MemoryStore) Update(_ context.Context, e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.q.update(e)
+
+	return nil
+}
+
+func (s *MemoryStore) Ack(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.q.ack(id)
+
+	return nil
+}
+
+func (s *MemoryStore) Pending(_ context.Context) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.q.pending(), nil
+}
+
+// NewFileStore opens or creates the log at path and replays it.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path}
+	good, err := s.replay()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("yandex-messenger/outbox: open log: %w", err)
+	}
+	// Drop a torn tail so that new records start on a clean line.
+	if err := f.Truncate(good); err != nil {
+		f.Close()
+
+		return nil, fmt.Errorf("yandex-messenger/outbox: open log: %w", err)
+	}
+	if _, err := f.Seek(good, io.SeekStart); err != nil {
+		f.Close()
+
+		return nil, fmt.Errorf("yandex-messenger/outbox: open log: %w", err)
+	}
+	s.f = f
+
+	return s, nil
+}
+
+func (s *FileStore) Append(_ context.Context, e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.write(walRecord{Op: opAppend, Entry: &e}); err != nil {
+		return err
+	}
+	s.q.append(e)
+
+	return nil
+}
+
+func (s *FileStore) Update(_ context.Context, e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.q.entries[e.ID]; !ok {
+		return nil
+	}
+	if err := s.write(walRecord{Op: opUpdate, Entry: &e}); err != nil {
+		return err
+	}
+	s.q.update(e)
+
+	return nil
+}
+
+func (s *FileStore) Ack(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.q.entries[id]; !ok {
+		return nil
+	}
+	if err := s.write(walRecord{Op: opAck, ID: id}); err != nil {
+		return err
+	}
+	s.q.ack(id)
+
+	// The ack is already durable; a failed compaction only leaves the log
+	// longer and is retried on a later Ack.
+	if s.records >= compactMin && s.records > 4*len(s.q.entries) {
+		_ = s.compact()
+	}
+
+	return nil
+}
+
+func (s *FileStore) Pending(_ context.Context) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.q.pending(), nil
+}
+
+// Close closes the log file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.f.Close()
+}
+
+// replay rebuilds the queue from the log and returns the size of its valid prefix.
+func (s *FileStore) replay() (int64, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("yandex-messenger/outbox: read log: %w", err)
+	}
+
+	var good int64
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		end := good + int64(len(line)) + 1
+		var rec walRecord
+		if err := json.Unmarshal(line, &rec); err != nil || end > int64(len(data)) {
+			if end >= int64(len(data)) {
+				// The last record was not completely written.
+				break
+			}
+
+			return 0, fmt.Errorf("yandex-messenger/outbox: corrupt log record at offset %d: %w", good, err)
+		}
+		s.apply(rec)
+		s.records++
+		good = end
+	}
+
+	return good, nil
+}
+
+func (s *FileStore) apply(rec walRecord) {
+	switch rec.Op {
+	case opAppend:
+		if rec.Entry != nil {
+			s.q.append(*rec.Entry)
+		}
+	case opUpdate:
+		if rec.Entry != nil {
+			s.q.update(*rec.Entry)
+		}
+	case opAck:
+		s.q.ack(rec.ID)
+	}
+}
+
+// write appends rec to the log and syncs it. Callers must hold s.mu.
+func (s *FileStore) write(rec walRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("yandex-messenger/outbox: encode log record: %w", err)
+	}
+	if _, err := s.f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("yandex-messenger/outbox: write log: %w", err)
+	}
+	if err := s.f.Sync(); err != nil {
+		return fmt.Errorf("yandex-messenger/outbox: sync log: %w", err)
+	}
+	s.records++
+
+	return nil
+}
+
+// compact rewrites the log with the pending entries only. Callers must hold s.mu.
+func (s *FileStore) compact() error {
+	var buf bytes.Buffer
+	pending := s.q.pending()
+	for i := range pending {
+		data, err := json.Marshal(walRecord{Op: opAppend, Entry: &pending[i]})
+		if err != nil {
+			return fmt.Errorf("yandex-messenger/outbox: encode log record: %w", err)
+		}
+		buf.Write(append(data, '\n'))
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("yandex-messenger/outbox: compact log: %w", err)
+	}
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(tmp.Name())
+
+		return fmt.Errorf("yandex-messenger/outbox: compact log: %w", err)
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fail(err)
+	}
+
+	// The temporary file becomes the log, so appends never go to a file that
+	// was already replaced.
+	s.f.Close()
+	s.f = tmp
+	s.records = len(pending)
+
+	return nil
+}
+
+func (q *queue) append(e Entry) {
+	if q.entries == nil {
+		q.entries = make(map[string]Entry)
+	}
+	if _, ok := q.entries[e.ID]; !ok {
+		q.order = append(q.order, e.ID)
+	}
+	q.entries[e.ID] = e
+}
+
+func (q *queue) update(e Entry) {
+	if _, ok := q.entries[e.ID]; ok {
+		q.entries[e.ID] = e
+	}
+}
+
+func (q *queue) ack(id string) {
+	delete(q.entries, id)
+	// Acknowledged ids are dropped from the order once they dominate it.
+	if len(q.order) > 64 && len(q.order) > 2*len(q.entries) {
+		order := q.order[:0]
+		for _, id := range q.order {
+			if _, ok := q.entries[id]; ok {
+				order = append(order, id)
+			}
+		}
+		q.order = order
+	}
+}
+
+func (q *queue) pending() []Entry {
+	out := make([]Entry, 0, len(q.entries))
+	for _, id := range q.order {
+		if e, ok := q.entries[id]; ok {
+			out = append(out, e)
+		}
+	}
+
+	return out
+}
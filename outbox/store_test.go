@@ -0,0 +1,133 @@
+package outbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStoreReplaysLog(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "outbox.wal")
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if err := s.Append(ctx, Entry{ID: id, Message: Message{ChatID: "c1", Text: id}}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if err := s.Update(ctx, Entry{ID: "b", Message: Message{ChatID: "c1", Text: "b"}, Attempts: 2}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := s.Ack(ctx, "a"); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	s.Close()
+
+	// A crash in the middle of a write leaves a torn record at the end.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatalf("open log: %v", err)
+	}
+	f.WriteString(`{"op":"ack","id":"`)
+	f.Close()
+
+	s, err = NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reopen store: %v", err)
+	}
+	defer s.Close()
+	pending, err := s.Pending(ctx)
+	if err != nil {
+		t.Fatalf("pending: %v", err)
+	}
+	if len(pending) != 2 || pending[0].ID != "b" || pending[0].Attempts != 2 || pending[1].ID != "c" {
+		t.Fatalf("unexpected pending entries: %+v", pending)
+	}
+
+	if err := s.Ack(ctx, "c"); err != nil {
+		t.Fatalf("ack after reopen: %v", err)
+	}
+	s2, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reopen store: %v", err)
+	}
+	defer s2.Close()
+	if pending, _ := s2.Pending(ctx); len(pending) != 1 || pending[0].ID != "b" {
+		t.Fatalf("unexpected pending entries after torn tail: %+v", pending)
+	}
+}
+
+func TestFileStoreRejectsCorruptLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.wal")
+	if err := os.WriteFile(path, []byte("garbage\n{\"op\":\"ack\",\"id\":\"x\"}\n"), 0o600); err != nil {
+		t.Fatalf("write log: %v", err)
+	}
+	if _, err := NewFileStore(path); err == nil {
+		t.Fatal("expected corrupt log error")
+	}
+}
+
+func TestFileStoreCompacts(t *testing.T) {
+	defer func(n int) { compactMin = n }(compactMin)
+	compactMin = 8
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "outbox.wal")
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer s.Close()
+	s.Append(ctx, Entry{ID: "keep", Message: Message{Login: "u", Text: "keep"}})
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		s.Append(ctx, Entry{ID: id, Message: Message{Login: "u", Text: id}})
+		s.Ack(ctx, id)
+	}
+	// The log is compacted to the single pending entry after the fourth ack.
+	if s.records != 3 {
+		t.Fatalf("expected compacted log with 3 records, got %d", s.records)
+	}
+	s.Append(ctx, Entry{ID: "new", Message: Message{Login: "u", Text: "new"}})
+
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reopen store: %v", err)
+	}
+	defer reopened.Close()
+	if pending, _ := reopened.Pending(ctx); len(pending) != 2 || pending[0].ID != "keep" || pending[1].ID != "new" {
+		t.Fatalf("unexpected pending entries: %+v", pending)
+	}
+}
+
+func TestFileStoreAckSurvivesFailedCompaction(t *testing.T) {
+	defer func(n int) { compactMin = n }(compactMin)
+	compactMin = 2
+
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "wal")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	s, err := NewFileStore(filepath.Join(dir, "outbox.wal"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer s.Close()
+	if err := s.Append(ctx, Entry{ID: "a", Message: Message{Login: "u", Text: "a"}}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	// Without the directory the temporary log cannot be created.
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("remove dir: %v", err)
+	}
+	if err := s.Ack(ctx, "a"); err != nil {
+		t.Fatalf("expected the ack to succeed despite the failed compaction, got %v", err)
+	}
+	if pending, _ := s.Pending(ctx); len(pending) != 0 {
+		t.Fatalf("expected no pending entries, got %+v", pending)
+	}
+}